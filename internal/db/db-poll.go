@@ -24,13 +24,17 @@ import (
 	"fmt"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"go.uber.org/zap"
+	"time"
 )
 
 type IDBPoll interface {
 	GetLogger() *zap.Logger
 	NewPoll() types.Poll
 	UpdatePoll(poll *types.Poll) (err error)
+	UpdatePollHeld(poll *types.Poll, holderId string) (held bool, err error)
 	SelectPoll(poll *types.Poll) (err error)
+	AcquireLease(pollId, holderId string, ttl time.Duration) (held bool, err error)
+	ReleaseLease(pollId, holderId string) (err error)
 }
 
 type PollStruct struct {
@@ -69,8 +73,12 @@ const sqlUpdatePoll = `UPDATE poll
 		WHERE poll_instance=$4`
 
 func (p *PollStruct) UpdatePoll(poll *types.Poll) (err error) {
+	return updatePoll(p.db.Exec, poll)
+}
+
+func updatePoll(exec func(query string, args ...interface{}) (sql.Result, error), poll *types.Poll) (err error) {
 	var res sql.Result
-	res, err = p.db.Exec(sqlUpdatePoll, poll.LastPolled, poll.EnvBaseTime, poll.LastPollCompleted, poll.Id)
+	res, err = exec(sqlUpdatePoll, poll.LastPolled, poll.EnvBaseTime, poll.LastPollCompleted, poll.Id)
 	if err != nil {
 		return
 	}
@@ -88,6 +96,44 @@ func (p *PollStruct) UpdatePoll(poll *types.Poll) (err error) {
 	return
 }
 
+// the lease row stays locked until the poll update commits, so the lease cannot be taken over part way through
+const sqlLockHeldLease = `SELECT holder_id
+		FROM poll_lease
+		WHERE poll_instance=$1
+		  AND holder_id=$2
+		  AND expires_on > CURRENT_TIMESTAMP
+		FOR UPDATE`
+
+// UpdatePollHeld updates the poll only while the given holder has an unexpired lease, in a single transaction. held is
+// false, and the poll is not updated, when the lease was lost, e.g. it expired and another instance took over.
+func (p *PollStruct) UpdatePollHeld(poll *types.Poll, holderId string) (held bool, err error) {
+	var tx *sql.Tx
+	tx, err = p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var leaseHolder string
+	err = tx.QueryRow(sqlLockHeldLease, poll.Id, holderId).Scan(&leaseHolder)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	held = true
+	err = updatePoll(tx.Exec, poll)
+	return
+}
+
 const sqlSelectPoll = `SELECT 
 			last_polled_on, 
 			env_base_time, 
@@ -112,3 +158,43 @@ func (p *PollStruct) SelectPoll(poll *types.Poll) (err error) {
 
 	return
 }
+
+// the lease is taken over when expired, or renewed by its current holder. no row is returned when another holder has it.
+const sqlAcquireLease = `INSERT INTO poll_lease
+		(poll_instance, holder_id, expires_on, heartbeat_on)
+		VALUES ($1, $2, CURRENT_TIMESTAMP + make_interval(secs => $3), CURRENT_TIMESTAMP)
+		ON CONFLICT (poll_instance) DO UPDATE
+		SET
+			holder_id=EXCLUDED.holder_id,
+			expires_on=EXCLUDED.expires_on,
+			heartbeat_on=EXCLUDED.heartbeat_on
+		WHERE poll_lease.holder_id=EXCLUDED.holder_id
+			OR poll_lease.expires_on < CURRENT_TIMESTAMP
+		RETURNING holder_id`
+
+// AcquireLease takes (or renews) the polling lease for the given holder, so only one server instance polls at a time.
+// The lease lasts for ttl, so if the holder dies, another instance takes over once it expires.
+func (p *PollStruct) AcquireLease(pollId, holderId string, ttl time.Duration) (held bool, err error) {
+	var leaseHolder string
+	err = p.db.QueryRow(sqlAcquireLease, pollId, holderId, ttl.Seconds()).Scan(&leaseHolder)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		p.logger.Error("acquire lease error", zap.Error(err))
+		return
+	}
+	held = leaseHolder == holderId
+	return
+}
+
+const sqlReleaseLease = `DELETE FROM poll_lease
+		WHERE poll_instance=$1
+		AND holder_id=$2`
+
+// ReleaseLease gives up the polling lease, if still held by the given holder, so another instance can take over now.
+func (p *PollStruct) ReleaseLease(pollId, holderId string) (err error) {
+	_, err = p.db.Exec(sqlReleaseLease, pollId, holderId)
+	return
+}
@@ -20,6 +20,7 @@
 package db
 
 import (
+	"database/sql/driver"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zaptest"
@@ -77,3 +78,53 @@ func SetupMockPollSelectAndUpdateAnyUpdateTime(mock sqlmock.Sqlmock, pollId stri
 		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), pollId).
 		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
 }
+
+// SetupMockPollUpdateHeld expects the poll to be updated to polledTo, if the lease is still held by holderId
+func SetupMockPollUpdateHeld(mock sqlmock.Sqlmock, pollId, holderId string, polledTo driver.Value, held bool) {
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"holder_id"})
+	if held {
+		rows.AddRow(holderId)
+	}
+	mock.ExpectQuery(PollConvertSqlToDbMockExpect(sqlLockHeldLease)).
+		WithArgs(pollId, holderId).
+		WillReturnRows(rows)
+	if held {
+		mock.ExpectExec(PollConvertSqlToDbMockExpect(sqlUpdatePoll)).
+			WithArgs(polledTo, sqlmock.AnyArg(), sqlmock.AnyArg(), pollId).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func SetupMockPollSelectAndUpdateHeld(mock sqlmock.Sqlmock, pollId, holderId string, now time.Time) {
+	SetupMockPollSelect(mock, pollId, now)
+	SetupMockPollUpdateHeld(mock, pollId, holderId, now, true)
+}
+
+func SetupMockPollSelectAndUpdateHeldAnyUpdateTime(mock sqlmock.Sqlmock, pollId, holderId string, now time.Time) {
+	SetupMockPollSelect(mock, pollId, now)
+	SetupMockPollUpdateHeld(mock, pollId, holderId, sqlmock.AnyArg(), true)
+}
+
+// SetupMockPollSelectAndUpdateTo expects a poll from lastPolled, which commits polledTo as the new last polled time
+func SetupMockPollSelectAndUpdateTo(mock sqlmock.Sqlmock, pollId, holderId string, lastPolled, polledTo time.Time) {
+	SetupMockPollSelect(mock, pollId, lastPolled)
+	SetupMockPollUpdateHeld(mock, pollId, holderId, polledTo, true)
+}
+
+func SetupMockPollAcquireLease(mock sqlmock.Sqlmock, pollId, holderId string, held bool) {
+	rows := sqlmock.NewRows([]string{"holder_id"})
+	if held {
+		rows.AddRow(holderId)
+	}
+	mock.ExpectQuery(PollConvertSqlToDbMockExpect(sqlAcquireLease)).
+		WithArgs(pollId, holderId, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+func SetupMockPollReleaseLease(mock sqlmock.Sqlmock, pollId, holderId string) {
+	mock.ExpectExec(PollConvertSqlToDbMockExpect(sqlReleaseLease)).
+		WithArgs(pollId, holderId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
@@ -103,6 +103,83 @@ func TestUpdatePoll(t *testing.T) {
 	assert.NoError(t, db.UpdatePoll(&poll))
 }
 
+func TestUpdatePollHeld(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	poll := types.Poll{Id: PollId, LastPolled: now}
+	SetupMockPollUpdateHeld(mock, PollId, "myHolderId", now, true)
+
+	held, err := db.UpdatePollHeld(&poll, "myHolderId")
+	assert.NoError(t, err)
+	assert.True(t, held)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePollHeldLeaseLost(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := types.Poll{Id: PollId, LastPolled: time.Now()}
+	SetupMockPollUpdateHeld(mock, PollId, "myHolderId", nil, false)
+
+	held, err := db.UpdatePollHeld(&poll, "myHolderId")
+	assert.NoError(t, err)
+	assert.False(t, held)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePollHeldBeginError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced begin error")
+	mock.ExpectBegin().WillReturnError(forcedError)
+
+	held, err := db.UpdatePollHeld(&types.Poll{Id: PollId}, "myHolderId")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, held)
+}
+
+func TestUpdatePollHeldLockError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced lock error")
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlLockHeldLease)).
+		WithArgs(PollId, "myHolderId").
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	held, err := db.UpdatePollHeld(&types.Poll{Id: PollId}, "myHolderId")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, held)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePollHeldUpdateError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := types.Poll{Id: PollId}
+	forcedError := fmt.Errorf("forced poll error")
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlLockHeldLease)).
+		WithArgs(PollId, "myHolderId").
+		WillReturnRows(sqlmock.NewRows([]string{"holder_id"}).AddRow("myHolderId"))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdatePoll)).
+		WithArgs(poll.LastPolled, poll.EnvBaseTime, poll.LastPollCompleted, poll.Id).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	held, err := db.UpdatePollHeld(&poll, "myHolderId")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.True(t, held)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestSelectPollError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDBPoll(t)
 	defer closeDbFunc()
@@ -148,3 +225,49 @@ func TestSelectPoll(t *testing.T) {
 		LastPollCompleted: now.Add(time.Second * 2),
 	}, poll)
 }
+
+func TestAcquireLeaseError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced acquire lease error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlAcquireLease)).
+		WithArgs(PollId, "myHolderId", float64(360)).
+		WillReturnError(forcedError)
+
+	held, err := db.AcquireLease(PollId, "myHolderId", 6*time.Minute)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, held)
+}
+
+func TestAcquireLeaseHeldElsewhere(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	SetupMockPollAcquireLease(mock, PollId, "myHolderId", false)
+
+	held, err := db.AcquireLease(PollId, "myHolderId", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, held)
+}
+
+func TestAcquireLease(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	SetupMockPollAcquireLease(mock, PollId, "myHolderId", true)
+
+	held, err := db.AcquireLease(PollId, "myHolderId", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, held)
+}
+
+func TestReleaseLease(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	SetupMockPollReleaseLease(mock, PollId, "myHolderId")
+
+	assert.NoError(t, db.ReleaseLease(PollId, "myHolderId"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -21,19 +21,39 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/lib/pq"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"go.uber.org/zap"
+	"math"
+	"sort"
 	"time"
 )
 
+// IScoreDB scores participants. Scoring only writes through an IScoreTx, so it is always done under its locks.
 type IScoreDB interface {
 	GetDb() (db *sql.DB)
-	SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64)
-	InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error)
+	BeginScoreTx() (scoreTx IScoreTx, err error)
+	InsertDeadLetter(deadLetter *types.DeadLetter) (err error)
+}
+
+// IScoreTx is a unit of work for scoring a single participant. The participant is locked by SelectScoringHistory, and
+// then the scoring_event key of the scored pull request by SelectPriorScore, until Commit or Rollback. So concurrent
+// scoring of the same participant, or of the same pull request, is serialized.
+type IScoreTx interface {
+	InsertProcessedEvent(sourceId string, participant *types.ParticipantStruct) (firstTime bool, err error)
+	SelectScoringHistory(participant *types.ParticipantStruct, msg *types.ScoringMessage, asOf time.Time) (history *types.ScoringHistory, err error)
+	SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64, err error)
+	InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, scoredOn time.Time) (err error)
 	UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error)
+	InsertLedgerEntry(entry *types.LedgerEntry) (err error)
+	Commit() (err error)
+	Rollback() (err error)
 }
 
 type IBBashDB interface {
@@ -53,33 +73,62 @@ type IBBashDB interface {
 	ValidOrganization(msg *types.ScoringMessage) (orgExists bool, err error)
 
 	SelectParticipantsToScore(msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error)
-	SelectPointValue(msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64)
+	SelectPointValues(campaignName string) (pointValues map[string]float64, err error)
 	IScoreDB
 
 	InsertParticipant(participant *types.ParticipantStruct) (err error)
 	SelectParticipantDetail(campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error)
-	SelectParticipantsInCampaign(campaignName string) (participants []types.ParticipantStruct, err error)
+	SelectParticipantsInCampaign(campaignName string, filter *types.ParticipantFilter) (participants []types.ParticipantStruct, total int, err error)
 	UpdateParticipant(participant *types.ParticipantStruct) (rowsAffected int64, err error)
 	DeleteParticipant(campaign, scpName, loginName string) (participantId string, err error)
 	UpdateParticipantTeam(teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error)
+	SelectParticipantLedger(campaignName, scpName, loginName string) (entries []types.LedgerEntry, err error)
+	SelectScoredPullRequests(campaignName, scpName, loginName string) (pullRequests []types.ScoredPullRequest, err error)
+	SelectCampaignScoredPullRequests(campaignName string) (pullRequests []types.ScoredPullRequest, err error)
+	SelectCampaignDailyScores(campaignName string) (days []types.DayScore, err error)
+	CountParticipants(campaignName string) (registered, active int, err error)
 
 	InsertTeam(team *types.TeamStruct) (err error)
+	SelectTeamMemberScores(campaignName string) (teams []types.TeamMemberScores, err error)
+	UpdateTeamName(campaignName, teamName, newTeamName string) (rowsAffected int64, err error)
+	DeleteTeam(campaignName, teamName string) (rowsAffected int64, err error)
+	DeleteParticipantTeam(campaignName, scpName, loginName string) (rowsAffected int64, err error)
+
+	DeleteProcessedEvents(processedBefore time.Time) (rowsAffected int64, err error)
+
+	SelectDeadLetters() (deadLetters []types.DeadLetter, err error)
+	SelectDeadLetter(id string) (deadLetter *types.DeadLetter, err error)
+	UpdateDeadLetterAttempt(id, attemptErr string) (rowsAffected int64, err error)
+	DeleteDeadLetter(id string) (rowsAffected int64, err error)
+
+	RecomputeCampaignScores(campaignName string, reprice func(entry *types.LedgerEntry, scoredOn time.Time, history *types.ScoringHistory) (points float64, err error)) (report *types.RecomputeReport, err error)
 
 	InsertBug(bug *types.BugStruct) (err error)
 	UpdateBug(bug *types.BugStruct) (rowsAffected int64, err error)
 	SelectBugs() (bugs []types.BugStruct, err error)
+
+	SelectScoringRules(campaignName string, rules *types.ScoringRules) (err error)
+	UpsertScoringRules(campaignName string, rules *types.ScoringRules) (rowsAffected int64, err error)
+}
+
+// sqlRunner is implemented by both *sql.DB and *sql.Tx, so statements can be shared inside and outside of transactions.
+type sqlRunner interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 type BBashDB struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db          *sql.DB
+	logger      *zap.Logger
+	pointValues *pointValueCache
 }
 
 // Roll that beautiful bean footage
 var _ IBBashDB = (*BBashDB)(nil)
 
 func New(db *sql.DB, logger *zap.Logger) *BBashDB {
-	return &BBashDB{db: db, logger: logger}
+	return &BBashDB{db: db, logger: logger, pointValues: newPointValueCache(pointValueTTL)}
 }
 
 func (p *BBashDB) GetDb() (db *sql.DB) {
@@ -291,7 +340,8 @@ const sqlSelectParticipantId = `SELECT
         campaign.name,
        	source_control_provider.name,
         participant.login_name,
-        team.name
+        team.name,
+        participant.private
 		FROM participant
 		INNER JOIN campaign ON campaign.Id = fk_campaign
 		INNER JOIN source_control_provider ON source_control_provider.Id = fk_scp
@@ -313,7 +363,7 @@ func (p *BBashDB) SelectParticipantsToScore(msg *types.ScoringMessage, now time.
 		partier := types.ParticipantStruct{}
 		var nullableTeamName sql.NullString
 		// note: reads the db (capitalized) scpName
-		err = rows.Scan(&partier.ID, &partier.CampaignName, &partier.ScpName, &partier.LoginName, &nullableTeamName)
+		err = rows.Scan(&partier.ID, &partier.CampaignName, &partier.ScpName, &partier.LoginName, &nullableTeamName, &partier.Private)
 		if nullableTeamName.Valid {
 			partier.TeamName = nullableTeamName.String
 		}
@@ -326,31 +376,55 @@ func (p *BBashDB) SelectParticipantsToScore(msg *types.ScoringMessage, now time.
 	return
 }
 
-const sqlSelectPointValue = `SELECT pointValue FROM bug 
-	INNER JOIN campaign ON campaign.Id = fk_campaign	
-	WHERE fk_campaign = (SELECT campaign.Id FROM campaign WHERE name = $1) 
-	  AND category = $2`
+const sqlSelectPointValues = `SELECT category, pointValue FROM bug
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+// SelectPointValues reads the point value of each bug category of the campaign. The point values are cached (see
+// pointValueCache), so they must not be modified.
+func (p *BBashDB) SelectPointValues(campaignName string) (pointValues map[string]float64, err error) {
+	now := time.Now()
+	var generation uint64
+	var cached bool
+	if pointValues, generation, cached = p.pointValues.get(campaignName, now); cached {
+		return
+	}
+
+	rows, err := p.db.Query(sqlSelectPointValues, campaignName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
 
-func (p *BBashDB) SelectPointValue(msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
-	row := p.db.QueryRow(sqlSelectPointValue, campaignName, bugType)
-	pointValue = 1
-	if err := row.Scan(&pointValue); err != nil {
-		// ignore error from scan operation
-		p.logger.Debug("ignoring missing pointValue",
-			zap.String("bugType", bugType), zap.Error(err), zap.Any("scoringMsg", msg))
+	loaded := map[string]float64{}
+	for rows.Next() {
+		var category string
+		var pointValue float64
+		if err = rows.Scan(&category, &pointValue); err != nil {
+			return
+		}
+		loaded[category] = pointValue
+	}
+	if err = rows.Err(); err != nil {
+		return
 	}
+
+	p.pointValues.put(campaignName, loaded, generation, now)
+	pointValues = loaded
 	return
 }
 
 const sqlUpdateParticipantScore = `UPDATE participant 
-		SET Score = Score + $1 
+		SET Score = Score + $1,
+		    score_changed_on = CASE WHEN $1 = 0 THEN score_changed_on ELSE NOW() END
 		WHERE id = $2 
 		RETURNING Score`
 
-func (p *BBashDB) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
-	var score int
-	row := p.db.QueryRow(sqlUpdateParticipantScore, delta, participant.ID)
-	err = row.Scan(&score)
+// updateParticipantScore adds the delta to the participant's score, and sets participant.Score to the updated score.
+func updateParticipantScore(runner sqlRunner, participant *types.ParticipantStruct, delta float64) (err error) {
+	row := runner.QueryRow(sqlUpdateParticipantScore, delta, participant.ID)
+	err = row.Scan(&participant.Score)
 	return
 }
 
@@ -362,35 +436,332 @@ const sqlScoreQuery = `SELECT points
 				AND repoName = $4
 				AND pr = $5`
 
-func (p *BBashDB) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
-	row := p.db.QueryRow(sqlScoreQuery, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
-	oldPoints = 0
-	err := row.Scan(&oldPoints)
-	if err != nil {
-		// ignore error case from scan when no row exists, will occur when this is a new score event
-		p.logger.Debug("ignoring likely new score event", zap.Error(err), zap.Any("ScoringMessage", msg))
+const sqlInsertScoringEvent = `INSERT INTO scoring_event
+			(fk_campaign, fk_scp, repoOwner, repoName, pr, username, points, bug_counts, scored_on)
+			VALUES ((SELECT id FROM campaign WHERE name = $1), 
+			        (SELECT id FROM source_control_provider WHERE name = $2),
+			        $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO
+				UPDATE SET points = $7, bug_counts = $8, scored_on = $9`
+
+// insertScoringEvent stamps the event with the time it was scored at, which is also the time of its ledger entry, so
+// the scoring history as of a time agrees with the scored points.
+func insertScoringEvent(runner sqlRunner, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, scoredOn time.Time) (err error) {
+	var bugCounts []byte
+	if bugCounts, err = json.Marshal(msg.BugCounts); err != nil {
+		return
 	}
+	_, err = runner.Exec(sqlInsertScoringEvent, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints, bugCounts, scoredOn)
 	return
 }
 
-const sqlInsertScoringEvent = `INSERT INTO scoring_event
-			(fk_campaign, fk_scp, repoOwner, repoName, pr, username, points)
-			VALUES ((SELECT id FROM campaign WHERE name = $1), 
+const sqlInsertLedgerEntry = `INSERT INTO score_ledger
+			(fk_campaign, fk_scp, login_name, source_id, repoOwner, repoName, pr, old_points, new_points, bug_counts,
+			 scoring_message, resolved_categories, created_on)
+			VALUES ((SELECT id FROM campaign WHERE name = $1),
 			        (SELECT id FROM source_control_provider WHERE name = $2),
-			        $3, $4, $5, $6, $7)
-			ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO
-				UPDATE SET points = $7`
+			        $3, $4, $5, $6, NULLIF($7, 0), $8, $9, $10, $11, $12, COALESCE($13, NOW()))
+			RETURNING Id, created_on`
+
+// insertLedgerEntry records the entry as created at entry.CreatedOn, which is the time it was scored at, or else right now.
+// An entry with no pull request corrects the score of the participant rather than scoring a pull request.
+func insertLedgerEntry(runner sqlRunner, logger *zap.Logger, entry *types.LedgerEntry) (err error) {
+	var bugCounts, scoringMessage, resolvedCategories []byte
+	if bugCounts, err = json.Marshal(entry.BugCounts); err != nil {
+		return
+	}
+	if scoringMessage, err = json.Marshal(entry.ScoringMessage); err != nil {
+		return
+	}
+	if resolvedCategories, err = json.Marshal(entry.ResolvedCategories); err != nil {
+		return
+	}
+	err = runner.QueryRow(sqlInsertLedgerEntry,
+		entry.CampaignName,
+		entry.ScpName,
+		entry.LoginName,
+		entry.SourceId,
+		entry.RepoOwner,
+		entry.RepoName,
+		entry.PullRequest,
+		entry.OldPoints,
+		entry.NewPoints,
+		bugCounts,
+		scoringMessage,
+		resolvedCategories,
+		sql.NullTime{Time: entry.CreatedOn, Valid: !entry.CreatedOn.IsZero()},
+	).Scan(&entry.Id, &entry.CreatedOn)
+	if err != nil {
+		logger.Error("error inserting ledger entry", zap.Any("entry", entry), zap.Error(err))
+	}
+	return
+}
 
-func (p *BBashDB) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
-	_, err = p.db.Exec(sqlInsertScoringEvent, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints)
+const sqlSelectParticipantLedger = `SELECT
+		score_ledger.Id, campaign.name, source_control_provider.name, login_name, source_id,
+		repoOwner, repoName, pr, old_points, new_points, bug_counts, scoring_message, resolved_categories,
+		score_ledger.created_on
+		FROM score_ledger
+		INNER JOIN campaign ON campaign.Id = score_ledger.fk_campaign
+		INNER JOIN source_control_provider ON source_control_provider.Id = score_ledger.fk_scp
+		WHERE campaign.name = $1
+		  AND source_control_provider.name = $2
+		  AND login_name = $3
+		ORDER BY score_ledger.created_on`
+
+func (p *BBashDB) SelectParticipantLedger(campaignName, scpName, loginName string) (entries []types.LedgerEntry, err error) {
+	var rows *sql.Rows
+	rows, err = p.db.Query(sqlSelectParticipantLedger, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		entry := types.LedgerEntry{}
+		var sourceId sql.NullString
+		var pullRequest sql.NullInt64
+		var bugCounts, scoringMessage, resolvedCategories []byte
+		err = rows.Scan(&entry.Id,
+			&entry.CampaignName,
+			&entry.ScpName,
+			&entry.LoginName,
+			&sourceId,
+			&entry.RepoOwner,
+			&entry.RepoName,
+			&pullRequest,
+			&entry.OldPoints,
+			&entry.NewPoints,
+			&bugCounts,
+			&scoringMessage,
+			&resolvedCategories,
+			&entry.CreatedOn,
+		)
+		if err != nil {
+			return
+		}
+		entry.SourceId = sourceId.String
+		entry.PullRequest = int(pullRequest.Int64)
+		if len(bugCounts) > 0 {
+			if err = json.Unmarshal(bugCounts, &entry.BugCounts); err != nil {
+				return
+			}
+		}
+		if err = json.Unmarshal(scoringMessage, &entry.ScoringMessage); err != nil {
+			return
+		}
+		if len(resolvedCategories) > 0 {
+			if err = json.Unmarshal(resolvedCategories, &entry.ResolvedCategories); err != nil {
+				return
+			}
+		}
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+	return
+}
+
+const sqlSelectScoredPullRequests = `SELECT
+		repoOwner, repoName, pr, points, bug_counts, scored_on
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		  AND username = $3
+		ORDER BY scored_on DESC NULLS LAST, repoOwner, repoName, pr`
+
+// SelectScoredPullRequests reads the pull requests scored by a participant, the latest scored first.
+func (p *BBashDB) SelectScoredPullRequests(campaignName, scpName, loginName string) (pullRequests []types.ScoredPullRequest, err error) {
+	var rows *sql.Rows
+	rows, err = p.db.Query(sqlSelectScoredPullRequests, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	return scanScoredPullRequests(rows)
+}
+
+const sqlSelectCampaignScoredPullRequests = `SELECT
+		repoOwner, repoName, pr, points, bug_counts, scored_on
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		ORDER BY scored_on NULLS FIRST, repoOwner, repoName, pr`
+
+// SelectCampaignScoredPullRequests reads every pull request scored in the campaign, the first scored first.
+func (p *BBashDB) SelectCampaignScoredPullRequests(campaignName string) (pullRequests []types.ScoredPullRequest, err error) {
+	var rows *sql.Rows
+	rows, err = p.db.Query(sqlSelectCampaignScoredPullRequests, campaignName)
+	if err != nil {
+		return
+	}
+	return scanScoredPullRequests(rows)
+}
+
+func scanScoredPullRequests(rows *sql.Rows) (pullRequests []types.ScoredPullRequest, err error) {
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		pullRequest := types.ScoredPullRequest{}
+		var bugCounts []byte
+		var scoredOn sql.NullTime
+		err = rows.Scan(&pullRequest.RepoOwner,
+			&pullRequest.RepoName,
+			&pullRequest.PullRequest,
+			&pullRequest.Points,
+			&bugCounts,
+			&scoredOn,
+		)
+		if err != nil {
+			return
+		}
+		if len(bugCounts) > 0 {
+			if err = json.Unmarshal(bugCounts, &pullRequest.BugCounts); err != nil {
+				return
+			}
+		}
+		if scoredOn.Valid {
+			pullRequest.ScoredOn = &scoredOn.Time
+		}
+		pullRequests = append(pullRequests, pullRequest)
+	}
+	err = rows.Err()
 	return
 }
 
+const sqlSelectCampaignDailyScores = `SELECT
+		to_char(created_on, 'YYYY-MM-DD'), COUNT(DISTINCT (fk_scp, repoOwner, repoName, pr)), SUM(new_points - old_points)
+		FROM score_ledger
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND pr IS NOT NULL
+		  AND new_points <> old_points
+		GROUP BY 1
+		ORDER BY 1`
+
+// SelectCampaignDailyScores sums up the ledger of the campaign by the day (UTC) each entry was scored on: the pull
+// requests whose points changed that day, and by how much. A pull request re-priced by a recompute counts on the day
+// it was scored, and score corrections of a recompute scored no pull request, so are left out. Only days when points
+// changed are read, the first first.
+func (p *BBashDB) SelectCampaignDailyScores(campaignName string) (days []types.DayScore, err error) {
+	var rows *sql.Rows
+	rows, err = p.db.Query(sqlSelectCampaignDailyScores, campaignName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		day := types.DayScore{}
+		if err = rows.Scan(&day.Day, &day.PullRequests, &day.Points); err != nil {
+			return
+		}
+		days = append(days, day)
+	}
+	err = rows.Err()
+	return
+}
+
+// sqlLockScoringEvent serializes scoring of a pull request, even before its scoring_event row exists
+const sqlLockScoringEvent = `SELECT pg_advisory_xact_lock(hashtext($1))`
+
+const sqlScoreQueryForUpdate = sqlScoreQuery + `
+				FOR UPDATE`
+
+type ScoreTx struct {
+	tx     *sql.Tx
+	logger *zap.Logger
+}
+
+var _ IScoreTx = (*ScoreTx)(nil)
+
+func (p *BBashDB) BeginScoreTx() (scoreTx IScoreTx, err error) {
+	var tx *sql.Tx
+	tx, err = p.db.Begin()
+	if err != nil {
+		return
+	}
+	scoreTx = &ScoreTx{tx: tx, logger: p.logger}
+	return
+}
+
+func scoringEventLockKey(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) string {
+	return fmt.Sprintf("scoring_event/%s/%s/%s/%s/%d",
+		participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+}
+
+const sqlInsertProcessedEvent = `INSERT INTO processed_event
+		(source_id, fk_participant)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+// InsertProcessedEvent records the source event as scored for the participant, and returns false if it already was.
+func (s *ScoreTx) InsertProcessedEvent(sourceId string, participant *types.ParticipantStruct) (firstTime bool, err error) {
+	var res sql.Result
+	res, err = s.tx.Exec(sqlInsertProcessedEvent, sourceId, participant.ID)
+	if err != nil {
+		return
+	}
+	var rowsAffected int64
+	rowsAffected, err = res.RowsAffected()
+	firstTime = rowsAffected > 0
+	return
+}
+
+const sqlLockParticipant = `SELECT Id FROM participant WHERE Id = $1 FOR UPDATE`
+
+// SelectScoringHistory locks the participant, then reads what they scored in the ledger up to asOf. Ledger entries are
+// created at the time they were scored, so messages scored together see the points of those scored before them.
+func (s *ScoreTx) SelectScoringHistory(participant *types.ParticipantStruct, msg *types.ScoringMessage, asOf time.Time) (history *types.ScoringHistory, err error) {
+	var participantId string
+	if err = s.tx.QueryRow(sqlLockParticipant, participant.ID).Scan(&participantId); err != nil {
+		return
+	}
+	return selectScoringHistory(s.tx, participant, msg, asOf)
+}
+
+func (s *ScoreTx) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64, err error) {
+	_, err = s.tx.Exec(sqlLockScoringEvent, scoringEventLockKey(participantToScore, msg))
+	if err != nil {
+		return
+	}
+
+	row := s.tx.QueryRow(sqlScoreQueryForUpdate, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	err = row.Scan(&oldPoints)
+	if err == sql.ErrNoRows {
+		// new score event
+		err = nil
+	}
+	return
+}
+
+func (s *ScoreTx) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, scoredOn time.Time) (err error) {
+	return insertScoringEvent(s.tx, participantToScore, msg, newPoints, scoredOn)
+}
+
+func (s *ScoreTx) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
+	return updateParticipantScore(s.tx, participant, delta)
+}
+
+func (s *ScoreTx) InsertLedgerEntry(entry *types.LedgerEntry) (err error) {
+	return insertLedgerEntry(s.tx, s.logger, entry)
+}
+
+func (s *ScoreTx) Commit() (err error) {
+	return s.tx.Commit()
+}
+
+func (s *ScoreTx) Rollback() (err error) {
+	return s.tx.Rollback()
+}
+
 const sqlInsertParticipant = `INSERT INTO participant 
-		(fk_scp, fk_campaign, login_name, Email, DisplayName, Score) 
+		(fk_scp, fk_campaign, login_name, Email, DisplayName, Score, private) 
 		VALUES ((SELECT Id FROM source_control_provider WHERE Name = $1),
 		        (SELECT Id FROM campaign WHERE name = $2),
-		        $3, $4, $5, $6)
+		        $3, $4, $5, $6, $7)
 		RETURNING Id, Score, JoinedAt`
 
 func (p *BBashDB) InsertParticipant(participant *types.ParticipantStruct) (err error) {
@@ -402,6 +773,7 @@ func (p *BBashDB) InsertParticipant(participant *types.ParticipantStruct) (err e
 		participant.Email,
 		participant.DisplayName,
 		0,
+		participant.Private,
 	).Scan(&participant.ID, &participant.Score, &participant.JoinedAt)
 	if err != nil {
 		p.logger.Error("error inserting participant", zap.Any("participant", participant), zap.Error(err))
@@ -422,8 +794,120 @@ func (p *BBashDB) InsertTeam(team *types.TeamStruct) (err error) {
 	return
 }
 
+const sqlCountParticipants = `SELECT COUNT(*),
+		COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM scoring_event
+			WHERE scoring_event.fk_campaign = participant.fk_campaign
+			  AND scoring_event.fk_scp = participant.fk_scp
+			  AND scoring_event.username = participant.login_name))
+		FROM participant
+		WHERE participant.fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+// CountParticipants counts the participants registered in the campaign, and those of them who scored a pull request.
+func (p *BBashDB) CountParticipants(campaignName string) (registered, active int, err error) {
+	err = p.db.QueryRow(sqlCountParticipants, campaignName).Scan(&registered, &active)
+	return
+}
+
+const sqlSelectTeamMemberScores = `SELECT team.Id, team.name, participant.Id, COALESCE(participant.Score, 0)
+		FROM team
+		LEFT JOIN participant ON participant.fk_team = team.Id
+		WHERE team.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		ORDER BY team.name`
+
+// SelectTeamMemberScores reads each team of the campaign with the scores of its members.
+func (p *BBashDB) SelectTeamMemberScores(campaignName string) (teams []types.TeamMemberScores, err error) {
+	rows, err := p.db.Query(sqlSelectTeamMemberScores, campaignName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	teams = []types.TeamMemberScores{}
+	for rows.Next() {
+		team := types.TeamStruct{CampaignName: campaignName}
+		var memberId sql.NullString
+		var memberScore float64
+		if err = rows.Scan(&team.Id, &team.Name, &memberId, &memberScore); err != nil {
+			return
+		}
+		if len(teams) == 0 || teams[len(teams)-1].Team.Id != team.Id {
+			teams = append(teams, types.TeamMemberScores{Team: team})
+		}
+		if memberId.Valid {
+			last := &teams[len(teams)-1]
+			last.MemberScores = append(last.MemberScores, memberScore)
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+// uniqueViolation is the postgres error code of a row that would duplicate a unique key, e.g. the name of a team.
+const uniqueViolation = pq.ErrorCode("23505")
+
+// IsUniqueViolation is true when err is postgres refusing a row that would duplicate a unique key.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+const sqlUpdateTeamName = `UPDATE team
+		SET name = $3
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND name = $2`
+
+// UpdateTeamName renames the team. Renaming it to the name of another team of the campaign fails with an error for
+// which IsUniqueViolation is true.
+func (p *BBashDB) UpdateTeamName(campaignName, teamName, newTeamName string) (rowsAffected int64, err error) {
+	res, err := p.db.Exec(sqlUpdateTeamName, campaignName, teamName, newTeamName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlClearTeamMembers = `UPDATE participant
+		SET fk_team = NULL
+		WHERE fk_team = (SELECT Id FROM team
+		                 WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		                   AND name = $2)`
+
+const sqlDeleteTeam = `DELETE FROM team
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND name = $2`
+
+// DeleteTeam deletes the team, after removing its members from the team, in a single transaction.
+func (p *BBashDB) DeleteTeam(campaignName, teamName string) (rowsAffected int64, err error) {
+	var tx *sql.Tx
+	tx, err = p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec(sqlClearTeamMembers, campaignName, teamName); err != nil {
+		return
+	}
+	var res sql.Result
+	if res, err = tx.Exec(sqlDeleteTeam, campaignName, teamName); err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
 const sqlSelectParticipantDetail = `SELECT 
-		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt
+		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt,
+		participant.private
 		FROM participant
 		LEFT JOIN team ON team.Id = participant.fk_team
 		INNER JOIN campaign ON campaign.Id = participant.fk_campaign
@@ -446,6 +930,7 @@ func (p *BBashDB) SelectParticipantDetail(campaignName, scpName, loginName strin
 		&participant.Score,
 		&nullableTeamName,
 		&participant.JoinedAt,
+		&participant.Private,
 	)
 	if err != nil {
 		p.logger.Error("getParticipantDetail scan error", zap.Error(err))
@@ -457,20 +942,48 @@ func (p *BBashDB) SelectParticipantDetail(campaignName, scpName, loginName strin
 	return
 }
 
+// sqlSelectParticipantsByCampaign ranks every participant of the campaign before filtering, so a participant keeps
+// their rank in a filtered list.
 const sqlSelectParticipantsByCampaign = `SELECT
-		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt 
+		Id, campaign_name, scp_name, login_name, Email, DisplayName, Score, team_name, JoinedAt, private, rank
+		FROM (SELECT participant.Id, campaign.name AS campaign_name, source_control_provider.name AS scp_name,
+		             login_name, Email, DisplayName, Score, team.name AS team_name, JoinedAt, private, score_changed_on,
+		             DENSE_RANK() OVER (ORDER BY Score DESC) AS rank
+		      FROM participant
+		      LEFT JOIN team ON participant.fk_team = team.Id
+		      INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		      INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		      WHERE campaign.name = $1) AS ranked
+		WHERE ($2::TEXT = '' OR team_name = $2)
+		  AND ($3::TEXT = '' OR scp_name = $3)
+		ORDER BY rank, score_changed_on, login_name
+		LIMIT $4 OFFSET $5`
+
+const sqlCountParticipantsByCampaign = `SELECT COUNT(*)
 		FROM participant
 		LEFT JOIN team ON participant.fk_team = team.Id
-		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
 		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
-		WHERE campaign.name = $1
-		ORDER BY score DESC`
+		WHERE participant.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND ($2::TEXT = '' OR team.name = $2)
+		  AND ($3::TEXT = '' OR source_control_provider.name = $3)`
+
+// SelectParticipantsInCampaign reads a page of the participants of the campaign matching the filter, ordered by rank.
+// Participants with the same score share a rank, and are ordered by who reached the score first. total is the number
+// of participants matching the filter, on all pages.
+func (p *BBashDB) SelectParticipantsInCampaign(campaignName string, filter *types.ParticipantFilter) (participants []types.ParticipantStruct, total int, err error) {
+	err = p.db.QueryRow(sqlCountParticipantsByCampaign, campaignName, filter.TeamName, filter.ScpName).Scan(&total)
+	if err != nil {
+		return
+	}
 
-func (p *BBashDB) SelectParticipantsInCampaign(campaignName string) (participants []types.ParticipantStruct, err error) {
-	rows, err := p.db.Query(sqlSelectParticipantsByCampaign, campaignName)
+	var rows *sql.Rows
+	rows, err = p.db.Query(sqlSelectParticipantsByCampaign, campaignName, filter.TeamName, filter.ScpName, filter.Limit, filter.Offset)
 	if err != nil {
 		return
 	}
+	defer func() {
+		_ = rows.Close()
+	}()
 
 	for rows.Next() {
 		participant := new(types.ParticipantStruct)
@@ -485,6 +998,8 @@ func (p *BBashDB) SelectParticipantsInCampaign(campaignName string) (participant
 			&participant.Score,
 			&nullableTeamName,
 			&participant.JoinedAt,
+			&participant.Private,
+			&participant.Rank,
 		)
 		if err != nil {
 			return
@@ -494,6 +1009,7 @@ func (p *BBashDB) SelectParticipantsInCampaign(campaignName string) (participant
 		}
 		participants = append(participants, *participant)
 	}
+	err = rows.Err()
 	return
 }
 
@@ -505,8 +1021,10 @@ const sqlUpdateParticipant = `UPDATE participant
 		    Email = $4,
 		    DisplayName = $5,
 		    Score = $6,
-		    fk_team = (SELECT Id FROM team WHERE name = $7)		    
-		WHERE Id = $8`
+		    score_changed_on = CASE WHEN Score = $6 THEN score_changed_on ELSE NOW() END,
+		    fk_team = (SELECT Id FROM team WHERE name = $7 AND fk_campaign = (SELECT Id FROM campaign WHERE name = $1)),
+		    private = $8
+		WHERE Id = $9`
 
 func (p *BBashDB) UpdateParticipant(participant *types.ParticipantStruct) (rowsAffected int64, err error) {
 	res, err := p.db.Exec(
@@ -518,6 +1036,7 @@ func (p *BBashDB) UpdateParticipant(participant *types.ParticipantStruct) (rowsA
 		participant.DisplayName,
 		participant.Score,
 		participant.TeamName,
+		participant.Private,
 		participant.ID,
 	)
 	if err != nil {
@@ -545,7 +1064,7 @@ func (p *BBashDB) DeleteParticipant(campaign, scpName, loginName string) (partic
 }
 
 const sqlUpdateParticipantTeam = `UPDATE participant 
-		SET fk_team = (SELECT Id FROM team WHERE name = $1)
+		SET fk_team = (SELECT Id FROM team WHERE name = $1 AND fk_campaign = participant.fk_campaign)
 		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $2)
 		 AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $3)
 		 AND login_name = $4`
@@ -567,6 +1086,23 @@ func (p *BBashDB) UpdateParticipantTeam(teamName, campaignName, scpName, loginNa
 	return
 }
 
+const sqlDeleteParticipantTeam = `UPDATE participant
+		SET fk_team = NULL
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		 AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		 AND login_name = $3
+		 AND fk_team IS NOT NULL`
+
+// DeleteParticipantTeam removes the participant from their team.
+func (p *BBashDB) DeleteParticipantTeam(campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	res, err := p.db.Exec(sqlDeleteParticipantTeam, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
 const sqlInsertBug = `INSERT INTO bug
 		(fk_campaign, category, pointValue)
 		VALUES ((SELECT id FROM campaign WHERE name = $1), $2, $3)
@@ -578,6 +1114,7 @@ func (p *BBashDB) InsertBug(bug *types.BugStruct) (err error) {
 		p.logger.Error("error inserting bug", zap.Any("bug", bug), zap.Error(err))
 		return
 	}
+	p.pointValues.invalidate(bug.Campaign)
 	return
 }
 
@@ -590,6 +1127,7 @@ func (p *BBashDB) UpdateBug(bug *types.BugStruct) (rowsAffected int64, err error
 	if err != nil {
 		return
 	}
+	p.pointValues.invalidate(bug.Campaign)
 	rowsAffected, err = res.RowsAffected()
 	return
 }
@@ -613,3 +1151,460 @@ func (p *BBashDB) SelectBugs() (bugs []types.BugStruct, err error) {
 	}
 	return
 }
+
+const sqlSelectScoringRules = `SELECT rules FROM scoring_rule
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+// SelectScoringRules reads the scoring rules of the campaign over the given rules, so the given rules are left as they
+// are for a campaign without rules of its own, and fields missing from the stored rules keep their given value.
+func (p *BBashDB) SelectScoringRules(campaignName string, rules *types.ScoringRules) (err error) {
+	var rulesJson []byte
+	err = p.db.QueryRow(sqlSelectScoringRules, campaignName).Scan(&rulesJson)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(rulesJson, rules)
+	return
+}
+
+const sqlUpsertScoringRules = `INSERT INTO scoring_rule (fk_campaign, rules)
+		SELECT id, $2 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO UPDATE SET rules = EXCLUDED.rules, updated_on = NOW()`
+
+// UpsertScoringRules replaces the scoring rules of the campaign. No rows are affected when the campaign does not exist.
+func (p *BBashDB) UpsertScoringRules(campaignName string, rules *types.ScoringRules) (rowsAffected int64, err error) {
+	var rulesJson []byte
+	if rulesJson, err = json.Marshal(rules); err != nil {
+		return
+	}
+	var res sql.Result
+	res, err = p.db.Exec(sqlUpsertScoringRules, campaignName, rulesJson)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlSelectScoringHistory = `SELECT bug_counts, new_points - old_points, created_on
+		FROM score_ledger
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		  AND login_name = $3
+		  AND pr IS NOT NULL
+		  AND (repoOwner, repoName, pr) IS DISTINCT FROM ($4, $5, $6)
+		  AND created_on <= $7`
+
+// selectScoringHistory reads what the participant scored in the ledger up to asOf, in pull requests other than the
+// one of the message. Score corrections of a campaign recompute scored no pull request, so are left out.
+func selectScoringHistory(runner sqlRunner, participant *types.ParticipantStruct, msg *types.ScoringMessage, asOf time.Time) (history *types.ScoringHistory, err error) {
+	var rows *sql.Rows
+	rows, err = runner.Query(sqlSelectScoringHistory,
+		participant.CampaignName, participant.ScpName, participant.LoginName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest, asOf)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	startOfDay := asOf.UTC().Truncate(24 * time.Hour)
+	history = &types.ScoringHistory{}
+	for rows.Next() {
+		var bugCountsJson []byte
+		var delta float64
+		var createdOn time.Time
+		if err = rows.Scan(&bugCountsJson, &delta, &createdOn); err != nil {
+			return
+		}
+		if !createdOn.Before(startOfDay) {
+			history.PointsToday += delta
+		}
+		if len(bugCountsJson) > 0 {
+			var bugCounts map[string]interface{}
+			if err = json.Unmarshal(bugCountsJson, &bugCounts); err != nil {
+				return
+			}
+			history.BugCounts = append(history.BugCounts, bugCounts)
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+const sqlDeleteProcessedEvents = `DELETE FROM processed_event WHERE processed_on < $1`
+
+// DeleteProcessedEvents removes processed event ids older than the retention period. Once removed, an event would be
+// scored again if it was re-read, so the retention period must be longer than any poll rewind.
+func (p *BBashDB) DeleteProcessedEvents(processedBefore time.Time) (rowsAffected int64, err error) {
+	var res sql.Result
+	res, err = p.db.Exec(sqlDeleteProcessedEvents, processedBefore)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlInsertDeadLetter = `INSERT INTO dead_letter
+		(source_id, payload, error)
+		VALUES (NULLIF($1, ''), $2, $3)
+		ON CONFLICT (source_id) DO
+			UPDATE SET payload = EXCLUDED.payload,
+			           error = EXCLUDED.error,
+			           attempts = dead_letter.attempts + 1,
+			           last_attempt_on = NOW()
+		RETURNING Id, attempts, created_on, last_attempt_on`
+
+// InsertDeadLetter stores the failed event. A failed event that is read again (e.g. by an overlapping poll) updates the
+// existing dead letter for its source id, and counts another attempt.
+func (p *BBashDB) InsertDeadLetter(deadLetter *types.DeadLetter) (err error) {
+	err = p.db.QueryRow(sqlInsertDeadLetter, deadLetter.SourceId, deadLetter.Payload, deadLetter.Error).
+		Scan(&deadLetter.Id, &deadLetter.Attempts, &deadLetter.CreatedOn, &deadLetter.LastAttemptOn)
+	if err != nil {
+		p.logger.Error("error inserting dead letter", zap.Any("deadLetter", deadLetter), zap.Error(err))
+	}
+	return
+}
+
+const sqlSelectDeadLetterColumns = `SELECT Id, source_id, payload, error, attempts, created_on, last_attempt_on
+		FROM dead_letter`
+
+const sqlSelectDeadLetters = sqlSelectDeadLetterColumns + `
+		ORDER BY created_on`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (deadLetter *types.DeadLetter, err error) {
+	deadLetter = new(types.DeadLetter)
+	var sourceId sql.NullString
+	err = row.Scan(&deadLetter.Id,
+		&sourceId,
+		&deadLetter.Payload,
+		&deadLetter.Error,
+		&deadLetter.Attempts,
+		&deadLetter.CreatedOn,
+		&deadLetter.LastAttemptOn,
+	)
+	deadLetter.SourceId = sourceId.String
+	return
+}
+
+func (p *BBashDB) SelectDeadLetters() (deadLetters []types.DeadLetter, err error) {
+	var rows *sql.Rows
+	rows, err = p.db.Query(sqlSelectDeadLetters)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var deadLetter *types.DeadLetter
+		if deadLetter, err = scanDeadLetter(rows); err != nil {
+			return
+		}
+		deadLetters = append(deadLetters, *deadLetter)
+	}
+	err = rows.Err()
+	return
+}
+
+const sqlSelectDeadLetter = sqlSelectDeadLetterColumns + `
+		WHERE Id = $1`
+
+func (p *BBashDB) SelectDeadLetter(id string) (deadLetter *types.DeadLetter, err error) {
+	return scanDeadLetter(p.db.QueryRow(sqlSelectDeadLetter, id))
+}
+
+const sqlUpdateDeadLetterAttempt = `UPDATE dead_letter
+		SET error = $2,
+		    attempts = attempts + 1,
+		    last_attempt_on = NOW()
+		WHERE Id = $1`
+
+func (p *BBashDB) UpdateDeadLetterAttempt(id, attemptErr string) (rowsAffected int64, err error) {
+	var res sql.Result
+	res, err = p.db.Exec(sqlUpdateDeadLetterAttempt, id, attemptErr)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlDeleteDeadLetter = `DELETE FROM dead_letter WHERE Id = $1`
+
+func (p *BBashDB) DeleteDeadLetter(id string) (rowsAffected int64, err error) {
+	var res sql.Result
+	res, err = p.db.Exec(sqlDeleteDeadLetter, id)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlSelectCampaignScoresForUpdate = `SELECT participant.Id, source_control_provider.name, login_name, private, Score
+		FROM participant
+		INNER JOIN source_control_provider ON source_control_provider.Id = participant.fk_scp
+		WHERE participant.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		ORDER BY participant.Id
+		FOR UPDATE OF participant`
+
+const sqlSelectCampaignScoringEventsForUpdate = `SELECT
+		source_control_provider.name, scoring_event.repoOwner, scoring_event.repoName, scoring_event.pr,
+		scoring_event.username, scoring_event.points, latest.scoring_message, latest.created_on
+		FROM scoring_event
+		INNER JOIN source_control_provider ON source_control_provider.Id = scoring_event.fk_scp
+		LEFT JOIN LATERAL (SELECT scoring_message, created_on FROM score_ledger
+			WHERE score_ledger.fk_campaign = scoring_event.fk_campaign
+			  AND score_ledger.fk_scp = scoring_event.fk_scp
+			  AND score_ledger.repoOwner = scoring_event.repoOwner
+			  AND score_ledger.repoName = scoring_event.repoName
+			  AND score_ledger.pr = scoring_event.pr
+			ORDER BY score_ledger.created_on DESC
+			LIMIT 1) latest ON true
+		WHERE scoring_event.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		ORDER BY scoring_event.fk_scp, scoring_event.repoOwner, scoring_event.repoName, scoring_event.pr
+		FOR UPDATE OF scoring_event`
+
+const sqlUpdateScoringEventPoints = `UPDATE scoring_event
+		SET points = $6
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		  AND repoOwner = $3
+		  AND repoName = $4
+		  AND pr = $5`
+
+const sqlUpdateCampaignScoresFromEvents = `UPDATE participant
+		SET (Score, score_changed_on) = (SELECT totals.score,
+				CASE WHEN totals.score = participant.Score THEN participant.score_changed_on ELSE NOW() END
+			FROM (SELECT COALESCE(SUM(points), 0) AS score FROM scoring_event
+				WHERE scoring_event.fk_campaign = participant.fk_campaign
+				  AND scoring_event.fk_scp = participant.fk_scp
+				  AND scoring_event.username = participant.login_name) AS totals)
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		RETURNING Id, Score`
+
+type recomputeEvent struct {
+	ledger    types.LedgerEntry
+	hasLedger bool
+	scoredOn  sql.NullTime
+}
+
+// recomputeParticipant identifies a participant of the campaign being recomputed
+type recomputeParticipant struct {
+	scpName   string
+	loginName string
+}
+
+// RecomputeCampaignScores rebuilds every participant score in the campaign from the stored scoring events, in a
+// single transaction. When reprice is not nil, each scoring event is first re-priced from the latest scoring message
+// in the ledger for its pull request, given the participant's scoring history as of the time that message was scored.
+// Events are re-priced in the order they were scored, and each re-priced event is recorded in the ledger as of its
+// scoring time, so the history of a later event includes it, and today's points of a participant only change by the
+// events scored today. Events scored before the ledger existed keep their stored points. Any other change to a
+// participant score, e.g. from fixing drift, is recorded in the ledger as a correction with no pull request. Like a
+// ScoreTx, participants are locked before scoring events, so recomputing never deadlocks with scoring.
+func (p *BBashDB) RecomputeCampaignScores(campaignName string,
+	reprice func(entry *types.LedgerEntry, scoredOn time.Time, history *types.ScoringHistory) (points float64, err error)) (report *types.RecomputeReport, err error) {
+
+	var tx *sql.Tx
+	tx, err = p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	type priorScore struct {
+		scpName   string
+		loginName string
+		private   bool
+		score     float64
+	}
+	priorScores := map[string]priorScore{}
+	var participantIds []string
+	var rows *sql.Rows
+	rows, err = tx.Query(sqlSelectCampaignScoresForUpdate, campaignName)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var id string
+		prior := priorScore{}
+		if err = rows.Scan(&id, &prior.scpName, &prior.loginName, &prior.private, &prior.score); err != nil {
+			_ = rows.Close()
+			return
+		}
+		priorScores[id] = prior
+		participantIds = append(participantIds, id)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	report = &types.RecomputeReport{CampaignName: campaignName}
+
+	repriced := map[recomputeParticipant]float64{}
+	if reprice != nil {
+		if report.RepricedEvents, repriced, err = p.repriceScoringEvents(tx, campaignName, reprice); err != nil {
+			return
+		}
+	}
+
+	newScores := map[string]float64{}
+	rows, err = tx.Query(sqlUpdateCampaignScoresFromEvents, campaignName)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var id string
+		var score float64
+		if err = rows.Scan(&id, &score); err != nil {
+			_ = rows.Close()
+			return
+		}
+		newScores[id] = score
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	for _, id := range participantIds {
+		prior := priorScores[id]
+		newScore, updated := newScores[id]
+		if !updated || newScore == prior.score {
+			continue
+		}
+		report.Changes = append(report.Changes, types.ScoreChange{
+			ScpName:   prior.scpName,
+			LoginName: prior.loginName,
+			Private:   prior.private,
+			OldScore:  prior.score,
+			NewScore:  newScore,
+		})
+
+		// the ledger already explains the points of re-priced events, so only the rest of the change is corrected
+		explained := roundCents(prior.score + repriced[recomputeParticipant{scpName: prior.scpName, loginName: prior.loginName}])
+		if explained == newScore {
+			continue
+		}
+		correction := types.LedgerEntry{
+			CampaignName: campaignName,
+			ScpName:      prior.scpName,
+			LoginName:    prior.loginName,
+			SourceId:     types.LedgerSourceRecompute,
+			OldPoints:    explained,
+			NewPoints:    newScore,
+		}
+		if err = insertLedgerEntry(tx, p.logger, &correction); err != nil {
+			return
+		}
+	}
+
+	p.logger.Info("recomputed campaign scores",
+		zap.String("campaignName", campaignName), zap.Int("repricedEvents", report.RepricedEvents), zap.Int("changes", len(report.Changes)))
+	return
+}
+
+// roundCents rounds points to the two decimal places they are stored with
+func roundCents(points float64) float64 {
+	return math.Round(points*100) / 100
+}
+
+// repriceScoringEvents re-prices the scoring events of the campaign, and sums up how many points each participant
+// gained (or lost) by it.
+func (p *BBashDB) repriceScoringEvents(tx *sql.Tx, campaignName string,
+	reprice func(entry *types.LedgerEntry, scoredOn time.Time, history *types.ScoringHistory) (points float64, err error)) (repricedCount int, repriced map[recomputeParticipant]float64, err error) {
+
+	// read all events before updating any, since the transaction can only run one statement at a time
+	var events []recomputeEvent
+	var rows *sql.Rows
+	rows, err = tx.Query(sqlSelectCampaignScoringEventsForUpdate, campaignName)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		event := recomputeEvent{ledger: types.LedgerEntry{CampaignName: campaignName, SourceId: types.LedgerSourceRecompute}}
+		var scoringMessage []byte
+		err = rows.Scan(&event.ledger.ScpName,
+			&event.ledger.RepoOwner,
+			&event.ledger.RepoName,
+			&event.ledger.PullRequest,
+			&event.ledger.LoginName,
+			&event.ledger.OldPoints,
+			&scoringMessage,
+			&event.scoredOn,
+		)
+		if err != nil {
+			_ = rows.Close()
+			return
+		}
+		if len(scoringMessage) > 0 {
+			if err = json.Unmarshal(scoringMessage, &event.ledger.ScoringMessage); err != nil {
+				_ = rows.Close()
+				return
+			}
+			event.hasLedger = true
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	// events were locked in key order, but are re-priced in the order they were scored, as their history builds up
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].scoredOn.Time.Before(events[j].scoredOn.Time)
+	})
+
+	repriced = map[recomputeParticipant]float64{}
+	for _, event := range events {
+		if !event.hasLedger {
+			continue
+		}
+		entry := event.ledger
+		entry.CreatedOn = event.scoredOn.Time
+		participant := &types.ParticipantStruct{CampaignName: campaignName, ScpName: entry.ScpName, LoginName: entry.LoginName}
+		pullRequest := &types.ScoringMessage{RepoOwner: entry.RepoOwner, RepoName: entry.RepoName, PullRequest: entry.PullRequest}
+		var history *types.ScoringHistory
+		if history, err = selectScoringHistory(tx, participant, pullRequest, event.scoredOn.Time); err != nil {
+			return
+		}
+		if entry.NewPoints, err = reprice(&entry, event.scoredOn.Time, history); err != nil {
+			return
+		}
+		if entry.NewPoints == entry.OldPoints {
+			continue
+		}
+		entry.BugCounts = entry.ScoringMessage.BugCounts
+
+		_, err = tx.Exec(sqlUpdateScoringEventPoints,
+			campaignName, entry.ScpName, entry.RepoOwner, entry.RepoName, entry.PullRequest, entry.NewPoints)
+		if err != nil {
+			return
+		}
+		if err = insertLedgerEntry(tx, p.logger, &entry); err != nil {
+			return
+		}
+		repricedCount++
+		repriced[recomputeParticipant{scpName: entry.ScpName, loginName: entry.LoginName}] += entry.NewPoints - entry.OldPoints
+	}
+	return
+}
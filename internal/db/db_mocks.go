@@ -20,7 +20,9 @@
 package db
 
 import (
+	"encoding/json"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zaptest"
 	"regexp"
@@ -63,3 +65,37 @@ func convertSqlToDbMockExpect(realSql string) string {
 // TestEventSourceValid EventSource is lower case to match case sent by loggly
 const TestEventSourceValid = "github"
 const TestOrgValid = "myValidTestOrganization"
+
+// SetupMockScoreTxBeginAndPriorScore expects a score transaction to begin, lock the participant and read their (empty)
+// scoring history, then lock the scoring event and read its prior points.
+func SetupMockScoreTxBeginAndPriorScore(mock sqlmock.Sqlmock, participant *types.ParticipantStruct, msg *types.ScoringMessage, oldPoints float64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlLockParticipant)).
+		WithArgs(participant.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow(participant.ID))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(participant.CampaignName, participant.ScpName, participant.LoginName, msg.RepoOwner, msg.RepoName, msg.PullRequest, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"bug_counts", "delta", "created_on"}))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlLockScoringEvent)).
+		WithArgs(scoringEventLockKey(participant, msg)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlScoreQueryForUpdate)).
+		WithArgs(participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest).
+		WillReturnRows(sqlmock.NewRows([]string{"points"}).AddRow(oldPoints))
+}
+
+// SetupMockScoreTxUpdateScoreForcedError expects a score transaction that fails to update the participant score, after
+// the scoring event was written, and so must be rolled back.
+func SetupMockScoreTxUpdateScoreForcedError(mock sqlmock.Sqlmock, participant *types.ParticipantStruct, msg *types.ScoringMessage,
+	oldPoints, newPoints float64, forcedError error) {
+
+	SetupMockScoreTxBeginAndPriorScore(mock, participant, msg, oldPoints)
+	bugCounts, _ := json.Marshal(msg.BugCounts)
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
+		WithArgs(participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints, bugCounts, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateParticipantScore)).
+		WithArgs(newPoints-oldPoints, participant.ID).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+}
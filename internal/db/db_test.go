@@ -22,10 +22,13 @@ package db
 import (
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 	"time"
 )
@@ -112,7 +115,7 @@ func TestGetSourceControlProviders(t *testing.T) {
 	scps, err := db.GetSourceControlProviders()
 	assert.NoError(t, err)
 	assert.Equal(t, []types.SourceControlProviderStruct{
-		{"someId", "someSCP", "someUrl"},
+		{ID: "someId", SCPName: "someSCP", Url: "someUrl"},
 	}, scps)
 }
 
@@ -491,7 +494,7 @@ func TestSelectParticipantsToScoreScanError(t *testing.T) {
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
 
 	participantsToScore, err := db.SelectParticipantsToScore(msg, now)
-	assert.EqualError(t, err, "sql: expected 1 destination arguments in Scan, not 5")
+	assert.EqualError(t, err, "sql: expected 1 destination arguments in Scan, not 6")
 	assert.Nil(t, participantsToScore)
 }
 
@@ -501,15 +504,16 @@ func TestSelectParticipantsToScoreValidTeam(t *testing.T) {
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
 		WithArgs(now, TestEventSourceValid, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"Id", "CampaignName", "SCPName", "loginName", "teamName"}).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "CampaignName", "SCPName", "loginName", "teamName", "private"}).
 			// force scan error due to type mismatch at ID column
-			AddRow(now, "someCampaign", "someSCP", "someLoginName", "someTeamName"))
+			AddRow(now, "someCampaign", "someSCP", "someLoginName", "someTeamName", true))
 
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
 
 	participantsToScore, err := db.SelectParticipantsToScore(msg, now)
 	assert.NoError(t, err)
 	assert.Equal(t, "someTeamName", participantsToScore[0].TeamName)
+	assert.True(t, participantsToScore[0].Private)
 }
 
 func TestSelectParticipantsToScoreNoTeam(t *testing.T) {
@@ -518,9 +522,9 @@ func TestSelectParticipantsToScoreNoTeam(t *testing.T) {
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
 		WithArgs(now, TestEventSourceValid, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"Id", "CampaignName", "SCPName", "loginName", "teamName"}).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "CampaignName", "SCPName", "loginName", "teamName", "private"}).
 			// force scan error due to type mismatch at ID column
-			AddRow(now, "someCampaign", "someSCP", "someLoginName", nil))
+			AddRow(now, "someCampaign", "someSCP", "someLoginName", nil, false))
 
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
 
@@ -529,33 +533,134 @@ func TestSelectParticipantsToScoreNoTeam(t *testing.T) {
 	assert.Equal(t, "", participantsToScore[0].TeamName)
 }
 
-func TestSelectPointValueScanError(t *testing.T) {
+func TestSelectPointValuesError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced point value error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValue)).
-		WithArgs(testCampaign.Name, testBugType).
+	forcedError := fmt.Errorf("forced point values error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValues)).
+		WithArgs(testCampaign.Name).
 		WillReturnError(forcedError)
 
-	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+	pointValues, err := db.SelectPointValues(testCampaign.Name)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, pointValues)
+}
+
+func TestSelectPointValuesScanError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValues)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "pointValue"}).AddRow(testBugType, "bogus"))
+
+	_, err := db.SelectPointValues(testCampaign.Name)
+	assert.Error(t, err)
 
-	participantsToScore := db.SelectPointValue(msg, testCampaign.Name, testBugType)
-	assert.Equal(t, float64(1), participantsToScore)
+	// a failed read is not cached
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValues)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "pointValue"}).AddRow(testBugType, 5))
+	pointValues, err := db.SelectPointValues(testCampaign.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{testBugType: 5}, pointValues)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestSelectPointValueRead(t *testing.T) {
+func TestSelectPointValuesIsCached(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValue)).
-		WithArgs(testCampaign.Name, testBugType).
-		WillReturnRows(sqlmock.NewRows([]string{"points"}).AddRow(5))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValues)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "pointValue"}).
+			AddRow(testBugType, 5).
+			AddRow("ErrorProne.NullAway", []byte("0.50")))
 
-	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+	for i := 0; i < 3; i++ {
+		pointValues, err := db.SelectPointValues(testCampaign.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]float64{testBugType: 5, "ErrorProne.NullAway": 0.5}, pointValues)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectPointValuesNoBugs(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValues)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "pointValue"}))
+
+	pointValues, err := db.SelectPointValues(testCampaign.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{}, pointValues)
+}
+
+func expectSelectPointValues(mock sqlmock.Sqlmock, pointValue int) {
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValues)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "pointValue"}).AddRow(bugCategory, pointValue))
+}
+
+func TestSelectPointValuesInvalidatedByInsertBug(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	expectSelectPointValues(mock, 2)
+	_, err := db.SelectPointValues(campaignName)
+	assert.NoError(t, err)
+
+	bug := types.BugStruct{Campaign: campaignName, Category: bugCategory, PointValue: 3}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
+		WithArgs(bug.Campaign, bug.Category, bug.PointValue).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(bugGuid))
+	assert.NoError(t, db.InsertBug(&bug))
 
-	participantsToScore := db.SelectPointValue(msg, testCampaign.Name, testBugType)
-	assert.Equal(t, float64(5), participantsToScore)
+	expectSelectPointValues(mock, 3)
+	pointValues, err := db.SelectPointValues(campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{bugCategory: 3}, pointValues)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectPointValuesInvalidatedByUpdateBug(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	expectSelectPointValues(mock, 2)
+	_, err := db.SelectPointValues(campaignName)
+	assert.NoError(t, err)
+
+	bug := types.BugStruct{Campaign: campaignName, Category: bugCategory, PointValue: 5}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateBug)).
+		WithArgs(bug.PointValue, bug.Campaign, bug.Category).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = db.UpdateBug(&bug)
+	assert.NoError(t, err)
+
+	expectSelectPointValues(mock, 5)
+	pointValues, err := db.SelectPointValues(campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{bugCategory: 5}, pointValues)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectPointValuesExpire(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+	db.pointValues = newPointValueCache(0)
+
+	expectSelectPointValues(mock, 2)
+	expectSelectPointValues(mock, 4)
+	_, err := db.SelectPointValues(campaignName)
+	assert.NoError(t, err)
+	pointValues, err := db.SelectPointValues(campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{bugCategory: 4}, pointValues)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 const testParticipantGuid = "testParticipantGuid"
@@ -569,7 +674,7 @@ func TestUpdateParticipantScoreError(t *testing.T) {
 		WithArgs(float64(0), testParticipantGuid).
 		WillReturnError(forcedError)
 
-	err := db.UpdateParticipantScore(&types.ParticipantStruct{ID: testParticipantGuid}, 0)
+	err := updateParticipantScore(db.db, &types.ParticipantStruct{ID: testParticipantGuid}, 0)
 	assert.EqualError(t, err, forcedError.Error())
 }
 
@@ -581,10 +686,12 @@ func TestUpdateParticipantScoreZero(t *testing.T) {
 		WithArgs(float64(0), testParticipantGuid).
 		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(3))
 
-	assert.NoError(t, db.UpdateParticipantScore(&types.ParticipantStruct{ID: testParticipantGuid}, 0))
+	participant := &types.ParticipantStruct{ID: testParticipantGuid}
+	assert.NoError(t, updateParticipantScore(db.db, participant, 0))
+	assert.Equal(t, float64(3), participant.Score)
 }
 
-func TestSelectPriorScoreError(t *testing.T) {
+func TestInsertScoringEventError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
@@ -596,16 +703,17 @@ func TestSelectPriorScoreError(t *testing.T) {
 
 	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: -1}
 
-	forcedError := fmt.Errorf("forced prior score error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlScoreQuery)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest).
+	const newPoints = float64(11)
+
+	forcedError := fmt.Errorf("forced insert score error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints, []byte("null"), now).
 		WillReturnError(forcedError)
 
-	oldPoints := db.SelectPriorScore(testParticipant, msg)
-	assert.Equal(t, float64(0), oldPoints)
+	assert.EqualError(t, insertScoringEvent(db.db, testParticipant, msg, newPoints, now), forcedError.Error())
 }
 
-func TestSelectPriorScore(t *testing.T) {
+func TestInsertScoringEvent(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
@@ -615,57 +723,311 @@ func TestSelectPriorScore(t *testing.T) {
 		ScpName:      "scpName",
 	}
 
-	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: -1}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: -1,
+		BugCounts: map[string]interface{}{"bugType": 2}}
+
+	const newPoints = float64(11)
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlScoreQuery)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest).
-		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(-2))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints,
+			[]byte(`{"bugType":2}`), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	oldPoints := db.SelectPriorScore(testParticipant, msg)
-	assert.Equal(t, float64(-2), oldPoints)
+	assert.NoError(t, insertScoringEvent(db.db, testParticipant, msg, newPoints, now))
+}
+
+func setupTestLedgerEntry() *types.LedgerEntry {
+	return &types.LedgerEntry{
+		CampaignName:       campaignName,
+		ScpName:            scpName,
+		LoginName:          loginName,
+		SourceId:           "mySourceId",
+		RepoOwner:          TestOrgValid,
+		RepoName:           "testRepoName",
+		PullRequest:        3,
+		OldPoints:          1,
+		NewPoints:          4,
+		BugCounts:          map[string]interface{}{testBugType: float64(2)},
+		ScoringMessage:     types.ScoringMessage{TriggerUser: loginName, TotalFixed: 2},
+		ResolvedCategories: map[string]string{testBugType: testBugType},
+	}
 }
 
-func TestInsertScoringEventError(t *testing.T) {
+func TestInsertLedgerEntryError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testParticipant := &types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: testCampaign.Name,
-		ScpName:      "scpName",
-	}
+	entry := setupTestLedgerEntry()
+	forcedError := fmt.Errorf("forced insert ledger error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, entry.SourceId, entry.RepoOwner, entry.RepoName, entry.PullRequest,
+			entry.OldPoints, entry.NewPoints, []byte(`{"testBugType":2}`), sqlmock.AnyArg(),
+			[]byte(`{"testBugType":"testBugType"}`), nil).
+		WillReturnError(forcedError)
 
-	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: -1}
+	assert.EqualError(t, insertLedgerEntry(db.db, db.logger, entry), forcedError.Error())
+}
 
-	const newPoints = float64(11)
+func TestInsertLedgerEntry(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced insert score error")
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints).
+	entry := setupTestLedgerEntry()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, entry.SourceId, entry.RepoOwner, entry.RepoName, entry.PullRequest,
+			entry.OldPoints, entry.NewPoints, []byte(`{"testBugType":2}`), sqlmock.AnyArg(),
+			[]byte(`{"testBugType":"testBugType"}`), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId", now))
+
+	assert.NoError(t, insertLedgerEntry(db.db, db.logger, entry))
+	assert.Equal(t, "ledgerId", entry.Id)
+	assert.Equal(t, now, entry.CreatedOn)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertLedgerEntryScoredAt(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	scoredAt := now.Add(-time.Hour)
+	entry := setupTestLedgerEntry()
+	entry.CreatedOn = scoredAt
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, entry.SourceId, entry.RepoOwner, entry.RepoName, entry.PullRequest,
+			entry.OldPoints, entry.NewPoints, []byte(`{"testBugType":2}`), sqlmock.AnyArg(),
+			[]byte(`{"testBugType":"testBugType"}`), scoredAt).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId", scoredAt))
+
+	assert.NoError(t, insertLedgerEntry(db.db, db.logger, entry))
+	assert.Equal(t, scoredAt, entry.CreatedOn)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+var ledgerColumns = []string{"Id", "campaign", "scp", "login_name", "source_id", "repoOwner", "repoName", "pr",
+	"old_points", "new_points", "bug_counts", "scoring_message", "resolved_categories", "created_on"}
+
+func TestSelectParticipantLedgerError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select ledger error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantLedger)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnError(forcedError)
+
+	entries, err := db.SelectParticipantLedger(campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, entries)
+}
+
+func TestSelectParticipantLedgerInvalidMessage(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantLedger)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows(ledgerColumns).
+			AddRow("ledgerId", campaignName, scpName, loginName, nil, TestOrgValid, "testRepoName", 3, 0, 1, nil, []byte("{bogus"), nil, now))
+
+	entries, err := db.SelectParticipantLedger(campaignName, scpName, loginName)
+	assert.EqualError(t, err, "invalid character 'b' looking for beginning of object key string")
+	assert.Nil(t, entries)
+}
+
+func TestSelectParticipantLedger(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantLedger)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows(ledgerColumns).
+			AddRow("ledgerId1", campaignName, scpName, loginName, nil, TestOrgValid, "testRepoName", 3, 0, 1,
+				nil, []byte(`{"triggerUser": "loginName", "fixed-bugs": 1}`), nil, now).
+			AddRow("ledgerId2", campaignName, scpName, loginName, "mySourceId", TestOrgValid, "testRepoName", 3, 1, 4,
+				[]byte(`{"testBugType": 2}`), []byte(`{"triggerUser": "loginName", "fixed-bugs": 2}`),
+				[]byte(`{"testBugType": "testBugType"}`), now).
+			AddRow("ledgerId3", campaignName, scpName, loginName, types.LedgerSourceRecompute, "", "", nil, 4, 5,
+				nil, []byte(`{}`), nil, now))
+
+	entries, err := db.SelectParticipantLedger(campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "", entries[0].SourceId)
+	assert.Nil(t, entries[0].BugCounts)
+	assert.Nil(t, entries[0].ResolvedCategories)
+	assert.Equal(t, types.LedgerEntry{
+		Id:                 "ledgerId2",
+		CampaignName:       campaignName,
+		ScpName:            scpName,
+		LoginName:          loginName,
+		SourceId:           "mySourceId",
+		RepoOwner:          TestOrgValid,
+		RepoName:           "testRepoName",
+		PullRequest:        3,
+		OldPoints:          1,
+		NewPoints:          4,
+		BugCounts:          map[string]interface{}{testBugType: float64(2)},
+		ScoringMessage:     types.ScoringMessage{TriggerUser: loginName, TotalFixed: 2},
+		ResolvedCategories: map[string]string{testBugType: testBugType},
+		CreatedOn:          now,
+	}, entries[1])
+	assert.Equal(t, 0, entries[2].PullRequest)
+	assert.Equal(t, float64(5), entries[2].NewPoints)
+}
+
+var scoredPullRequestColumns = []string{"repoOwner", "repoName", "pr", "points", "bug_counts", "scored_on"}
+
+func TestSelectScoredPullRequestsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select scored pull requests error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoredPullRequests)).
+		WithArgs(campaignName, scpName, loginName).
 		WillReturnError(forcedError)
 
-	assert.EqualError(t, db.InsertScoringEvent(testParticipant, msg, newPoints), forcedError.Error())
+	pullRequests, err := db.SelectScoredPullRequests(campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, pullRequests)
 }
 
-func TestInsertScoringEvent(t *testing.T) {
+func TestSelectScoredPullRequestsInvalidBugCounts(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testParticipant := &types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: testCampaign.Name,
-		ScpName:      "scpName",
-	}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoredPullRequests)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows(scoredPullRequestColumns).
+			AddRow(TestOrgValid, "testRepoName", 3, 1, []byte("{bogus"), now))
 
-	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: -1}
+	pullRequests, err := db.SelectScoredPullRequests(campaignName, scpName, loginName)
+	assert.EqualError(t, err, "invalid character 'b' looking for beginning of object key string")
+	assert.Nil(t, pullRequests)
+}
 
-	const newPoints = float64(11)
+func TestSelectScoredPullRequests(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
 
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoredPullRequests)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows(scoredPullRequestColumns).
+			AddRow(TestOrgValid, "testRepoName", 4, 2.5, []byte(`{"testBugType": 2}`), now).
+			AddRow(TestOrgValid, "testRepoName", 3, 1, nil, nil))
+
+	pullRequests, err := db.SelectScoredPullRequests(campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ScoredPullRequest{
+		{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 4, Points: 2.5,
+			BugCounts: map[string]interface{}{testBugType: float64(2)}, ScoredOn: &now},
+		{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 3, Points: 1},
+	}, pullRequests)
+}
+
+func TestSelectCampaignScoredPullRequestsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select campaign scored pull requests error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoredPullRequests)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	pullRequests, err := db.SelectCampaignScoredPullRequests(campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, pullRequests)
+}
+
+func TestSelectCampaignScoredPullRequests(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoredPullRequests)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(scoredPullRequestColumns).
+			AddRow(TestOrgValid, "testRepoName", 3, 1, nil, nil).
+			AddRow(TestOrgValid, "otherRepoName", 4, 2.5, []byte(`{"testBugType": 2}`), now))
+
+	pullRequests, err := db.SelectCampaignScoredPullRequests(campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ScoredPullRequest{
+		{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 3, Points: 1},
+		{RepoOwner: TestOrgValid, RepoName: "otherRepoName", PullRequest: 4, Points: 2.5,
+			BugCounts: map[string]interface{}{testBugType: float64(2)}, ScoredOn: &now},
+	}, pullRequests)
+}
+
+func TestSelectCampaignDailyScoresError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select campaign daily scores error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignDailyScores)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	days, err := db.SelectCampaignDailyScores(campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, days)
+}
+
+func TestSelectCampaignDailyScoresScanError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignDailyScores)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "pullRequests", "points"}).
+			AddRow("2022-05-16", "notANumber", 1))
+
+	days, err := db.SelectCampaignDailyScores(campaignName)
+	assert.Error(t, err)
+	assert.Nil(t, days)
+}
+
+func TestSelectCampaignDailyScores(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignDailyScores)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "pullRequests", "points"}).
+			AddRow("2022-05-16", 2, 2.5).
+			AddRow("2022-05-18", 1, -1))
+
+	days, err := db.SelectCampaignDailyScores(campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.DayScore{
+		{Day: "2022-05-16", PullRequests: 2, Points: 2.5},
+		{Day: "2022-05-18", PullRequests: 1, Points: -1},
+	}, days)
+}
+
+func TestCountParticipantsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
 
-	assert.NoError(t, db.InsertScoringEvent(testParticipant, msg, newPoints))
+	forcedError := fmt.Errorf("forced count participants error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlCountParticipants)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	_, _, err := db.CountParticipants(campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestCountParticipants(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlCountParticipants)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"registered", "active"}).AddRow(5, 3))
+
+	registered, active, err := db.CountParticipants(campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, registered)
+	assert.Equal(t, 3, active)
 }
 
 func TestInsertParticipantError(t *testing.T) {
@@ -679,12 +1041,12 @@ func TestInsertParticipantError(t *testing.T) {
 	forcedError := fmt.Errorf("forced insert participant error")
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
 		WithArgs(testParticipant.ScpName, testParticipant.CampaignName,
-			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0).
+			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0, false).
 		WillReturnError(forcedError)
 
 	assert.EqualError(t, db.InsertParticipant(&testParticipant), forcedError.Error())
 	assert.Equal(t, "", testParticipant.ID)
-	assert.Equal(t, -2, testParticipant.Score)
+	assert.Equal(t, float64(-2), testParticipant.Score)
 	assert.Equal(t, time.Time{}, testParticipant.JoinedAt)
 }
 
@@ -700,17 +1062,18 @@ func TestInsertParticipant(t *testing.T) {
 		Email:        "email",
 		DisplayName:  "displayName",
 		Score:        -1, // this should be ignored during insert
+		Private:      true,
 	}
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
 		WithArgs(testParticipant.ScpName, testParticipant.CampaignName,
-			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0).
+			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0, true).
 		WillReturnRows(sqlmock.NewRows([]string{"guid", "score", "joinedAt"}).
 			AddRow(testParticipantGuid, 0, now))
 
 	assert.NoError(t, db.InsertParticipant(&testParticipant))
 	assert.Equal(t, testParticipantGuid, testParticipant.ID)
-	assert.Equal(t, 0, testParticipant.Score)
+	assert.Equal(t, float64(0), testParticipant.Score)
 	assert.Equal(t, now, testParticipant.JoinedAt)
 }
 
@@ -755,116 +1118,343 @@ func TestInsertTeam(t *testing.T) {
 	assert.Equal(t, testTeamGuid, testTeam.Id)
 }
 
-const campaignName = "campaignName"
-const scpName = "scpName"
-
-func TestSelectParticipantDetailError(t *testing.T) {
+func TestSelectTeamMemberScoresError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced insert team error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
-		WithArgs(campaignName, scpName, loginName).
+	forcedError := fmt.Errorf("forced select teams error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamMemberScores)).
+		WithArgs(testCampaign.Name).
 		WillReturnError(forcedError)
 
-	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
+	teams, err := db.SelectTeamMemberScores(testCampaign.Name)
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, &types.ParticipantStruct{}, participant)
+	assert.Nil(t, teams)
 }
 
-func TestSelectParticipantDetailNoTeam(t *testing.T) {
+func TestSelectTeamMemberScoresScanError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
-		WithArgs(campaignName, scpName, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamMemberScores)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"teamId", "teamName", "participantId", "score"}).
+			AddRow(testTeamGuid, "teamName", testParticipantGuid, "bogus"))
 
-	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
-	assert.NoError(t, err)
-	assert.Equal(t, &types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: campaignName,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		Email:        "email",
-		DisplayName:  "display",
-		Score:        -1,
-		TeamName:     "",
-		JoinedAt:     now,
-	}, participant)
+	_, err := db.SelectTeamMemberScores(testCampaign.Name)
+	assert.Error(t, err)
 }
 
-func TestSelectParticipantDetail(t *testing.T) {
+func TestSelectTeamMemberScoresNone(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	const campaignName = "campaignName"
-	const scpName = "scpName"
-	const loginName = "loginName"
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamMemberScores)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"teamId", "teamName", "participantId", "score"}))
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
-		WithArgs(campaignName, scpName, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now))
+	teams, err := db.SelectTeamMemberScores(testCampaign.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TeamMemberScores{}, teams)
+}
 
-	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
+func TestSelectTeamMemberScores(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamMemberScores)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"teamId", "teamName", "participantId", "score"}).
+			AddRow("teamId1", "alpha", "participantId1", []byte("2.50")).
+			AddRow("teamId1", "alpha", "participantId2", 3).
+			AddRow("teamId2", "empty", nil, 0).
+			AddRow("teamId3", "zulu", "participantId3", 0))
+
+	teams, err := db.SelectTeamMemberScores(testCampaign.Name)
 	assert.NoError(t, err)
-	assert.Equal(t, &types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: campaignName,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		Email:        "email",
-		DisplayName:  "display",
-		Score:        -1,
-		TeamName:     "teamName",
-		JoinedAt:     now,
-	}, participant)
+	assert.Equal(t, []types.TeamMemberScores{
+		{Team: types.TeamStruct{Id: "teamId1", CampaignName: testCampaign.Name, Name: "alpha"}, MemberScores: []float64{2.5, 3}},
+		{Team: types.TeamStruct{Id: "teamId2", CampaignName: testCampaign.Name, Name: "empty"}},
+		{Team: types.TeamStruct{Id: "teamId3", CampaignName: testCampaign.Name, Name: "zulu"}, MemberScores: []float64{0}},
+	}, teams)
 }
 
-func TestSelectParticipantsInCampaignError(t *testing.T) {
+func TestUpdateTeamNameError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced select campaign participants error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
+	forcedError := fmt.Errorf("forced update team error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateTeamName)).
+		WithArgs(testCampaign.Name, "teamName", "newTeamName").
 		WillReturnError(forcedError)
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
+	rowsAffected, err := db.UpdateTeamName(testCampaign.Name, "teamName", "newTeamName")
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+	assert.Equal(t, int64(0), rowsAffected)
 }
 
-func TestSelectParticipantsInCampaignScanError(t *testing.T) {
+func TestUpdateTeamNameTaken(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			// force scan error with nil in JoinedAt Time field
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", nil))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateTeamName)).
+		WithArgs(testCampaign.Name, "teamName", "newTeamName").
+		WillReturnError(&pq.Error{Code: uniqueViolation})
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
-	assert.EqualError(t, err, "sql: Scan error on column index 8, name \"joinedAt\": unsupported Scan, storing driver.Value type <nil> into type *time.Time")
-	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+	_, err := db.UpdateTeamName(testCampaign.Name, "teamName", "newTeamName")
+	assert.True(t, IsUniqueViolation(err))
 }
 
-func TestSelectParticipantsInCampaignNoTeam(t *testing.T) {
+func TestIsUniqueViolation(t *testing.T) {
+	assert.False(t, IsUniqueViolation(nil))
+	assert.False(t, IsUniqueViolation(fmt.Errorf("not a postgres error")))
+	assert.False(t, IsUniqueViolation(&pq.Error{Code: "23503"}))
+	assert.True(t, IsUniqueViolation(&pq.Error{Code: "23505"}))
+	assert.True(t, IsUniqueViolation(fmt.Errorf("wrapped: %w", &pq.Error{Code: "23505"})))
+}
+
+func TestUpdateTeamName(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateTeamName)).
+		WithArgs(testCampaign.Name, "teamName", "newTeamName").
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
+	rowsAffected, err := db.UpdateTeamName(testCampaign.Name, "teamName", "newTeamName")
 	assert.NoError(t, err)
-	assert.Equal(t, []types.ParticipantStruct{
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestDeleteTeamBeginError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced begin error")
+	mock.ExpectBegin().WillReturnError(forcedError)
+
+	_, err := db.DeleteTeam(testCampaign.Name, "teamName")
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestDeleteTeamClearMembersError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced clear team members error")
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlClearTeamMembers)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.DeleteTeam(testCampaign.Name, "teamName")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTeamError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced delete team error")
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlClearTeamMembers)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteTeam)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.DeleteTeam(testCampaign.Name, "teamName")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlClearTeamMembers)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteTeam)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rowsAffected, err := db.DeleteTeam(testCampaign.Name, "teamName")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const campaignName = "campaignName"
+const scpName = "scpName"
+
+func TestSelectParticipantDetailError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced insert team error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnError(forcedError)
+
+	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, &types.ParticipantStruct{}, participant)
+}
+
+func TestSelectParticipantDetailNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "private"}).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now, false))
+
+	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ParticipantStruct{
+		ID:           testParticipantGuid,
+		CampaignName: campaignName,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Email:        "email",
+		DisplayName:  "display",
+		Score:        -1,
+		TeamName:     "",
+		JoinedAt:     now,
+	}, participant)
+}
+
+func TestSelectParticipantDetail(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	const campaignName = "campaignName"
+	const scpName = "scpName"
+	const loginName = "loginName"
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "private"}).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now, true))
+
+	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ParticipantStruct{
+		ID:           testParticipantGuid,
+		CampaignName: campaignName,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Email:        "email",
+		DisplayName:  "display",
+		Score:        -1,
+		TeamName:     "teamName",
+		JoinedAt:     now,
+		Private:      true,
+	}, participant)
+}
+
+var participantListColumns = []string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "private", "rank"}
+
+func expectCountParticipantsByCampaign(mock sqlmock.Sqlmock, total int) {
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlCountParticipantsByCampaign)).
+		WithArgs(campaignName, "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(total))
+}
+
+func TestSelectParticipantsInCampaignCountError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced count campaign participants error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlCountParticipantsByCampaign)).
+		WithArgs(campaignName, "myTeam", scpName).
+		WillReturnError(forcedError)
+
+	participants, _, err := db.SelectParticipantsInCampaign(campaignName,
+		&types.ParticipantFilter{TeamName: "myTeam", ScpName: scpName, Limit: 10})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, participants)
+}
+
+func TestSelectParticipantsInCampaignFiltered(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlCountParticipantsByCampaign)).
+		WithArgs(campaignName, "myTeam", scpName).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName, "myTeam", scpName, 2, 4).
+		WillReturnRows(sqlmock.NewRows(participantListColumns).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", 3, "myTeam", now, false, 2).
+			AddRow(testParticipantGuid, campaignName, scpName, "name2", "email", "display", 3, "myTeam", now, true, 2))
+
+	participants, total, err := db.SelectParticipantsInCampaign(campaignName,
+		&types.ParticipantFilter{TeamName: "myTeam", ScpName: scpName, Limit: 2, Offset: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, total)
+	assert.Equal(t, 2, len(participants))
+	assert.Equal(t, loginName, participants[0].LoginName)
+	assert.Equal(t, 2, participants[0].Rank)
+	assert.Equal(t, "name2", participants[1].LoginName)
+	assert.Equal(t, 2, participants[1].Rank)
+	assert.True(t, participants[1].Private)
+}
+
+func TestSelectParticipantsInCampaignError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select campaign participants error")
+	expectCountParticipantsByCampaign(mock, 2)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName, "", "", 10, 0).
+		WillReturnError(forcedError)
+
+	participants, total, err := db.SelectParticipantsInCampaign(campaignName, &types.ParticipantFilter{Limit: 10})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+	assert.Equal(t, 2, total)
+}
+
+func TestSelectParticipantsInCampaignScanError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	expectCountParticipantsByCampaign(mock, 2)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName, "", "", 10, 0).
+		WillReturnRows(sqlmock.NewRows(participantListColumns).
+			// force scan error with nil in JoinedAt Time field
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", nil, false, 1))
+
+	participants, total, err := db.SelectParticipantsInCampaign(campaignName, &types.ParticipantFilter{Limit: 10})
+	assert.EqualError(t, err, "sql: Scan error on column index 8, name \"joinedAt\": unsupported Scan, storing driver.Value type <nil> into type *time.Time")
+	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+	assert.Equal(t, 2, total)
+}
+
+func TestSelectParticipantsInCampaignNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	expectCountParticipantsByCampaign(mock, 2)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName, "", "", 10, 0).
+		WillReturnRows(sqlmock.NewRows(participantListColumns).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now, false, 1))
+
+	participants, total, err := db.SelectParticipantsInCampaign(campaignName, &types.ParticipantFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ParticipantStruct{
 		{
 			ID:           testParticipantGuid,
 			CampaignName: campaignName,
@@ -875,20 +1465,23 @@ func TestSelectParticipantsInCampaignNoTeam(t *testing.T) {
 			Score:        -1,
 			TeamName:     "",
 			JoinedAt:     now,
+			Rank:         1,
 		},
 	}, participants)
+	assert.Equal(t, 2, total)
 }
 
 func TestSelectParticipantsInCampaign(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
+	expectCountParticipantsByCampaign(mock, 2)
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now))
+		WithArgs(campaignName, "", "", 10, 0).
+		WillReturnRows(sqlmock.NewRows(participantListColumns).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now, false, 1))
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
+	participants, total, err := db.SelectParticipantsInCampaign(campaignName, &types.ParticipantFilter{Limit: 10})
 	assert.NoError(t, err)
 	assert.Equal(t, []types.ParticipantStruct{
 		{
@@ -901,21 +1494,25 @@ func TestSelectParticipantsInCampaign(t *testing.T) {
 			Score:        -1,
 			TeamName:     "teamName",
 			JoinedAt:     now,
+			Rank:         1,
 		},
 	}, participants)
+	assert.Equal(t, 2, total)
 }
 
 func TestSelectParticipantsInCampaignSorted(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
+	expectCountParticipantsByCampaign(mock, 2)
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", 1, "teamName", now).
-			AddRow(testParticipantGuid, campaignName, scpName, "name2", "email", "display", 0, "teamName", now))
+		WithArgs(campaignName, "", "", 10, 0).
+		WillReturnRows(sqlmock.NewRows(participantListColumns).
+			// postgres reads NUMERIC scores as text
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", []byte("1.50"), "teamName", now, false, 1).
+			AddRow(testParticipantGuid, campaignName, scpName, "name2", "email", "display", 0, "teamName", now, false, 2))
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
+	participants, total, err := db.SelectParticipantsInCampaign(campaignName, &types.ParticipantFilter{Limit: 10})
 	assert.NoError(t, err)
 	assert.Equal(t, []types.ParticipantStruct{
 		{
@@ -925,9 +1522,10 @@ func TestSelectParticipantsInCampaignSorted(t *testing.T) {
 			LoginName:    loginName,
 			Email:        "email",
 			DisplayName:  "display",
-			Score:        1,
+			Score:        1.5,
 			TeamName:     "teamName",
 			JoinedAt:     now,
+			Rank:         1,
 		},
 		{
 			ID:           testParticipantGuid,
@@ -939,8 +1537,10 @@ func TestSelectParticipantsInCampaignSorted(t *testing.T) {
 			Score:        0,
 			TeamName:     "teamName",
 			JoinedAt:     now,
+			Rank:         2,
 		},
 	}, participants)
+	assert.Equal(t, 2, total)
 }
 
 func TestUpdateParticipantError(t *testing.T) {
@@ -951,7 +1551,7 @@ func TestUpdateParticipantError(t *testing.T) {
 	forcedError := fmt.Errorf("forced update participant error")
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
 		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
-			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName, testParticipant.Private,
 			testParticipant.ID).
 		WillReturnError(forcedError)
 
@@ -978,7 +1578,7 @@ func TestUpdateParticipantRowsAffectedError(t *testing.T) {
 	forcedError := fmt.Errorf("forced update participant rows affected error")
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
 		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
-			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName, testParticipant.Private,
 			testParticipant.ID).
 		WillReturnResult(sqlmock.NewErrorResult(forcedError))
 
@@ -1001,10 +1601,11 @@ func TestUpdateParticipant(t *testing.T) {
 		Score:        -1,
 		TeamName:     "teamName",
 		JoinedAt:     now,
+		Private:      true,
 	}
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
 		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
-			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName, testParticipant.Private,
 			testParticipant.ID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -1083,6 +1684,33 @@ func TestUpdateParticipantTeam(t *testing.T) {
 	assert.Equal(t, int64(1), rowsAffected)
 }
 
+func TestDeleteParticipantTeamError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced delete participant team error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteParticipantTeam)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnError(forcedError)
+
+	rowsAffected, err := db.DeleteParticipantTeam(campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestDeleteParticipantTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteParticipantTeam)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteParticipantTeam(campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
 const bugCategory = "bugCategory"
 const bugGuid = "bugGuid"
 
@@ -1202,6 +1830,180 @@ func TestSelectBugs(t *testing.T) {
 	assert.Equal(t, []types.BugStruct{bug}, bugs)
 }
 
+// expectRepriceHistory expects the scoring history to be read, in the recompute transaction, before a pull request
+// is re-priced
+func expectRepriceHistory(mock sqlmock.Sqlmock, repoName string, pr int, rows *sqlmock.Rows) {
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(campaignName, scpName, loginName, TestOrgValid, repoName, pr, now).
+		WillReturnRows(rows)
+}
+
+func TestRecomputeCampaignScoresRepriceHistoryError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 3))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoringEventsForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeEventColumns).
+			AddRow(scpName, TestOrgValid, "repriced", 1, loginName, 2, []byte(`{"fixed-bugs": 1}`), now))
+	forcedError := fmt.Errorf("forced history error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.RecomputeCampaignScores(campaignName, func(entry *types.LedgerEntry, _ time.Time, _ *types.ScoringHistory) (points float64, err error) {
+		assert.Fail(t, "should not reprice without the scoring history")
+		return
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecomputeCampaignScoresRepriceError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 3))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoringEventsForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeEventColumns).
+			AddRow(scpName, TestOrgValid, "repriced", 1, loginName, 2, []byte(`{"fixed-bugs": 1}`), now))
+	expectRepriceHistory(mock, "repriced", 1, sqlmock.NewRows(scoringHistoryColumns))
+	mock.ExpectRollback()
+
+	forcedError := fmt.Errorf("forced reprice error")
+	_, err := db.RecomputeCampaignScores(campaignName, func(entry *types.LedgerEntry, _ time.Time, _ *types.ScoringHistory) (points float64, err error) {
+		return 0, forcedError
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectScoringRulesNone(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringRules)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"rules"}))
+
+	rules := types.ScoringRules{UnclassifiedPoints: 1}
+	assert.NoError(t, db.SelectScoringRules(campaignName, &rules))
+	assert.Equal(t, types.ScoringRules{UnclassifiedPoints: 1}, rules)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectScoringRulesError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select rules error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringRules)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	assert.EqualError(t, db.SelectScoringRules(campaignName, &types.ScoringRules{}), forcedError.Error())
+}
+
+func TestSelectScoringRules(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringRules)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"rules"}).AddRow([]byte(`{"dailyCap": 20, "zeroedCategories": ["G104"]}`)))
+
+	// fields missing from the stored rules keep their given value
+	rules := types.ScoringRules{UnclassifiedPoints: 1}
+	assert.NoError(t, db.SelectScoringRules(campaignName, &rules))
+	assert.Equal(t, types.ScoringRules{UnclassifiedPoints: 1, DailyCap: 20, ZeroedCategories: []string{"G104"}}, rules)
+}
+
+func TestUpsertScoringRulesError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced upsert rules error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpsertScoringRules)).
+		WithArgs(campaignName, sqlmock.AnyArg()).
+		WillReturnError(forcedError)
+
+	_, err := db.UpsertScoringRules(campaignName, &types.ScoringRules{})
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestUpsertScoringRules(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	rules := &types.ScoringRules{UnclassifiedPoints: 2, Multipliers: []types.ScoringMultiplier{{RepoOwner: TestOrgValid, Factor: 1.5}}}
+	rulesJson, err := json.Marshal(rules)
+	assert.NoError(t, err)
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpsertScoringRules)).
+		WithArgs(campaignName, rulesJson).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.UpsertScoringRules(campaignName, rules)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+var scoringHistoryColumns = []string{"bug_counts", "delta", "created_on"}
+
+func TestSelectScoringHistoryError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select history error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WillReturnError(forcedError)
+
+	_, err := selectScoringHistory(db.db, &types.ParticipantStruct{}, &types.ScoringMessage{}, now)
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestSelectScoringHistoryBadBugCounts(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WillReturnRows(sqlmock.NewRows(scoringHistoryColumns).AddRow([]byte(`{bogus`), 1, now))
+
+	_, err := selectScoringHistory(db.db, &types.ParticipantStruct{}, &types.ScoringMessage{}, now)
+	assert.EqualError(t, err, "invalid character 'b' looking for beginning of object key string")
+}
+
+func TestSelectScoringHistory(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	asOf := time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC)
+	participant := &types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: loginName}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "myRepo", PullRequest: 7}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(campaignName, scpName, loginName, TestOrgValid, "myRepo", 7, asOf).
+		WillReturnRows(sqlmock.NewRows(scoringHistoryColumns).
+			AddRow([]byte(`{"G104": 1}`), 4, asOf.Add(-24*time.Hour)).
+			AddRow(nil, 3, asOf.Add(-time.Hour)).
+			AddRow([]byte(`{"ShellCheck": 2}`), 2, asOf.Add(-time.Minute)))
+
+	history, err := selectScoringHistory(db.db, participant, msg, asOf)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringHistory{
+		PointsToday: 5,
+		BugCounts:   []map[string]interface{}{{"G104": float64(1)}, {"ShellCheck": float64(2)}},
+	}, history)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetDb(t *testing.T) {
 	_, dbFake, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
@@ -1209,3 +2011,686 @@ func TestGetDb(t *testing.T) {
 	assert.NotNil(t, dbFake.GetDb())
 	assert.NotNil(t, dbFake.logger)
 }
+
+var recomputeScoreColumns = []string{"Id", "scp", "login_name", "private", "Score"}
+var recomputeEventColumns = []string{"scp", "repoOwner", "repoName", "pr", "username", "points", "scoring_message", "created_on"}
+
+func TestRecomputeCampaignScoresBeginError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced begin error")
+	mock.ExpectBegin().WillReturnError(forcedError)
+
+	report, err := db.RecomputeCampaignScores(campaignName, nil)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, report)
+}
+
+func TestRecomputeCampaignScoresSelectError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	forcedError := fmt.Errorf("forced select scores error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	report, err := db.RecomputeCampaignScores(campaignName, nil)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecomputeCampaignScoresUpdateError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 3))
+	forcedError := fmt.Errorf("forced update scores error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaignScoresFromEvents)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.RecomputeCampaignScores(campaignName, nil)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectScoreCorrection expects the recompute to record a change to the score of the participant in the ledger
+func expectScoreCorrection(mock sqlmock.Sqlmock, oldScore, newScore float64) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, types.LedgerSourceRecompute, "", "", 0,
+			oldScore, newScore, []byte("null"), sqlmock.AnyArg(), []byte("null"), nil)
+}
+
+func TestRecomputeCampaignScoresCorrectionError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 3))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaignScoresFromEvents)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "Score"}).AddRow(testParticipantGuid, 5))
+	forcedError := fmt.Errorf("forced correction error")
+	expectScoreCorrection(mock, 3, 5).WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.RecomputeCampaignScores(campaignName, nil)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecomputeCampaignScores(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).
+			AddRow(testParticipantGuid, scpName, loginName, true, 3).
+			AddRow("unchangedGuid", scpName, "unchanged", false, 7))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaignScoresFromEvents)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "Score"}).
+			AddRow(testParticipantGuid, 5).
+			AddRow("unchangedGuid", 7))
+	expectScoreCorrection(mock, 3, 5).WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId", now))
+	mock.ExpectCommit()
+
+	report, err := db.RecomputeCampaignScores(campaignName, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.RecomputeReport{
+		CampaignName: campaignName,
+		Changes:      []types.ScoreChange{{ScpName: scpName, LoginName: loginName, Private: true, OldScore: 3, NewScore: 5}},
+	}, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecomputeCampaignScoresReprice(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	repriceMsg := `{"triggerUser": "loginName", "fixed-bugs": 2, "fixed-bug-types": {"testBugType": 2}}`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 3))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoringEventsForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeEventColumns).
+			AddRow(scpName, TestOrgValid, "repriced", 1, loginName, 2, []byte(repriceMsg), now).
+			AddRow(scpName, TestOrgValid, "noLedger", 2, loginName, 1, nil, nil).
+			AddRow(scpName, TestOrgValid, "samePrice", 3, loginName, 6, []byte(repriceMsg), now))
+	expectRepriceHistory(mock, "repriced", 1, sqlmock.NewRows(scoringHistoryColumns).AddRow(nil, 4, now))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateScoringEventPoints)).
+		WithArgs(campaignName, scpName, TestOrgValid, "repriced", 1, float64(6)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, types.LedgerSourceRecompute, TestOrgValid, "repriced", 1,
+			float64(2), float64(6), []byte(`{"testBugType":2}`), sqlmock.AnyArg(), sqlmock.AnyArg(), now).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId", now))
+	expectRepriceHistory(mock, "samePrice", 3, sqlmock.NewRows(scoringHistoryColumns).AddRow(nil, 4, now))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaignScoresFromEvents)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "Score"}).AddRow(testParticipantGuid, 13))
+	// the score had drifted from its events: 4 of the 10 points gained are explained by the re-priced event
+	expectScoreCorrection(mock, 7, 13).WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId2", now))
+	mock.ExpectCommit()
+
+	repricedPRs := 0
+	report, err := db.RecomputeCampaignScores(campaignName, func(entry *types.LedgerEntry, scoredOn time.Time, history *types.ScoringHistory) (points float64, err error) {
+		assert.Equal(t, campaignName, entry.CampaignName)
+		assert.Equal(t, loginName, entry.LoginName)
+		assert.Equal(t, 2, entry.ScoringMessage.TotalFixed)
+		assert.Equal(t, now, scoredOn)
+		assert.Equal(t, &types.ScoringHistory{PointsToday: 4}, history)
+		repricedPRs++
+		return 6, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, repricedPRs)
+	assert.Equal(t, &types.RecomputeReport{
+		CampaignName:   campaignName,
+		RepricedEvents: 1,
+		Changes:        []types.ScoreChange{{ScpName: scpName, LoginName: loginName, OldScore: 3, NewScore: 13}},
+	}, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// dailyCapReprice prices each fixed bug at price points, capped by what is left of a daily cap of 10 points
+func dailyCapReprice(price float64) func(entry *types.LedgerEntry, _ time.Time, history *types.ScoringHistory) (points float64, err error) {
+	return func(entry *types.LedgerEntry, _ time.Time, history *types.ScoringHistory) (points float64, err error) {
+		return math.Min(float64(entry.ScoringMessage.TotalFixed)*price, math.Max(10-history.PointsToday, 0)), nil
+	}
+}
+
+func TestRecomputeCampaignScoresRepriceInScoringOrder(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	// two pull requests scored the same day at 2 points a bug, under a daily cap of 10, re-priced at 4 points a bug
+	firstScoredOn := time.Date(2022, 5, 16, 9, 0, 0, 0, time.UTC)
+	secondScoredOn := firstScoredOn.Add(time.Hour)
+	msg := []byte(`{"fixed-bugs": 2}`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 8))
+	// locked in key order, the second scored first
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoringEventsForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeEventColumns).
+			AddRow(scpName, TestOrgValid, "a-scoredSecond", 2, loginName, 4, msg, secondScoredOn).
+			AddRow(scpName, TestOrgValid, "b-scoredFirst", 1, loginName, 4, msg, firstScoredOn))
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(campaignName, scpName, loginName, TestOrgValid, "b-scoredFirst", 1, firstScoredOn).
+		WillReturnRows(sqlmock.NewRows(scoringHistoryColumns))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateScoringEventPoints)).
+		WithArgs(campaignName, scpName, TestOrgValid, "b-scoredFirst", 1, float64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// recorded as of its scoring time, so the history of the second pull request includes it
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, types.LedgerSourceRecompute, TestOrgValid, "b-scoredFirst", 1,
+			float64(4), float64(8), []byte("null"), sqlmock.AnyArg(), []byte("null"), firstScoredOn).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId1", firstScoredOn))
+
+	// the ledger of the first pull request as of the second: scored at 4, then re-priced from 4 to 8
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(campaignName, scpName, loginName, TestOrgValid, "a-scoredSecond", 2, secondScoredOn).
+		WillReturnRows(sqlmock.NewRows(scoringHistoryColumns).
+			AddRow(nil, 4, firstScoredOn).
+			AddRow(nil, 4, firstScoredOn))
+	// only 2 points are left under the daily cap, not the 6 left before re-pricing the first pull request
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateScoringEventPoints)).
+		WithArgs(campaignName, scpName, TestOrgValid, "a-scoredSecond", 2, float64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, types.LedgerSourceRecompute, TestOrgValid, "a-scoredSecond", 2,
+			float64(4), float64(2), []byte("null"), sqlmock.AnyArg(), []byte("null"), secondScoredOn).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId2", secondScoredOn))
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaignScoresFromEvents)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "Score"}).AddRow(testParticipantGuid, 10))
+	mock.ExpectCommit()
+
+	report, err := db.RecomputeCampaignScores(campaignName, dailyCapReprice(4))
+	assert.NoError(t, err)
+	assert.Equal(t, &types.RecomputeReport{
+		CampaignName:   campaignName,
+		RepricedEvents: 2,
+		Changes:        []types.ScoreChange{{ScpName: scpName, LoginName: loginName, OldScore: 8, NewScore: 10}},
+	}, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecomputeCampaignScoresRepriceKeepsPointsToday(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	// a pull request scored yesterday at 2 points a bug, re-priced today at 4 points a bug
+	scoredOn := time.Date(2022, 5, 16, 9, 0, 0, 0, time.UTC)
+	today := scoredOn.Add(24 * time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 4))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoringEventsForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeEventColumns).
+			AddRow(scpName, TestOrgValid, "repriced", 1, loginName, 4, []byte(`{"fixed-bugs": 2}`), scoredOn))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(campaignName, scpName, loginName, TestOrgValid, "repriced", 1, scoredOn).
+		WillReturnRows(sqlmock.NewRows(scoringHistoryColumns))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateScoringEventPoints)).
+		WithArgs(campaignName, scpName, TestOrgValid, "repriced", 1, float64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, types.LedgerSourceRecompute, TestOrgValid, "repriced", 1,
+			float64(4), float64(8), []byte("null"), sqlmock.AnyArg(), []byte("null"), scoredOn).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId", scoredOn))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaignScoresFromEvents)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "Score"}).AddRow(testParticipantGuid, 8))
+	mock.ExpectCommit()
+
+	// the ledger of the participant as read by a pull request scored later today: yesterday's score and its re-pricing
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(campaignName, scpName, loginName, TestOrgValid, "later", 2, today).
+		WillReturnRows(sqlmock.NewRows(scoringHistoryColumns).
+			AddRow(nil, 4, scoredOn).
+			AddRow(nil, 4, scoredOn))
+
+	_, err := db.RecomputeCampaignScores(campaignName, dailyCapReprice(4))
+	assert.NoError(t, err)
+
+	participant := &types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: loginName}
+	history, err := selectScoringHistory(db.db, participant,
+		&types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "later", PullRequest: 2, TotalFixed: 3}, today)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), history.PointsToday)
+	// so the later pull request still gets the whole daily cap
+	points, err := dailyCapReprice(4)(&types.LedgerEntry{ScoringMessage: types.ScoringMessage{TotalFixed: 3}}, today, history)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), points)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecomputeCampaignScoresRepriceRollback(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoresForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeScoreColumns).AddRow(testParticipantGuid, scpName, loginName, false, 3))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignScoringEventsForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows(recomputeEventColumns).
+			AddRow(scpName, TestOrgValid, "repriced", 1, loginName, 2, []byte(`{"fixed-bugs": 1}`), now))
+	expectRepriceHistory(mock, "repriced", 1, sqlmock.NewRows(scoringHistoryColumns))
+	forcedError := fmt.Errorf("forced reprice error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateScoringEventPoints)).
+		WithArgs(campaignName, scpName, TestOrgValid, "repriced", 1, float64(1)).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.RecomputeCampaignScores(campaignName, func(entry *types.LedgerEntry, _ time.Time, _ *types.ScoringHistory) (points float64, err error) {
+		return float64(entry.ScoringMessage.TotalFixed), nil
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBeginScoreTxError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced begin score error")
+	mock.ExpectBegin().WillReturnError(forcedError)
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, scoreTx)
+}
+
+func setupTestScoreTxParticipant() (*types.ParticipantStruct, *types.ScoringMessage) {
+	return &types.ParticipantStruct{ID: testParticipantGuid, CampaignName: campaignName, ScpName: scpName, LoginName: loginName},
+		&types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: 3}
+}
+
+func TestScoreTxSelectScoringHistoryLockError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, msg := setupTestScoreTxParticipant()
+	mock.ExpectBegin()
+	forcedError := fmt.Errorf("forced lock error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlLockParticipant)).
+		WithArgs(testParticipantGuid).
+		WillReturnError(forcedError)
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	_, err = scoreTx.SelectScoringHistory(participant, msg, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScoreTxSelectScoringHistory(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, msg := setupTestScoreTxParticipant()
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlLockParticipant)).
+		WithArgs(testParticipantGuid).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow(testParticipantGuid))
+	// an entry scored at the same time, e.g. earlier in the same poll, is part of the history
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringHistory)).
+		WithArgs(campaignName, scpName, loginName, TestOrgValid, "testRepoName", 3, now).
+		WillReturnRows(sqlmock.NewRows(scoringHistoryColumns).AddRow([]byte(`{"G104": 1}`), 4, now))
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	history, err := scoreTx.SelectScoringHistory(participant, msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringHistory{
+		PointsToday: 4,
+		BugCounts:   []map[string]interface{}{{"G104": float64(1)}},
+	}, history)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScoreTxSelectPriorScoreLockError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, msg := setupTestScoreTxParticipant()
+	mock.ExpectBegin()
+	forcedError := fmt.Errorf("forced lock error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlLockScoringEvent)).
+		WithArgs("scoring_event/campaignName/scpName/myValidTestOrganization/testRepoName/3").
+		WillReturnError(forcedError)
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	oldPoints, err := scoreTx.SelectPriorScore(participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, float64(0), oldPoints)
+}
+
+func TestScoreTxSelectPriorScoreNewEvent(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, msg := setupTestScoreTxParticipant()
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlLockScoringEvent)).
+		WithArgs(scoringEventLockKey(participant, msg)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlScoreQueryForUpdate)).
+		WithArgs(participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest).
+		WillReturnRows(sqlmock.NewRows([]string{"points"}))
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	oldPoints, err := scoreTx.SelectPriorScore(participant, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), oldPoints)
+}
+
+func TestScoreTxSelectPriorScoreError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, msg := setupTestScoreTxParticipant()
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlLockScoringEvent)).
+		WithArgs(scoringEventLockKey(participant, msg)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	forcedError := fmt.Errorf("forced prior score error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlScoreQueryForUpdate)).
+		WithArgs(participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest).
+		WillReturnError(forcedError)
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	_, err = scoreTx.SelectPriorScore(participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestScoreTxCommit(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, msg := setupTestScoreTxParticipant()
+	SetupMockScoreTxBeginAndPriorScore(mock, participant, msg, 2)
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
+		WithArgs(campaignName, scpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, float64(5), []byte("null"), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateParticipantScore)).
+		WithArgs(float64(3), testParticipantGuid).
+		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(3))
+	entry := setupTestLedgerEntry()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLedgerEntry)).
+		WithArgs(campaignName, scpName, loginName, entry.SourceId, entry.RepoOwner, entry.RepoName, entry.PullRequest,
+			entry.OldPoints, entry.NewPoints, []byte(`{"testBugType":2}`), sqlmock.AnyArg(),
+			[]byte(`{"testBugType":"testBugType"}`), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("ledgerId", now))
+	mock.ExpectCommit()
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	history, err := scoreTx.SelectScoringHistory(participant, msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringHistory{}, history)
+	oldPoints, err := scoreTx.SelectPriorScore(participant, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), oldPoints)
+	assert.NoError(t, scoreTx.InsertScoringEvent(participant, msg, 5, now))
+	assert.NoError(t, scoreTx.UpdateParticipantScore(participant, 3))
+	assert.NoError(t, scoreTx.InsertLedgerEntry(entry))
+	assert.NoError(t, scoreTx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScoreTxRollback(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, msg := setupTestScoreTxParticipant()
+	forcedError := fmt.Errorf("forced update score error")
+	SetupMockScoreTxUpdateScoreForcedError(mock, participant, msg, 2, 5, forcedError)
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	_, err = scoreTx.SelectScoringHistory(participant, msg, now)
+	assert.NoError(t, err)
+	_, err = scoreTx.SelectPriorScore(participant, msg)
+	assert.NoError(t, err)
+	assert.NoError(t, scoreTx.InsertScoringEvent(participant, msg, 5, now))
+	assert.EqualError(t, scoreTx.UpdateParticipantScore(participant, 3), forcedError.Error())
+	assert.NoError(t, scoreTx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScoreTxInsertProcessedEventError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, _ := setupTestScoreTxParticipant()
+	mock.ExpectBegin()
+	forcedError := fmt.Errorf("forced processed event error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertProcessedEvent)).
+		WithArgs("myLogId", testParticipantGuid).
+		WillReturnError(forcedError)
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	firstTime, err := scoreTx.InsertProcessedEvent("myLogId", participant)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, firstTime)
+}
+
+func TestScoreTxInsertProcessedEvent(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant, _ := setupTestScoreTxParticipant()
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertProcessedEvent)).
+		WithArgs("myLogId", testParticipantGuid).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertProcessedEvent)).
+		WithArgs("myLogId", testParticipantGuid).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	scoreTx, err := db.BeginScoreTx()
+	assert.NoError(t, err)
+	firstTime, err := scoreTx.InsertProcessedEvent("myLogId", participant)
+	assert.NoError(t, err)
+	assert.True(t, firstTime)
+
+	firstTime, err = scoreTx.InsertProcessedEvent("myLogId", participant)
+	assert.NoError(t, err)
+	assert.False(t, firstTime)
+}
+
+func TestDeleteProcessedEventsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced delete processed events error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteProcessedEvents)).
+		WithArgs(now).
+		WillReturnError(forcedError)
+
+	rowsAffected, err := db.DeleteProcessedEvents(now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestDeleteProcessedEvents(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteProcessedEvents)).
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	rowsAffected, err := db.DeleteProcessedEvents(now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), rowsAffected)
+}
+
+var deadLetterColumns = []string{"Id", "source_id", "payload", "error", "attempts", "created_on", "last_attempt_on"}
+
+func TestInsertDeadLetterError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced insert dead letter error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertDeadLetter)).
+		WithArgs("myLogId", "{}", "bad").
+		WillReturnError(forcedError)
+
+	assert.EqualError(t, db.InsertDeadLetter(&types.DeadLetter{SourceId: "myLogId", Payload: "{}", Error: "bad"}), forcedError.Error())
+}
+
+func TestInsertDeadLetter(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertDeadLetter)).
+		WithArgs("myLogId", "{}", "bad").
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "attempts", "created_on", "last_attempt_on"}).AddRow("deadLetterId", 2, now, now))
+
+	deadLetter := &types.DeadLetter{SourceId: "myLogId", Payload: "{}", Error: "bad"}
+	assert.NoError(t, db.InsertDeadLetter(deadLetter))
+	assert.Equal(t, &types.DeadLetter{Id: "deadLetterId", SourceId: "myLogId", Payload: "{}", Error: "bad", Attempts: 2, CreatedOn: now, LastAttemptOn: now}, deadLetter)
+}
+
+func TestSelectDeadLettersError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select dead letters error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDeadLetters)).
+		WillReturnError(forcedError)
+
+	deadLetters, err := db.SelectDeadLetters()
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, deadLetters)
+}
+
+func TestSelectDeadLetters(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDeadLetters)).
+		WillReturnRows(sqlmock.NewRows(deadLetterColumns).
+			AddRow("deadLetterId1", nil, "{bogus", "bad", 1, now, now).
+			AddRow("deadLetterId2", "myLogId", "{}", "worse", 3, now, now))
+
+	deadLetters, err := db.SelectDeadLetters()
+	assert.NoError(t, err)
+	assert.Equal(t, []types.DeadLetter{
+		{Id: "deadLetterId1", Payload: "{bogus", Error: "bad", Attempts: 1, CreatedOn: now, LastAttemptOn: now},
+		{Id: "deadLetterId2", SourceId: "myLogId", Payload: "{}", Error: "worse", Attempts: 3, CreatedOn: now, LastAttemptOn: now},
+	}, deadLetters)
+}
+
+func TestSelectDeadLetterMissing(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDeadLetter)).
+		WithArgs("deadLetterId").
+		WillReturnRows(sqlmock.NewRows(deadLetterColumns))
+
+	_, err := db.SelectDeadLetter("deadLetterId")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestSelectDeadLetter(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDeadLetter)).
+		WithArgs("deadLetterId").
+		WillReturnRows(sqlmock.NewRows(deadLetterColumns).AddRow("deadLetterId", "myLogId", "{}", "bad", 1, now, now))
+
+	deadLetter, err := db.SelectDeadLetter("deadLetterId")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.DeadLetter{Id: "deadLetterId", SourceId: "myLogId", Payload: "{}", Error: "bad", Attempts: 1, CreatedOn: now, LastAttemptOn: now}, deadLetter)
+}
+
+func TestUpdateDeadLetterAttemptError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced update dead letter error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateDeadLetterAttempt)).
+		WithArgs("deadLetterId", "still bad").
+		WillReturnError(forcedError)
+
+	rowsAffected, err := db.UpdateDeadLetterAttempt("deadLetterId", "still bad")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestUpdateDeadLetterAttempt(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateDeadLetterAttempt)).
+		WithArgs("deadLetterId", "still bad").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.UpdateDeadLetterAttempt("deadLetterId", "still bad")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestDeleteDeadLetterError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced delete dead letter error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteDeadLetter)).
+		WithArgs("deadLetterId").
+		WillReturnError(forcedError)
+
+	rowsAffected, err := db.DeleteDeadLetter("deadLetterId")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestDeleteDeadLetter(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteDeadLetter)).
+		WithArgs("deadLetterId").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteDeadLetter("deadLetterId")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- table: score_ledger
+-- append-only history of every scoring decision, used to explain how a participant's score got to its value
+CREATE TABLE score_ledger
+(
+    Id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fk_campaign     UUID references campaign (Id)                NOT NULL,
+    fk_scp          UUID references source_control_provider (Id) NOT NULL,
+    login_name      varchar(250)                                 NOT NULL,
+    source_id       TEXT,
+    repoOwner       TEXT                                         NOT NULL,
+    repoName        TEXT                                         NOT NULL,
+    pr              INT,
+    old_points      INT                                          NOT NULL,
+    new_points      INT                                          NOT NULL,
+    bug_counts      JSONB,
+    scoring_message JSONB                                        NOT NULL,
+    created_on      timestamp                                    NOT NULL DEFAULT NOW()
+);
+CREATE INDEX score_ledger_participant ON score_ledger (fk_campaign, fk_scp, login_name, created_on);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- table: processed_event
+-- source event ids (e.g. Datadog log ids) already scored for a participant, so re-read events are never applied twice
+CREATE TABLE processed_event
+(
+    source_id      TEXT      NOT NULL CHECK (source_id <> ''),
+    fk_participant UUID      NOT NULL,
+    processed_on   timestamp NOT NULL DEFAULT NOW(),
+    primary key (source_id, fk_participant)
+);
+CREATE INDEX processed_event_processed_on ON processed_event (processed_on);
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- table: dead_letter
+-- scoring events that could not be read or scored, kept for an admin to retry or discard
+CREATE TABLE dead_letter
+(
+    Id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_id       TEXT UNIQUE,
+    payload         TEXT      NOT NULL,
+    error           TEXT      NOT NULL,
+    attempts        INT       NOT NULL DEFAULT 1,
+    created_on      timestamp NOT NULL DEFAULT NOW(),
+    last_attempt_on timestamp NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- table: poll_lease
+-- only the server instance holding an unexpired lease polls for scoring events
+CREATE TABLE poll_lease
+(
+    poll_instance varchar(255) PRIMARY KEY NOT NULL REFERENCES poll (poll_instance),
+    holder_id     TEXT                     NOT NULL,
+    expires_on    timestamp                NOT NULL,
+    heartbeat_on  timestamp                NOT NULL
+);
+
+COMMIT;
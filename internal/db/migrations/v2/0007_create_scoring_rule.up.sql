@@ -0,0 +1,12 @@
+BEGIN;
+
+-- table: scoring_rule
+-- the scoring rules of a campaign, campaigns without a row use the default rules
+CREATE TABLE scoring_rule
+(
+    fk_campaign UUID PRIMARY KEY references campaign (Id) NOT NULL,
+    rules       JSONB                                     NOT NULL,
+    updated_on  timestamp                                 NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
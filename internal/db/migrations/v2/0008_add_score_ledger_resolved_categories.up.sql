@@ -0,0 +1,6 @@
+BEGIN;
+
+-- the bug category that priced each category path of the scored bug counts, "" when none did
+ALTER TABLE score_ledger ADD COLUMN resolved_categories JSONB;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- points may be fractional, e.g. a bug worth 0.5 points, and are stored with two decimal places
+ALTER TABLE bug ALTER COLUMN pointValue TYPE NUMERIC(12, 2);
+ALTER TABLE participant ALTER COLUMN Score TYPE NUMERIC(12, 2);
+ALTER TABLE scoring_event ALTER COLUMN points TYPE NUMERIC(12, 2);
+ALTER TABLE score_ledger
+    ALTER COLUMN old_points TYPE NUMERIC(12, 2),
+    ALTER COLUMN new_points TYPE NUMERIC(12, 2);
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+-- the bug counts of the latest scoring of each pull request, and when it was scored
+ALTER TABLE scoring_event ADD COLUMN bug_counts JSONB;
+ALTER TABLE scoring_event ADD COLUMN scored_on TIMESTAMP;
+
+-- fill in pull requests scored before now from their latest ledger entry
+UPDATE scoring_event
+SET bug_counts = latest.bug_counts,
+    scored_on  = latest.created_on
+FROM (SELECT DISTINCT ON (fk_campaign, fk_scp, repoOwner, repoName, pr)
+             fk_campaign, fk_scp, repoOwner, repoName, pr, bug_counts, created_on
+      FROM score_ledger
+      ORDER BY fk_campaign, fk_scp, repoOwner, repoName, pr, created_on DESC) AS latest
+WHERE scoring_event.fk_campaign = latest.fk_campaign
+  AND scoring_event.fk_scp = latest.fk_scp
+  AND scoring_event.repoOwner = latest.repoOwner
+  AND scoring_event.repoName = latest.repoName
+  AND scoring_event.pr = latest.pr;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- when the participant score last changed, so participants with the same score are ranked by who reached it first
+ALTER TABLE participant ADD COLUMN score_changed_on TIMESTAMP;
+UPDATE participant
+SET score_changed_on = COALESCE((SELECT MAX(created_on) FROM score_ledger
+                                 WHERE score_ledger.fk_campaign = participant.fk_campaign
+                                   AND score_ledger.fk_scp = participant.fk_scp
+                                   AND score_ledger.login_name = participant.login_name
+                                   AND score_ledger.old_points <> score_ledger.new_points), JoinedAt);
+ALTER TABLE participant ALTER COLUMN score_changed_on SET DEFAULT NOW();
+ALTER TABLE participant ALTER COLUMN score_changed_on SET NOT NULL;
+
+CREATE INDEX participant_leaderboard ON participant (fk_campaign, Score DESC, score_changed_on);
+
+COMMIT;
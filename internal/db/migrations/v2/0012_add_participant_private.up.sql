@@ -0,0 +1,6 @@
+BEGIN;
+
+-- private participants are shown on public pages by display name only
+ALTER TABLE participant ADD COLUMN private BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
@@ -0,0 +1,78 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// pointValueTTL is how long the point values of a campaign are cached. Bug changes made through this server invalidate
+// the cache right away, but bug changes made through another server instance are only seen once the cache expires.
+const pointValueTTL = time.Minute
+
+type cachedPointValues struct {
+	pointValues map[string]float64
+	loadedAt    time.Time
+}
+
+// pointValueCache holds the bug category point values of each campaign, so scoring a page of polled logs loads each
+// campaign's point values once, rather than querying each bug category of each message.
+type pointValueCache struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	campaign map[string]cachedPointValues
+	// generation counts the invalidations of each campaign, so a load that started before an invalidation is not cached
+	generation map[string]uint64
+}
+
+func newPointValueCache(ttl time.Duration) *pointValueCache {
+	return &pointValueCache{ttl: ttl, campaign: map[string]cachedPointValues{}, generation: map[string]uint64{}}
+}
+
+// get returns the cached point values of the campaign, unless they are missing or expired. When they are, generation
+// must be passed to put along with the newly loaded point values.
+func (c *pointValueCache) get(campaignName string, now time.Time) (pointValues map[string]float64, generation uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.campaign[campaignName]
+	if !ok || now.Sub(cached.loadedAt) >= c.ttl {
+		return nil, c.generation[campaignName], false
+	}
+	return cached.pointValues, c.generation[campaignName], true
+}
+
+// put caches the point values of the campaign, loaded after get returned generation. Point values loaded before the
+// campaign was invalidated are stale, so they are not cached.
+func (c *pointValueCache) put(campaignName string, pointValues map[string]float64, generation uint64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation[campaignName] != generation {
+		return
+	}
+	c.campaign[campaignName] = cachedPointValues{pointValues: pointValues, loadedAt: now}
+}
+
+func (c *pointValueCache) invalidate(campaignName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.campaign, campaignName)
+	c.generation[campaignName]++
+}
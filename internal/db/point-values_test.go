@@ -0,0 +1,215 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPointValueCache(t *testing.T) {
+	cache := newPointValueCache(time.Minute)
+	loadedAt := time.Now()
+
+	_, generation, ok := cache.get(campaignName, loadedAt)
+	assert.False(t, ok)
+
+	cache.put(campaignName, map[string]float64{bugCategory: 2}, generation, loadedAt)
+	cache.put("otherCampaign", map[string]float64{bugCategory: 3}, 0, loadedAt)
+	pointValues, _, ok := cache.get(campaignName, loadedAt.Add(time.Minute-time.Nanosecond))
+	assert.True(t, ok)
+	assert.Equal(t, map[string]float64{bugCategory: 2}, pointValues)
+
+	_, _, ok = cache.get(campaignName, loadedAt.Add(time.Minute))
+	assert.False(t, ok)
+
+	cache.invalidate(campaignName)
+	_, _, ok = cache.get(campaignName, loadedAt)
+	assert.False(t, ok)
+	_, _, ok = cache.get("otherCampaign", loadedAt)
+	assert.True(t, ok)
+}
+
+func TestPointValueCacheSkipsLoadStartedBeforeInvalidate(t *testing.T) {
+	cache := newPointValueCache(time.Minute)
+	loadedAt := time.Now()
+
+	_, staleGeneration, ok := cache.get(campaignName, loadedAt)
+	assert.False(t, ok)
+	// a bug changes while the point values are loading
+	cache.invalidate(campaignName)
+	cache.put(campaignName, map[string]float64{bugCategory: 2}, staleGeneration, loadedAt)
+	_, generation, ok := cache.get(campaignName, loadedAt)
+	assert.False(t, ok)
+	assert.NotEqual(t, staleGeneration, generation)
+
+	cache.put(campaignName, map[string]float64{bugCategory: 3}, generation, loadedAt)
+	pointValues, _, ok := cache.get(campaignName, loadedAt)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]float64{bugCategory: 3}, pointValues)
+}
+
+// benchRoundTrip is the simulated database round trip of each benchmark query.
+const benchRoundTrip = 100 * time.Microsecond
+
+// benchCategories are the bug categories of the benchmark campaign, and the categories fixed by each benchmark message.
+var benchCategories = []string{"G104", "G304", "ShellCheck", "NullAway", "node_password"}
+
+// benchPageSize is the number of logs in a page of polled logs.
+const benchPageSize = 500
+
+// sqlSelectPointValue is how a point value was read before point values were batched: one query per bug category.
+const sqlSelectPointValue = `SELECT pointValue FROM bug
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1) AND category = $2`
+
+// BenchmarkScorePagePointValuePerCategory prices each bug category of each message of a page with its own query.
+func BenchmarkScorePagePointValuePerCategory(b *testing.B) {
+	pg, queries := openBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for msg := 0; msg < benchPageSize; msg++ {
+			for _, category := range benchCategories {
+				var pointValue float64
+				if err := pg.QueryRow(sqlSelectPointValue, campaignName, category).Scan(&pointValue); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(queries))/float64(b.N), "queries/op")
+}
+
+// BenchmarkScorePageSelectPointValues prices each bug category of each message of a page from the cached point values.
+func BenchmarkScorePageSelectPointValues(b *testing.B) {
+	pg, queries := openBenchDB(b)
+	db := New(pg, zap.NewNop())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// a new page, polled once the point values have expired
+		db.pointValues.invalidate(campaignName)
+		for msg := 0; msg < benchPageSize; msg++ {
+			pointValues, err := db.SelectPointValues(campaignName)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, category := range benchCategories {
+				_ = pointValues[category]
+			}
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(queries))/float64(b.N), "queries/op")
+}
+
+var benchDriverCount int64
+
+// openBenchDB opens a database of the benchCategories, each worth one point, which counts its queries.
+func openBenchDB(b *testing.B) (pg *sql.DB, queries *int64) {
+	queries = new(int64)
+	driverName := fmt.Sprintf("pointValueBench%d", atomic.AddInt64(&benchDriverCount, 1))
+	sql.Register(driverName, benchDriver{queries: queries})
+	pg, err := sql.Open(driverName, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		_ = pg.Close()
+	})
+	return
+}
+
+type benchDriver struct {
+	queries *int64
+}
+
+func (d benchDriver) Open(string) (driver.Conn, error) {
+	return benchConn(d), nil
+}
+
+type benchConn benchDriver
+
+func (c benchConn) Prepare(string) (driver.Stmt, error) {
+	return benchStmt(c), nil
+}
+
+func (c benchConn) Close() error {
+	return nil
+}
+
+func (c benchConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+type benchStmt benchConn
+
+func (s benchStmt) Close() error {
+	return nil
+}
+
+func (s benchStmt) NumInput() int {
+	return -1
+}
+
+func (s benchStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("exec is not supported")
+}
+
+// Query returns the point value of the category when given a campaign and a category, and otherwise every category
+// with its point value.
+func (s benchStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt64(s.queries, 1)
+	time.Sleep(benchRoundTrip)
+	if len(args) == 2 {
+		return &benchRows{columns: []string{"pointValue"}, values: [][]driver.Value{{int64(1)}}}, nil
+	}
+	rows := &benchRows{columns: []string{"category", "pointValue"}}
+	for _, category := range benchCategories {
+		rows.values = append(rows.values, []driver.Value{category, int64(1)})
+	}
+	return rows, nil
+}
+
+type benchRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *benchRows) Columns() []string {
+	return r.columns
+}
+
+func (r *benchRows) Close() error {
+	return nil
+}
+
+func (r *benchRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,135 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package leaderboard
+
+import (
+	"errors"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sync"
+)
+
+// ErrTooManySubscribers is returned by Subscribe when the broker already has as many subscribers as it allows.
+var ErrTooManySubscribers = errors.New("too many leaderboard subscribers")
+
+// ErrClosed is returned by Subscribe once the broker is closed.
+var ErrClosed = errors.New("leaderboard broker is closed")
+
+// subscriberBuffer is how many deltas a subscriber may fall behind by before it is dropped.
+const subscriberBuffer = 64
+
+// Subscription receives the leaderboard deltas of a campaign, until it is dropped.
+type Subscription struct {
+	campaignName string
+	deltas       chan types.LeaderboardDelta
+}
+
+// Deltas is closed when the subscription is dropped: on Unsubscribe, when the subscriber falls too far behind, or when
+// the broker is closed.
+func (s *Subscription) Deltas() <-chan types.LeaderboardDelta {
+	return s.deltas
+}
+
+// Broker publishes leaderboard deltas to the subscribers of each campaign, within this server only.
+type Broker struct {
+	maxSubscribers int
+
+	mu          sync.Mutex
+	closed      bool
+	count       int
+	subscribers map[string]map[*Subscription]bool
+}
+
+func NewBroker(maxSubscribers int) *Broker {
+	return &Broker{maxSubscribers: maxSubscribers, subscribers: map[string]map[*Subscription]bool{}}
+}
+
+// Subscribe starts receiving the deltas of the campaign. Every subscription must be ended by Unsubscribe.
+func (b *Broker) Subscribe(campaignName string) (s *Subscription, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	if b.count >= b.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	s = &Subscription{campaignName: campaignName, deltas: make(chan types.LeaderboardDelta, subscriberBuffer)}
+	if b.subscribers[campaignName] == nil {
+		b.subscribers[campaignName] = map[*Subscription]bool{}
+	}
+	b.subscribers[campaignName][s] = true
+	b.count++
+	return
+}
+
+// Unsubscribe ends the subscription, unless it was already dropped.
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(s)
+}
+
+func (b *Broker) drop(s *Subscription) {
+	if !b.subscribers[s.campaignName][s] {
+		return
+	}
+	delete(b.subscribers[s.campaignName], s)
+	if len(b.subscribers[s.campaignName]) == 0 {
+		delete(b.subscribers, s.campaignName)
+	}
+	b.count--
+	close(s.deltas)
+}
+
+// Publish sends the delta to each subscriber of its campaign, without waiting on any of them. A subscriber that fell
+// too far behind is dropped instead, so it reconnects and reloads the leaderboard rather than silently miss deltas.
+func (b *Broker) Publish(delta types.LeaderboardDelta) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers[delta.CampaignName] {
+		select {
+		case s.deltas <- delta:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// SubscriberCount is the number of subscribers of all campaigns.
+func (b *Broker) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// Close drops every subscriber, and refuses new ones.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, campaignSubscribers := range b.subscribers {
+		for s := range campaignSubscribers {
+			b.drop(s)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package leaderboard
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSubscribeTooMany(t *testing.T) {
+	b := NewBroker(1)
+	s, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+
+	_, err = b.Subscribe("otherCampaign")
+	assert.Equal(t, ErrTooManySubscribers, err)
+
+	b.Unsubscribe(s)
+	_, err = b.Subscribe("otherCampaign")
+	assert.NoError(t, err)
+}
+
+func TestPublishToCampaignSubscribers(t *testing.T) {
+	b := NewBroker(3)
+	first, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+	second, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+	other, err := b.Subscribe("otherCampaign")
+	assert.NoError(t, err)
+
+	delta := types.LeaderboardDelta{CampaignName: "myCampaign", LoginName: "myLogin", OldScore: 1, Score: 3}
+	b.Publish(delta)
+	assert.Equal(t, delta, <-first.Deltas())
+	assert.Equal(t, delta, <-second.Deltas())
+	assert.Equal(t, 0, len(other.Deltas()))
+}
+
+func TestPublishDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(2)
+	slow, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(types.LeaderboardDelta{CampaignName: "myCampaign", Score: float64(i)})
+	}
+	assert.Equal(t, 0, b.SubscriberCount())
+	for i := 0; i < subscriberBuffer; i++ {
+		assert.Equal(t, float64(i), (<-slow.Deltas()).Score)
+	}
+	_, open := <-slow.Deltas()
+	assert.False(t, open)
+
+	// already dropped
+	b.Unsubscribe(slow)
+	assert.Equal(t, 0, b.SubscriberCount())
+}
+
+func TestUnsubscribe(t *testing.T) {
+	b := NewBroker(1)
+	s, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, b.SubscriberCount())
+
+	b.Unsubscribe(s)
+	assert.Equal(t, 0, b.SubscriberCount())
+	_, open := <-s.Deltas()
+	assert.False(t, open)
+
+	b.Publish(types.LeaderboardDelta{CampaignName: "myCampaign"})
+}
+
+func TestClose(t *testing.T) {
+	b := NewBroker(2)
+	s, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+
+	b.Close()
+	assert.Equal(t, 0, b.SubscriberCount())
+	_, open := <-s.Deltas()
+	assert.False(t, open)
+
+	_, err = b.Subscribe("myCampaign")
+	assert.Equal(t, ErrClosed, err)
+}
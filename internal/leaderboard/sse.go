@@ -0,0 +1,62 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package leaderboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EventScore is the server-sent event name of a leaderboard delta.
+const EventScore = "score"
+
+// Serve streams the deltas of the subscription as server-sent events, flushing each one. A comment line is sent every
+// heartbeat interval, so idle connections are not closed by a load balancer in between. Serve returns once the context
+// is done (the client went away), or the subscription is dropped.
+func Serve(ctx context.Context, s *Subscription, w io.Writer, flush func(), heartbeatInterval time.Duration) (err error) {
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delta, open := <-s.Deltas():
+			if !open {
+				return
+			}
+			var data []byte
+			if data, err = json.Marshal(delta); err != nil {
+				return
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EventScore, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err = io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flush()
+	}
+}
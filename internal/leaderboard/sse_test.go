@@ -0,0 +1,74 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package leaderboard
+
+import (
+	"bytes"
+	"context"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeDeltas(t *testing.T) {
+	b := NewBroker(1)
+	s, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+	b.Publish(types.LeaderboardDelta{CampaignName: "myCampaign", ScpName: "GitHub", LoginName: "myLogin", OldScore: 1, Score: 2.5})
+	b.Unsubscribe(s)
+
+	var w bytes.Buffer
+	flushes := 0
+	assert.NoError(t, Serve(context.Background(), s, &w, func() { flushes++ }, time.Hour))
+	assert.Equal(t, "event: score\n"+
+		`data: {"campaignName":"myCampaign","scpName":"GitHub","loginName":"myLogin","oldScore":1,"score":2.5}`+"\n\n", w.String())
+	assert.Equal(t, 1, flushes)
+}
+
+func TestServeHeartbeat(t *testing.T) {
+	b := NewBroker(1)
+	s, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+	defer b.Unsubscribe(s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var w bytes.Buffer
+	assert.NoError(t, Serve(ctx, s, &w, func() {
+		// stop after the first heartbeat, though another may be sent before the cancel is seen
+		cancel()
+	}, time.Millisecond))
+	assert.True(t, strings.HasPrefix(w.String(), ": heartbeat\n\n"), w.String())
+	assert.Equal(t, "", strings.ReplaceAll(w.String(), ": heartbeat\n\n", ""))
+}
+
+func TestServeClientGone(t *testing.T) {
+	b := NewBroker(1)
+	s, err := b.Subscribe("myCampaign")
+	assert.NoError(t, err)
+	defer b.Unsubscribe(s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var w bytes.Buffer
+	assert.NoError(t, Serve(ctx, s, &w, func() {}, time.Hour))
+	assert.Equal(t, "", w.String())
+}
@@ -21,7 +21,10 @@ package poll
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
@@ -35,7 +38,14 @@ import (
 	"time"
 )
 
-var logger *zap.Logger
+// logger is set once by UseLogger, before polling begins and requests are served, since both polling and request
+// handlers (e.g. imports) read it.
+var logger = zap.NewNop()
+
+// UseLogger replaces the logger used by polling and parsing. Call it before polling begins.
+func UseLogger(useLogger *zap.Logger) {
+	logger = useLogger
+}
 
 var dogApiClient IDogApiClient
 
@@ -44,7 +54,7 @@ func init() {
 }
 
 type IDogApiClient interface {
-	getDDApiClient() (context.Context, *datadog.APIClient)
+	getDDApiClient(parent context.Context) (context.Context, *datadog.APIClient)
 }
 
 type DogApiClient struct {
@@ -52,9 +62,9 @@ type DogApiClient struct {
 
 var _ IDogApiClient = (*DogApiClient)(nil)
 
-func (c *DogApiClient) getDDApiClient() (context.Context, *datadog.APIClient) {
+func (c *DogApiClient) getDDApiClient(parent context.Context) (context.Context, *datadog.APIClient) {
 	ctx := context.WithValue(
-		context.Background(),
+		parent,
 		datadog.ContextAPIKeys,
 		map[string]datadog.APIKey{
 			// API Key
@@ -83,7 +93,52 @@ const qryFldFixedBugs = "fixed-bugs"
 // should be negative
 const pollFudgeSeconds = -5
 
-func pollTheDog(pollDB db.IDBPoll, priorPollTime, now time.Time) (logs []ddLog, err error) {
+// maxPollSlice is the longest time window fetched by a single poll. After a long outage, polling catches up one slice
+// at a time, and the last polled time is committed as each slice is processed, so a failure does not restart the backlog.
+const maxPollSlice = time.Hour
+
+// pollLease is the lease polling is done under, which is renewed as polling goes, and must still be held to commit
+// the last polled time.
+type pollLease struct {
+	pollId   string
+	holderId string
+	ttl      time.Duration
+}
+
+// errLeaseLost stops polling part way through, when another instance has taken over the lease
+var errLeaseLost = errors.New("poll lease lost")
+
+// renew extends the lease, and is errLeaseLost when another instance has taken it over
+func (l pollLease) renew(pollDb db.IDBPoll) (err error) {
+	var held bool
+	held, err = pollDb.AcquireLease(l.pollId, l.holderId, l.ttl)
+	if err != nil {
+		logger.Error("error renewing poll lease", zap.Error(err))
+		return
+	}
+	tracker.setLeaseHeld(held)
+	if !held {
+		err = errLeaseLost
+	}
+	return
+}
+
+// pageResume is where to carry on polling, when fetching a page failed part way through a poll window
+type pageResume struct {
+	from   time.Time
+	to     time.Time
+	cursor string
+}
+
+// pollTheDog fetches the events of the next slice of the poll window, processes them, and only then commits the slice
+// end as the last polled time. caughtUp is false while slices remain before now. When a page fails, the events of the
+// prior pages are still processed, and resume is set so the next poll carries on from the failed page, instead of
+// starting over. When processing fails (i.e. a failed event could not be dead lettered), the slice is not committed,
+// so its events are polled again. Cancelling the context stops the fetch, just like a failed page. The lease is renewed
+// before each further page, and the slice is only committed while the lease is still held, otherwise errLeaseLost
+// is returned.
+func pollTheDog(ctx context.Context, pollDB db.IDBPoll, source ScoreSource, lease pollLease, priorPollTime, now time.Time, resume *pageResume,
+	process func(events []ScoreEvent) error) (polledTo time.Time, caughtUp bool, err error) {
 
 	// get last poll time from database
 	poll := pollDB.NewPoll()
@@ -104,48 +159,158 @@ func pollTheDog(pollDB db.IDBPoll, priorPollTime, now time.Time) (logs []ddLog,
 	// fudge factor, always poll a little older than last poll, to make sure no scores are missed
 	before = before.Add(time.Second * pollFudgeSeconds)
 
+	sliceEnd := now
+	if before.Add(maxPollSlice).Before(now) {
+		sliceEnd = before.Add(maxPollSlice)
+	}
 	pageCursor := ""
+	if resume.cursor != "" {
+		before, sliceEnd, pageCursor = resume.from, resume.to, resume.cursor
+		logger.Info("resuming poll",
+			zap.String("before", before.Format(time.RFC3339)),
+			zap.String("sliceEnd", sliceEnd.Format(time.RFC3339)),
+		)
+	}
+	*resume = pageResume{}
+
+	var events []ScoreEvent
 	isDone := false
 	var totalFetchDuration time.Duration
-	for err == nil && isDone == false {
-		var logPage []ddLog
-		var fetchDuration time.Duration
-		isDone, pageCursor, logPage, fetchDuration, err = fetchLogPage(before, now, &pageCursor)
+	for firstPage := true; err == nil && isDone == false; firstPage = false {
+		if !firstPage {
+			// a slice may have many pages, so keep the lease from expiring while they are fetched
+			if err = lease.renew(pollDB); err != nil {
+				return
+			}
+		}
+		var page []ScoreEvent
+		fetchStart := time.Now()
+		var nextCursor string
+		page, nextCursor, err = source.FetchPage(ctx, before, sliceEnd, pageCursor)
 		if err != nil {
+			*resume = pageResume{from: before, to: sliceEnd, cursor: pageCursor}
+			// score the pages fetched before the error, the next poll resumes after them
+			if processErr := process(events); processErr != nil {
+				logger.Error("error processing events fetched before poll error", zap.Error(processErr))
+				// some of those events were lost, so start the slice over
+				*resume = pageResume{}
+			}
 			return
 		}
+		pageCursor = nextCursor
+		isDone = pageCursor == ""
 
-		logs = append(logs, logPage...)
-		totalFetchDuration = totalFetchDuration + fetchDuration
+		events = append(events, page...)
+		totalFetchDuration = totalFetchDuration + time.Since(fetchStart)
 	}
 
-	logCount := len(logs)
+	eventCount := len(events)
+	caughtUp = !sliceEnd.Before(now)
+	tracker.polled(before, sliceEnd, eventCount, now)
 	logger.Debug("totalPolled",
-		zap.Int("logCount", logCount),
+		zap.Int("logCount", eventCount),
 		zap.String("before", before.Format(time.RFC3339)),
-		zap.String("now", now.Format(time.RFC3339)),
+		zap.String("sliceEnd", sliceEnd.Format(time.RFC3339)),
 		zap.Duration("totalFetchDuration", totalFetchDuration),
-		zap.Int("maxLogsPerPage", maxLogsPerPage),
 	)
+	if !caughtUp {
+		logger.Info("catching up poll",
+			zap.String("polledTo", sliceEnd.Format(time.RFC3339)),
+			zap.Duration("remaining", now.Sub(sliceEnd)),
+		)
+	}
 
-	// Update Poll completed time
-	poll.LastPolled = now
-	if logCount > 0 {
-		poll.EnvBaseTime = logs[logCount-1].Fields.envBaseTime
+	// failed messages are dead lettered, so a processing error means an event was lost, and the slice must be polled again
+	err = process(events)
+	if err != nil {
+		return
+	}
+
+	// Update Poll completed time, now the slice is processed
+	poll.LastPolled = sliceEnd
+	if eventCount > 0 {
+		poll.EnvBaseTime = events[eventCount-1].BaseTime
 	}
 	poll.LastPollCompleted = time.Now()
-	err = pollDB.UpdatePoll(&poll)
+	var held bool
+	held, err = pollDB.UpdatePollHeld(&poll, lease.holderId)
 	if err != nil {
 		return
 	}
+	if !held {
+		// another instance took over while this slice was processed, so it polls from the last committed time
+		err = errLeaseLost
+		return
+	}
+	polledTo = sliceEnd
+	return
+}
+
+// DatadogSource is the ScoreSource that reads Lift scoring logs from Datadog.
+type DatadogSource struct {
+	retry retryPolicy
+}
+
+var _ ScoreSource = (*DatadogSource)(nil)
+
+func NewDatadogSource() *DatadogSource {
+	return &DatadogSource{retry: defaultRetryPolicy()}
+}
 
+// FetchPage retries a page that failed due to rate limiting or a Datadog error, and waits out an exhausted rate limit
+// before the next page is fetched. Cancelling the context ends any wait, and the request in flight.
+func (s *DatadogSource) FetchPage(ctx context.Context, from, to time.Time, cursor string) (events []ScoreEvent, nextCursor string, err error) {
+	var logs []ddLog
+	var fetchDuration time.Duration
+	var limit rateLimit
+	for attempt := 0; ; attempt++ {
+		_, nextCursor, logs, fetchDuration, limit, err = fetchLogPage(ctx, from, to, &cursor)
+		if err == nil {
+			break
+		}
+		if !isRetryable(err) || attempt >= s.retry.maxRetries {
+			return
+		}
+		delay := s.retry.delay(attempt, limit)
+		logger.Warn("retrying datadog page",
+			zap.Error(err),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+		)
+		if err = s.retry.sleep(ctx, delay); err != nil {
+			return
+		}
+	}
+	logger.Debug("fetched datadog page",
+		zap.Int("logCount", len(logs)),
+		zap.Duration("fetchDuration", fetchDuration),
+		zap.Int("maxLogsPerPage", maxLogsPerPage),
+		zap.Int("rateLimitRemaining", limit.remaining),
+	)
+	if nextCursor != "" && limit.exhausted() {
+		logger.Info("waiting for datadog rate limit reset", zap.Duration("reset", limit.reset))
+		if err = s.retry.sleep(ctx, limit.reset); err != nil {
+			return
+		}
+	}
+
+	for _, log := range logs {
+		events = append(events, ScoreEvent{
+			Id:             log.Id,
+			BaseTime:       log.Fields.envBaseTime,
+			ScoringMessage: log.Fields.scoringMessage,
+			ParseErr:       log.parseErr,
+			Raw:            log.raw,
+		})
+	}
 	return
 }
 
 const maxLogsPerPage = 500
 
-func fetchLogPage(before, now time.Time, pageCursor *string) (isDone bool, cursor string, logs []ddLog, fetchDuration time.Duration, err error) {
-	ctx, apiClient := dogApiClient.getDDApiClient()
+// fetchLogPage returns a retryableError when trying again later may work, along with any rate limit of the response.
+func fetchLogPage(parent context.Context, before, now time.Time, pageCursor *string) (isDone bool, cursor string, logs []ddLog, fetchDuration time.Duration, limit rateLimit, err error) {
+	ctx, apiClient := dogApiClient.getDDApiClient(parent)
 
 	var pageAttribs *datadog.LogsListRequestPage
 	if *pageCursor == "" {
@@ -159,28 +324,36 @@ func fetchLogPage(before, now time.Time, pageCursor *string) (isDone bool, curso
 		}
 	}
 
+	filter := &datadog.LogsQueryFilter{
+		Query: datadog.PtrString(logMapping.Query),
+		From:  datadog.PtrString(before.Format(time.RFC3339)),
+		To:    datadog.PtrString(now.Format(time.RFC3339)),
+	}
+	if len(logMapping.Indexes) > 0 {
+		filter.Indexes = &logMapping.Indexes
+	}
 	body := datadog.LogsListRequest{
-		Filter: &datadog.LogsQueryFilter{
-			Query: datadog.PtrString(fmt.Sprintf("@%s.%s.%s:>0 kube_namespace:lift-production-lift", qryEnv, qryEnvExtraJsonFields, qryFldFixedBugs)),
-			//Indexes: &[]string{
-			//	"main",
-			//},
-			From: datadog.PtrString(before.Format(time.RFC3339)),
-			To:   datadog.PtrString(now.Format(time.RFC3339)),
-		},
-		Sort: datadog.LOGSSORT_TIMESTAMP_ASCENDING.Ptr(),
-		Page: pageAttribs,
+		Filter: filter,
+		Sort:   datadog.LOGSSORT_TIMESTAMP_ASCENDING.Ptr(),
+		Page:   pageAttribs,
 	}
 	var resp datadog.LogsListResponse
 	var r *http.Response
 	fetchStart := time.Now()
 	resp, r, err = apiClient.LogsApi.ListLogs(ctx, *datadog.NewListLogsOptionalParameters().WithBody(body))
+	limit = readRateLimit(r)
 	if err != nil {
 		logger.Error("error calling datadog api",
 			zap.Error(err),
 			// logging resp causes error: "json: unsupported type: func() (io.ReadCloser, error)"
 			//zap.Any("http response", r),
 		)
+		if isRetryableResponse(r) {
+			err = &retryableError{err: err}
+		}
+		if r == nil {
+			return
+		}
 		dump, errDump := httputil.DumpResponse(r, true)
 		if errDump != nil {
 			return
@@ -208,7 +381,7 @@ func fetchLogPage(before, now time.Time, pageCursor *string) (isDone bool, curso
 	switch status {
 	case datadog.LOGSAGGREGATERESPONSESTATUS_TIMEOUT:
 		logger.Debug("status", zap.Any("status", status))
-		err = fmt.Errorf("timeout getting scoring page. %+v", status)
+		err = &retryableError{err: fmt.Errorf("timeout getting scoring page. %+v", status)}
 		return
 	case datadog.LOGSAGGREGATERESPONSESTATUS_DONE:
 		isDone = true
@@ -229,58 +402,86 @@ func fetchLogPage(before, now time.Time, pageCursor *string) (isDone bool, curso
 	}
 	responseData := resp.GetData()
 
-	logs, err = processResponseData(responseData)
+	logs = processResponsePage(responseData)
+
+	return
+}
 
+// processResponsePage parses each log on its own, so one bad log does not stop the rest of the page from being scored.
+// Logs that can not be parsed are returned with their parse error and raw payload.
+func processResponsePage(responseData []datadog.Log) (logs []ddLog) {
+	for _, log := range responseData {
+		if log.Id == nil {
+			log.Id = datadog.PtrString("")
+		}
+		parsed, parseErr := processResponseData([]datadog.Log{log})
+		if parseErr == nil {
+			logs = append(logs, parsed...)
+			continue
+		}
+		logger.Error("error parsing datadog log", zap.String("logId", *log.Id), zap.Error(parseErr))
+		raw, marshalErr := json.Marshal(log)
+		if marshalErr != nil {
+			raw = []byte(fmt.Sprintf("%+v", log))
+		}
+		logs = append(logs, ddLog{Id: *log.Id, parseErr: parseErr, raw: raw})
+	}
 	return
 }
 
 const jsonErrBadPullRequestID = "json: cannot unmarshal string into Go struct field ScoringMessage.pullRequestId of type int"
 const bogusPRidPrefix = "PullRequestId "
 
+// processResponseData reads each log using the logMapping. Other attributes of the log are ignored.
 func processResponseData(responseData []datadog.Log) (logs []ddLog, err error) {
 	for _, log := range responseData {
 		logStruct := ddLog{
 			Id: *log.Id,
 		}
 
-		attribEnv := log.Attributes.GetAttributes()[qryEnv]
-		mapAttribEnv, ok := attribEnv.(map[string]interface{})
-		if !ok {
-			err = fmt.Errorf("unexpected attribute map type in %+v", log.Attributes.GetAttributes())
+		attributes := log.Attributes.GetAttributes()
+		loggedMessage, messageParent := attributeAt(attributes, logMapping.MessagePath)
+		if messageParent == nil {
+			err = fmt.Errorf("unexpected attribute map type in %+v", attributes)
 			return
 		}
 		extra := extraFields{}
-		for key, value := range mapAttribEnv {
-			switch key {
-			case qryEnvBaseTime:
-				var baseTime time.Time
-				baseTime, err = time.Parse(time.RFC3339, value.(string))
-				if err != nil {
-					return
-				}
-				extra.envBaseTime = baseTime
-			case qryEnvExtraJsonFields:
-				valueMap := value.(map[string]interface{})
-				err = parseExtraJsonFields(valueMap, &extra)
-				if err != nil {
-					logger.Error("error unmarshalling scoring message", zap.Error(err), zap.Any("valueMap", valueMap))
-					// handle special case were bogus test data made it into production on 5/16/2022 - non-numeric pull request id
-					// see: https://issues.sonatype.org/browse/LIFT-3230
-					if strings.Contains(err.Error(), jsonErrBadPullRequestID) {
-						err = applyDuctTapeToScoringMessage(valueMap, &extra)
-						if err != nil {
-							return
-						}
-					} else {
+		if baseTimeValue, _ := attributeAt(attributes, logMapping.BaseTimePath); baseTimeValue != nil {
+			baseTimeText, isText := baseTimeValue.(string)
+			if !isText {
+				err = fmt.Errorf("unexpected base time type %T at %s", baseTimeValue, logMapping.BaseTimePath)
+				return
+			}
+			var baseTime time.Time
+			baseTime, err = time.Parse(time.RFC3339, baseTimeText)
+			if err != nil {
+				return
+			}
+			extra.envBaseTime = baseTime
+		}
+		if loggedMessage != nil {
+			loggedValues, isMap := loggedMessage.(map[string]interface{})
+			if !isMap {
+				err = fmt.Errorf("unexpected scoring message type %T at %s", loggedMessage, logMapping.MessagePath)
+				return
+			}
+			valueMap := logMapping.messageValues(loggedValues)
+			err = parseExtraJsonFields(valueMap, &extra)
+			if err != nil {
+				logger.Error("error unmarshalling scoring message", zap.Error(err), zap.Any("valueMap", valueMap))
+				// handle special case were bogus test data made it into production on 5/16/2022 - non-numeric pull request id
+				// see: https://issues.sonatype.org/browse/LIFT-3230
+				if strings.Contains(err.Error(), jsonErrBadPullRequestID) {
+					err = applyDuctTapeToScoringMessage(valueMap, &extra)
+					if err != nil {
 						return
 					}
+				} else {
+					return
 				}
-			default:
-				err = fmt.Errorf("unexpected extra field key: %s", key)
-				return
 			}
-			logStruct.Fields = extra
 		}
+		logStruct.Fields = extra
 
 		logs = append(logs, logStruct)
 	}
@@ -289,7 +490,12 @@ func processResponseData(responseData []datadog.Log) (logs []ddLog, err error) {
 
 func applyDuctTapeToScoringMessage(valueMap map[string]interface{}, extra *extraFields) (err error) {
 	// try mangling/fixing the PR ID and other fields, and parse again
-	badPRId := valueMap["pullRequestId"].(string)
+	badPRId, isText := valueMap["pullRequestId"].(string)
+	if !isText {
+		err = fmt.Errorf("unexpected PR id type: %T", valueMap["pullRequestId"])
+		logger.Error("invalid PR id", zap.Error(err), zap.Any("valueMap", valueMap))
+		return
+	}
 	if strings.HasPrefix(badPRId, bogusPRidPrefix) {
 		goodPRId := badPRId[len(bogusPRidPrefix):]
 		var realPRid float64
@@ -300,9 +506,11 @@ func applyDuctTapeToScoringMessage(valueMap map[string]interface{}, extra *extra
 		valueMap["pullRequestId"] = realPRid
 
 		// remove extra quotes
-		trimQuotes(valueMap, "eventSource")
-		trimQuotes(valueMap, "repositoryOwner")
-		trimQuotes(valueMap, "repositoryName")
+		for _, fieldName := range []string{"eventSource", "repositoryOwner", "repositoryName"} {
+			if err = trimQuotes(valueMap, fieldName); err != nil {
+				return
+			}
+		}
 
 		err = parseExtraJsonFields(valueMap, extra)
 		if err != nil {
@@ -332,8 +540,18 @@ func parseExtraJsonFields(valueMap map[string]interface{}, extra *extraFields) (
 	return
 }
 
-func trimQuotes(valueMap map[string]interface{}, fieldName string) {
-	valueMap[fieldName] = strings.Trim(valueMap[fieldName].(string), "\"")
+// trimQuotes removes the quotes around a text field. A missing field is left missing.
+func trimQuotes(valueMap map[string]interface{}, fieldName string) (err error) {
+	value, present := valueMap[fieldName]
+	if !present || value == nil {
+		return
+	}
+	text, isText := value.(string)
+	if !isText {
+		return fmt.Errorf("unexpected %s type: %T", fieldName, value)
+	}
+	valueMap[fieldName] = strings.Trim(text, "\"")
+	return
 }
 
 type extraFields struct {
@@ -344,51 +562,79 @@ type extraFields struct {
 type ddLog struct {
 	Id     string
 	Fields extraFields
+	// parseErr and raw are only set for a log that could not be parsed
+	parseErr error
+	raw      []byte
+}
+
+// leaseIntervals is the number of poll intervals a poll lease lasts without a heartbeat, before another instance
+// may take over polling.
+const leaseIntervals = 3
+
+// NewLeaseHolderId identifies this server instance as a poll lease holder.
+func NewLeaseHolderId() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
 }
 
-// ChaseTail will loop every given interval, polling dataDog for new scoring data
-func ChaseTail(pollDb db.IDBPoll, scoreDb db.IScoreDB, seconds time.Duration, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (pollErr error)) (quit chan bool, errChan chan error) {
-	logger = pollDb.GetLogger()
-	logger.Info("poll ticker starting", zap.Duration("chase tail seconds", seconds))
+// ChaseTail will loop every given interval, polling the source for new scoring data. Many server instances may chase
+// the tail, but only the holder of the poll lease actually polls. Each poll renews the lease, which is the heartbeat.
+// Polling stops when the context is cancelled, which also ends any in-flight fetch. Errors are kept in the poll Status,
+// and the last one is sent on done once stopped.
+func ChaseTail(ctx context.Context, pollDb db.IDBPoll, scoreDb db.IScoreDB, source ScoreSource, seconds time.Duration, holderId string, scoreMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error)) (done chan error) {
+	logger.Info("poll ticker starting", zap.Duration("chase tail seconds", seconds), zap.String("holderId", holderId))
 	ticker := time.NewTicker(seconds * time.Second)
-	quit = make(chan bool)
+	lease := pollLease{pollId: pollDb.NewPoll().Id, holderId: holderId, ttl: leaseIntervals * seconds * time.Second}
 
-	const errBufferSize = 100
-	errChan = make(chan error, errBufferSize)
-	var errCount int
+	done = make(chan error, 1)
 	priorPollTime := time.Now()
+	var resume pageResume
+	tracker.started(seconds * time.Second)
 	go func() {
 		var pollErr error
 		for {
 			select {
 			case <-ticker.C:
 				now := time.Now()
-				var logs []ddLog
-				logs, pollErr = pollTheDog(pollDb, priorPollTime, now)
+				var held bool
+				held, pollErr = pollDb.AcquireLease(lease.pollId, holderId, lease.ttl)
 				if pollErr != nil {
-					logger.Error("error in polling chase", zap.Error(pollErr))
-					errCount++
-					if errCount < errBufferSize {
-						errChan <- pollErr
-					}
+					logger.Error("error acquiring poll lease", zap.Error(pollErr))
+					tracker.addError(now, pollErr)
 					continue // continue allows polling to keep running when errors occur
 				}
-				// track actual poll time to avoid db write oddness
-				priorPollTime = now
+				tracker.setLeaseHeld(held)
+				if !held {
+					logger.Debug("poll lease held by another instance", zap.String("holderId", holderId))
+					// another instance is polling, so the db poll time is the one to use if we take over
+					priorPollTime = now
+					resume = pageResume{}
+					continue
+				}
 
-				pollErr = processLogs(scoreDb, logs, now, processScoringMessage)
+				pollErr = catchUp(ctx, pollDb, scoreDb, source, lease, &priorPollTime, now, &resume, scoreMessage)
+				if pollErr != nil && ctx.Err() != nil {
+					// stopped part way through a fetch, which is not a poll error. the next poll resumes from that page.
+					pollErr = nil
+					continue
+				}
 				if pollErr != nil {
-					logger.Error("error in process logs chase", zap.Error(pollErr))
-					errCount++
-					if errCount < errBufferSize {
-						errChan <- pollErr
-					}
+					tracker.addError(now, pollErr)
 					continue // continue allows polling to keep running when errors occur
 				}
-			case <-quit:
+			case <-ctx.Done():
 				ticker.Stop()
+				if releaseErr := pollDb.ReleaseLease(lease.pollId, holderId); releaseErr != nil {
+					logger.Error("error releasing poll lease", zap.Error(releaseErr))
+				}
+				tracker.stopped()
 				logger.Info("poll ticker stopped", zap.Error(pollErr))
-				errChan <- pollErr
+				done <- pollErr
 				return
 			}
 		}
@@ -396,13 +642,83 @@ func ChaseTail(pollDb db.IDBPoll, scoreDb db.IScoreDB, seconds time.Duration, pr
 	return
 }
 
-func processLogs(scoreDb db.IScoreDB, logs []ddLog, nowPoll time.Time, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error)) (err error) {
-	for _, log := range logs {
-		msg := log.Fields.scoringMessage
-		err = processScoringMessage(scoreDb, nowPoll, &msg)
+// catchUp polls slice by slice until caught up with now, renewing the lease before each further slice. It stops early
+// when the context is cancelled, leaving the remaining slices for the next poll, or when the lease is lost, leaving
+// the rest to the instance that took it over.
+func catchUp(ctx context.Context, pollDb db.IDBPoll, scoreDb db.IScoreDB, source ScoreSource, lease pollLease,
+	priorPollTime *time.Time, now time.Time, resume *pageResume,
+	scoreMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error)) (err error) {
+
+	process := func(events []ScoreEvent) error {
+		return processLogs(scoreDb, events, now, scoreMessage)
+	}
+	for caughtUp := false; !caughtUp; {
+		var polledTo time.Time
+		polledTo, caughtUp, err = pollTheDog(ctx, pollDb, source, lease, *priorPollTime, now, resume, process)
+		if !polledTo.IsZero() {
+			// track actual poll time to avoid db write oddness
+			*priorPollTime = polledTo
+		}
+		if err == nil && !caughtUp && ctx.Err() == nil {
+			err = lease.renew(pollDb)
+		}
+		if errors.Is(err, errLeaseLost) {
+			logger.Info("poll lease lost while catching up", zap.String("holderId", lease.holderId))
+			tracker.setLeaseHeld(false)
+			// the instance that took over polls on from the db poll time, which is the one to use if we take it back
+			*priorPollTime = now
+			*resume = pageResume{}
+			err = nil
+			return
+		}
 		if err != nil {
+			logger.Error("error in polling chase", zap.Error(err))
+			return
+		}
+		if caughtUp || ctx.Err() != nil {
 			return
 		}
 	}
 	return
 }
+
+func processLogs(scoreDb db.IScoreDB, events []ScoreEvent, nowPoll time.Time, scoreMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error)) (err error) {
+	for _, event := range events {
+		if event.ParseErr != nil {
+			tracker.processed(0, event.ParseErr)
+			err = firstError(err, deadLetter(scoreDb, event, event.ParseErr))
+			continue
+		}
+		msg := event.Message()
+		scoredCount, processErr := scoreMessage(scoreDb, nowPoll, &msg)
+		tracker.processed(scoredCount, processErr)
+		if processErr != nil {
+			logger.Error("error processing scoring message", zap.String("sourceId", msg.SourceId), zap.Error(processErr))
+			err = firstError(err, deadLetter(scoreDb, event, processErr))
+		}
+	}
+	return
+}
+
+func firstError(err, nextErr error) error {
+	if err != nil {
+		return err
+	}
+	return nextErr
+}
+
+// deadLetter stores the failed event, so polling can carry on past it. An unreadable event keeps its raw payload,
+// otherwise the payload is the ScoreEvent itself. Either payload can be retried via ParseImportRecord.
+func deadLetter(scoreDb db.IScoreDB, event ScoreEvent, eventErr error) (err error) {
+	payload := event.Raw
+	if payload == nil {
+		if payload, err = json.Marshal(event); err != nil {
+			return
+		}
+	}
+	return scoreDb.InsertDeadLetter(&types.DeadLetter{
+		SourceId: event.Id,
+		Payload:  string(payload),
+		Error:    eventErr.Error(),
+	})
+}
@@ -24,6 +24,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
 	"github.com/joho/godotenv"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
@@ -46,7 +47,7 @@ type MockDogApiClient struct {
 
 var _ IDogApiClient = (*MockDogApiClient)(nil)
 
-func (c *MockDogApiClient) getDDApiClient() (ctx context.Context, apiClient *datadog.APIClient) {
+func (c *MockDogApiClient) getDDApiClient(parent context.Context) (ctx context.Context, apiClient *datadog.APIClient) {
 	configuration := datadog.NewConfiguration()
 	configuration.Servers = datadog.ServerConfigurations{
 		datadog.ServerConfiguration{
@@ -66,7 +67,7 @@ func (c *MockDogApiClient) getDDApiClient() (ctx context.Context, apiClient *dat
 	}
 	apiClient = datadog.NewAPIClient(configuration)
 
-	ctx = context.Background()
+	ctx = parent
 	return
 }
 
@@ -83,7 +84,7 @@ func setupMockDDogApiClient(mockUrl *url.URL) (closeApiClient func()) {
 }
 
 func TestGetDDApiClientReal(t *testing.T) {
-	contextReal, clientReal := dogApiClient.getDDApiClient()
+	contextReal, clientReal := dogApiClient.getDDApiClient(context.Background())
 	assert.NotNil(t, contextReal)
 	assert.Equal(t, 3, len(clientReal.GetConfig().Servers))
 	assert.Equal(t, "https://{subdomain}.{site}", clientReal.GetConfig().Servers[0].URL)
@@ -108,7 +109,7 @@ func TestGetDDApiClientRealHasSomeScoresInPastWeek(t *testing.T) {
 	hoursDuration := time.Hour * -168 // one week in the past
 	before := now.Add(hoursDuration)
 
-	isDone, pageCursor, logPage, _, err = fetchLogPage(before, now, &pageCursor)
+	isDone, pageCursor, logPage, _, _, err = fetchLogPage(context.Background(), before, now, &pageCursor)
 	foundInfo := fmt.Sprintf("found logCount: %d in the past: %v", len(logPage), hoursDuration)
 	fmt.Println(foundInfo)
 
@@ -136,7 +137,7 @@ func TestFetchLogPagesErrorMissingKey(t *testing.T) {
 	pageCursor := ""
 	var logPage []ddLog
 
-	isDone, cursor, logPage, _, err := fetchLogPage(now, now, &pageCursor)
+	isDone, cursor, logPage, _, _, err := fetchLogPage(context.Background(), now, now, &pageCursor)
 	assert.False(t, isDone)
 	assert.Equal(t, "", cursor)
 	assert.Equal(t, ([]ddLog)(nil), logPage)
@@ -175,7 +176,7 @@ func TestFetchLogPagesMetaWarnings(t *testing.T) {
 	pageCursor := ""
 	var logPage []ddLog
 
-	isDone, cursor, logPage, _, err := fetchLogPage(now, now, &pageCursor)
+	isDone, cursor, logPage, _, _, err := fetchLogPage(context.Background(), now, now, &pageCursor)
 	assert.False(t, isDone)
 	assert.Equal(t, "", cursor)
 	assert.Equal(t, ([]ddLog)(nil), logPage)
@@ -210,7 +211,7 @@ func TestFetchLogPagesMetaStatusTimeout(t *testing.T) {
 	pageCursor := ""
 	var logPage []ddLog
 
-	isDone, cursor, logPage, _, err := fetchLogPage(now, now, &pageCursor)
+	isDone, cursor, logPage, _, _, err := fetchLogPage(context.Background(), now, now, &pageCursor)
 	assert.False(t, isDone)
 	assert.Equal(t, "", cursor)
 	assert.Equal(t, ([]ddLog)(nil), logPage)
@@ -245,7 +246,7 @@ func TestFetchLogPagesMetaStatusDone(t *testing.T) {
 	pageCursor := ""
 	var logPage []ddLog
 
-	isDone, cursor, logPage, fetchDuration, err := fetchLogPage(now, now, &pageCursor)
+	isDone, cursor, logPage, fetchDuration, _, err := fetchLogPage(context.Background(), now, now, &pageCursor)
 	assert.True(t, isDone)
 	assert.Equal(t, "", cursor)
 	assert.Equal(t, ([]ddLog)(nil), logPage)
@@ -284,7 +285,7 @@ func TestFetchLogPagesMetaPageHasAfter(t *testing.T) {
 	pageCursor := ""
 	var logPage []ddLog
 
-	isDone, cursor, logPage, _, err := fetchLogPage(now, now, &pageCursor)
+	isDone, cursor, logPage, _, _, err := fetchLogPage(context.Background(), now, now, &pageCursor)
 	assert.False(t, isDone)
 	assert.Equal(t, after, cursor)
 	assert.Equal(t, ([]ddLog)(nil), logPage)
@@ -314,7 +315,7 @@ func TestFetchLogPagesMetaPageNoAfter(t *testing.T) {
 	pageCursor := ""
 	var logPage []ddLog
 
-	isDone, cursor, logPage, _, err := fetchLogPage(now, now, &pageCursor)
+	isDone, cursor, logPage, _, _, err := fetchLogPage(context.Background(), now, now, &pageCursor)
 	assert.True(t, isDone)
 	assert.Equal(t, "", cursor)
 	assert.Equal(t, ([]ddLog)(nil), logPage)
@@ -352,7 +353,7 @@ func TestFetchLogPagesWithCursor(t *testing.T) {
 	now := time.Now()
 	var logPage []ddLog
 
-	isDone, cursor, logPage, _, err := fetchLogPage(now, now, &pageCursor)
+	isDone, cursor, logPage, _, _, err := fetchLogPage(context.Background(), now, now, &pageCursor)
 	assert.True(t, isDone)
 	assert.Equal(t, "", cursor)
 	assert.Equal(t, ([]ddLog)(nil), logPage)
@@ -365,6 +366,47 @@ func TestProcessResponseDataEmpty(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestProcessResponsePageSkipsBadLogs(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	badLogId := "myBadLogId"
+	wrongTypeLogId := "myWrongTypeLogId"
+	goodLogId := "myGoodLogId"
+	responseData := []datadog.Log{
+		{
+			Id:         &badLogId,
+			Attributes: &datadog.LogAttributes{Attributes: map[string]interface{}{}},
+		},
+		{
+			Id: &wrongTypeLogId,
+			Attributes: &datadog.LogAttributes{Attributes: map[string]interface{}{
+				qryEnv: map[string]interface{}{qryEnvExtraJsonFields: "not a map"},
+			}},
+		},
+		{
+			Id: &goodLogId,
+			Attributes: &datadog.LogAttributes{Attributes: map[string]interface{}{
+				qryEnv: map[string]interface{}{qryEnvExtraJsonFields: map[string]interface{}{"triggerUser": "someone"}},
+			}},
+		},
+	}
+
+	logs := processResponsePage(responseData)
+	assert.Equal(t, 3, len(logs))
+
+	assert.Equal(t, badLogId, logs[0].Id)
+	assert.EqualError(t, logs[0].parseErr, "unexpected attribute map type in map[]")
+	assert.Equal(t, `{"attributes":{"attributes":{}},"id":"myBadLogId"}`, string(logs[0].raw))
+
+	assert.Equal(t, wrongTypeLogId, logs[1].Id)
+	assert.EqualError(t, logs[1].parseErr, "unexpected scoring message type string at env.envExtraJsonFields")
+
+	assert.Equal(t, goodLogId, logs[2].Id)
+	assert.NoError(t, logs[2].parseErr)
+	assert.Nil(t, logs[2].raw)
+	assert.Equal(t, "someone", logs[2].Fields.scoringMessage.TriggerUser)
+}
+
 func TestProcessResponseDataMissingEnvMap(t *testing.T) {
 	logId := "myLogId"
 	attribs := map[string]interface{}{}
@@ -384,7 +426,7 @@ func TestProcessResponseDataMissingEnvMap(t *testing.T) {
 	assert.EqualError(t, err, "unexpected attribute map type in map[]")
 }
 
-func TestProcessResponseDataUnexpectedMapKey(t *testing.T) {
+func TestProcessResponseDataIgnoresExtraAttributes(t *testing.T) {
 	logId := "myLogId"
 	envMap := map[string]interface{}{
 		"yadda": "myEnv",
@@ -404,8 +446,8 @@ func TestProcessResponseDataUnexpectedMapKey(t *testing.T) {
 		},
 	}
 	logs, err := processResponseData(responseData)
-	assert.Equal(t, 0, len(logs))
-	assert.EqualError(t, err, "unexpected extra field key: yadda")
+	assert.NoError(t, err)
+	assert.Equal(t, []ddLog{{Id: logId}}, logs)
 }
 
 func TestProcessResponseDataMapKeyBaseTimeFormatError(t *testing.T) {
@@ -433,6 +475,21 @@ func TestProcessResponseDataMapKeyBaseTimeFormatError(t *testing.T) {
 	assert.True(t, strings.HasPrefix(err.Error(), "parsing time "))
 }
 
+func TestProcessResponseDataMapKeyBaseTimeTypeError(t *testing.T) {
+	logId := "myLogId"
+	responseData := []datadog.Log{
+		{
+			Id: &logId,
+			Attributes: &datadog.LogAttributes{Attributes: map[string]interface{}{
+				qryEnv: map[string]interface{}{qryEnvBaseTime: float64(12)},
+			}},
+		},
+	}
+	logs, err := processResponseData(responseData)
+	assert.Equal(t, 0, len(logs))
+	assert.EqualError(t, err, "unexpected base time type float64 at env.envBaseTime")
+}
+
 func TestProcessResponseDataMapKeyBaseTime(t *testing.T) {
 	logId := "myLogId"
 	now := time.Now()
@@ -662,9 +719,10 @@ func TestPollTheDogDBError(t *testing.T) {
 	db.SetupMockPollSelectForcedError(mock, forcedError, poll.Id)
 
 	now := time.Now()
-	logs, err := pollTheDog(dbPoll, now, now)
+	var logs []ScoreEvent
+	_, _, err := pollTheDog(context.Background(), dbPoll, NewDatadogSource(), testLease, now, now, &pageResume{}, collectEvents(&logs))
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, ([]ddLog)(nil), logs)
+	assert.Equal(t, ([]ScoreEvent)(nil), logs)
 }
 
 func TestPollTheDogPollError(t *testing.T) {
@@ -677,8 +735,10 @@ func TestPollTheDogPollError(t *testing.T) {
 	now := time.Now()
 	db.SetupMockPollSelect(mock, poll.Id, now)
 
+	requestCount := 0
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, http.MethodPost, r.Method)
+		requestCount++
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer ts.Close()
@@ -688,9 +748,12 @@ func TestPollTheDogPollError(t *testing.T) {
 	closeApiClient := setupMockDDogApiClient(urlTs)
 	defer closeApiClient()
 
-	logs, err := pollTheDog(dbPoll, now, now)
+	source, _ := newTestDatadogSource()
+	var logs []ScoreEvent
+	_, _, err = pollTheDog(context.Background(), dbPoll, source, testLease, now, now, &pageResume{}, collectEvents(&logs))
 	assert.EqualError(t, err, "500 Internal Server Error")
-	assert.Equal(t, ([]ddLog)(nil), logs)
+	assert.Equal(t, ([]ScoreEvent)(nil), logs)
+	assert.Equal(t, 1+source.retry.maxRetries, requestCount)
 }
 
 func TestPollTheDogUsePriorPollTime(t *testing.T) {
@@ -701,7 +764,7 @@ func TestPollTheDogUsePriorPollTime(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdate(mock, poll.Id, now, 1)
+	db.SetupMockPollSelectAndUpdateHeld(mock, poll.Id, testHolderId, now)
 
 	priorPollTime := now.Add(time.Second * -1)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -721,9 +784,10 @@ func TestPollTheDogUsePriorPollTime(t *testing.T) {
 	closeApiClient := setupMockDDogApiClient(urlTs)
 	defer closeApiClient()
 
-	logs, err := pollTheDog(dbPoll, priorPollTime, now)
+	var logs []ScoreEvent
+	_, _, err = pollTheDog(context.Background(), dbPoll, NewDatadogSource(), testLease, priorPollTime, now, &pageResume{}, collectEvents(&logs))
 	assert.NoError(t, err)
-	assert.Equal(t, ([]ddLog)(nil), logs)
+	assert.Equal(t, ([]ScoreEvent)(nil), logs)
 }
 
 func TestPollTheDogOneLog(t *testing.T) {
@@ -734,7 +798,7 @@ func TestPollTheDogOneLog(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdate(mock, poll.Id, now, 1)
+	db.SetupMockPollSelectAndUpdateHeld(mock, poll.Id, testHolderId, now)
 
 	logId := "myLogId"
 	eventSource := "myEventSource"
@@ -770,30 +834,331 @@ func TestPollTheDogOneLog(t *testing.T) {
 	closeApiClient := setupMockDDogApiClient(urlTs)
 	defer closeApiClient()
 
-	logs, err := pollTheDog(dbPoll, now, now)
+	var logs []ScoreEvent
+	_, _, err = pollTheDog(context.Background(), dbPoll, NewDatadogSource(), testLease, now, now, &pageResume{}, collectEvents(&logs))
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, len(logs))
 	assert.Equal(t, logId, logs[0].Id)
-	assert.Equal(t, eventSource, logs[0].Fields.scoringMessage.EventSource)
+	assert.Equal(t, eventSource, logs[0].ScoringMessage.EventSource)
+}
+
+// collectEvents returns a pollTheDog process function that keeps the events it is given
+func collectEvents(processed *[]ScoreEvent) func(events []ScoreEvent) error {
+	return func(events []ScoreEvent) error {
+		*processed = append(*processed, events...)
+		return nil
+	}
+}
+
+// windowSource returns one event for each window fetched, and fails once failAt windows have been fetched
+type windowSource struct {
+	windows [][2]time.Time
+	failAt  int
+}
+
+var _ ScoreSource = (*windowSource)(nil)
+
+func (w *windowSource) FetchPage(_ context.Context, from, to time.Time, _ string) (events []ScoreEvent, nextCursor string, err error) {
+	if w.failAt > 0 && len(w.windows) == w.failAt {
+		err = fmt.Errorf("forced window error")
+		return
+	}
+	w.windows = append(w.windows, [2]time.Time{from, to})
+	events = []ScoreEvent{{Id: fmt.Sprintf("window%d", len(w.windows)), BaseTime: to}}
+	return
+}
+
+func TestPollTheDogPollsOneSlice(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now().Truncate(time.Second)
+	lastPolled := now.Add(-3 * time.Hour)
+	before := lastPolled.Add(time.Second * pollFudgeSeconds)
+	db.SetupMockPollSelectAndUpdateTo(mock, dbPoll.NewPoll().Id, testHolderId, lastPolled, before.Add(maxPollSlice))
+
+	source := &windowSource{}
+	var processed []ScoreEvent
+	polledTo, caughtUp, err := pollTheDog(context.Background(), dbPoll, source, testLease, now, now, &pageResume{}, collectEvents(&processed))
+	assert.NoError(t, err)
+	assert.False(t, caughtUp)
+	assert.Equal(t, before.Add(maxPollSlice), polledTo)
+	assert.Equal(t, [][2]time.Time{{before, before.Add(maxPollSlice)}}, source.windows)
+	assert.Equal(t, 1, len(processed))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	pollStatus := Status()
+	assert.True(t, pollStatus.CatchingUp)
+	assert.Equal(t, now.Sub(polledTo).Seconds(), pollStatus.CatchUpRemainingSeconds)
+}
+
+func TestPollTheDogCommitsAfterProcessing(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	db.SetupMockPollSelectAndUpdateHeld(mock, dbPoll.NewPoll().Id, testHolderId, now)
+
+	polledTo, caughtUp, err := pollTheDog(context.Background(), dbPoll, &windowSource{}, testLease, now, now, &pageResume{}, func(events []ScoreEvent) error {
+		// the poll is selected, but not yet updated
+		assert.Error(t, mock.ExpectationsWereMet())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, caughtUp)
+	assert.Equal(t, now, polledTo)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollTheDogDoesNotCommitWhenProcessingFails(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	db.SetupMockPollSelect(mock, dbPoll.NewPoll().Id, now)
+
+	forcedError := fmt.Errorf("forced dead letter error")
+	polledTo, _, err := pollTheDog(context.Background(), dbPoll, &windowSource{}, testLease, now, now, &pageResume{}, func(events []ScoreEvent) error {
+		return forcedError
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.True(t, polledTo.IsZero())
+	// the poll is not updated, so the slice is polled again
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollTheDogStartsOverWhenProcessingBeforePageErrorFails(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	db.SetupMockPollSelect(mock, dbPoll.NewPoll().Id, now)
+	db.SetupMockPollAcquireLease(mock, dbPoll.NewPoll().Id, testHolderId, true)
+
+	source := &fakeScoreSource{pages: [][]ScoreEvent{{{Id: "first"}}}, err: fmt.Errorf("forced page error")}
+	resume := pageResume{}
+	_, _, err := pollTheDog(context.Background(), dbPoll, source, testLease, now, now, &resume, func(events []ScoreEvent) error {
+		return fmt.Errorf("forced dead letter error")
+	})
+	assert.EqualError(t, err, "forced page error")
+	assert.Equal(t, pageResume{}, resume)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollTheDogStopsWhenLeaseLostBeforeNextPage(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	now := time.Now()
+	db.SetupMockPollSelect(mock, pollId, now)
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, false)
+
+	source := &fakeScoreSource{pages: [][]ScoreEvent{{{Id: "first"}}, {{Id: "second"}}}}
+	resume := pageResume{}
+	polledTo, _, err := pollTheDog(context.Background(), dbPoll, source, testLease, now, now, &resume, func(events []ScoreEvent) error {
+		assert.Fail(t, "the instance that took over processes these events")
+		return nil
+	})
+	assert.Equal(t, errLeaseLost, err)
+	assert.True(t, polledTo.IsZero())
+	assert.Equal(t, []string{""}, source.cursorsGot)
+	assert.Equal(t, pageResume{}, resume)
+	assert.False(t, Status().LeaseHeld)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollTheDogDoesNotCommitWhenLeaseLost(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	now := time.Now()
+	db.SetupMockPollSelect(mock, pollId, now)
+	db.SetupMockPollUpdateHeld(mock, pollId, testHolderId, now, false)
+
+	var processed []ScoreEvent
+	polledTo, _, err := pollTheDog(context.Background(), dbPoll, &fakeScoreSource{pages: [][]ScoreEvent{{{Id: "first"}}}},
+		testLease, now, now, &pageResume{}, collectEvents(&processed))
+	assert.Equal(t, errLeaseLost, err)
+	assert.True(t, polledTo.IsZero())
+	assert.Equal(t, 1, len(processed))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func countScored(scored *int) func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+	return func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		*scored++
+		return 1, nil
+	}
+}
+
+func TestCatchUpCommitsEachSlice(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	now := time.Now().Truncate(time.Second)
+	lastPolled := now.Add(-150 * time.Minute)
+	fudge := time.Second * pollFudgeSeconds
+	slice1 := lastPolled.Add(fudge).Add(maxPollSlice)
+	slice2 := slice1.Add(fudge).Add(maxPollSlice)
+	db.SetupMockPollSelectAndUpdateTo(mock, pollId, testHolderId, lastPolled, slice1)
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, true)
+	db.SetupMockPollSelectAndUpdateTo(mock, pollId, testHolderId, slice1, slice2)
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, true)
+	db.SetupMockPollSelectAndUpdateTo(mock, pollId, testHolderId, slice2, now)
+
+	source := &windowSource{}
+	scored := 0
+	priorPollTime := now
+	var resume pageResume
+	err := catchUp(context.Background(), dbPoll, createMockScoreDb(t), source, testLease,
+		&priorPollTime, now, &resume, countScored(&scored))
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]time.Time{
+		{lastPolled.Add(fudge), slice1},
+		{slice1.Add(fudge), slice2},
+		{slice2.Add(fudge), now},
+	}, source.windows)
+	assert.Equal(t, 3, scored)
+	assert.Equal(t, now, priorPollTime)
+	assert.False(t, Status().CatchingUp)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatchUpKeepsSlicesCommittedBeforeError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	now := time.Now().Truncate(time.Second)
+	lastPolled := now.Add(-150 * time.Minute)
+	slice1 := lastPolled.Add(time.Second * pollFudgeSeconds).Add(maxPollSlice)
+	db.SetupMockPollSelectAndUpdateTo(mock, pollId, testHolderId, lastPolled, slice1)
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, true)
+	db.SetupMockPollSelect(mock, pollId, slice1)
+
+	scored := 0
+	priorPollTime := now
+	var resume pageResume
+	err := catchUp(context.Background(), dbPoll, createMockScoreDb(t), &windowSource{failAt: 1}, testLease,
+		&priorPollTime, now, &resume, countScored(&scored))
+	assert.EqualError(t, err, "forced window error")
+	assert.Equal(t, 1, scored)
+	// the next poll carries on after the committed slice, instead of starting over
+	assert.Equal(t, slice1, priorPollTime)
+	assert.Equal(t, slice1, resume.from.Add(-time.Second*pollFudgeSeconds))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatchUpStopsWhenLeaseLost(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	now := time.Now().Truncate(time.Second)
+	lastPolled := now.Add(-150 * time.Minute)
+	slice1 := lastPolled.Add(time.Second * pollFudgeSeconds).Add(maxPollSlice)
+	db.SetupMockPollSelectAndUpdateTo(mock, pollId, testHolderId, lastPolled, slice1)
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, false)
+
+	scored := 0
+	priorPollTime := now
+	var resume pageResume
+	err := catchUp(context.Background(), dbPoll, createMockScoreDb(t), &windowSource{}, testLease,
+		&priorPollTime, now, &resume, countScored(&scored))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, scored)
+	assert.False(t, Status().LeaseHeld)
+	// the instance that took over carries on from the db poll time
+	assert.Equal(t, now, priorPollTime)
+	assert.Equal(t, pageResume{}, resume)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatchUpStopsWhenLeaseLostBeforeCommit(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+	tracker.setLeaseHeld(true)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	now := time.Now().Truncate(time.Second)
+	lastPolled := now.Add(-150 * time.Minute)
+	db.SetupMockPollSelect(mock, pollId, lastPolled)
+	db.SetupMockPollUpdateHeld(mock, pollId, testHolderId, sqlmock.AnyArg(), false)
+
+	scored := 0
+	priorPollTime := now
+	var resume pageResume
+	err := catchUp(context.Background(), dbPoll, createMockScoreDb(t), &windowSource{}, testLease,
+		&priorPollTime, now, &resume, countScored(&scored))
+	assert.NoError(t, err)
+	assert.False(t, Status().LeaseHeld)
+	assert.Equal(t, now, priorPollTime)
+	assert.Equal(t, 0, len(Status().RecentErrors))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatchUpStopsWhenCancelled(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	now := time.Now().Truncate(time.Second)
+	lastPolled := now.Add(-150 * time.Minute)
+	slice1 := lastPolled.Add(time.Second * pollFudgeSeconds).Add(maxPollSlice)
+	db.SetupMockPollSelectAndUpdateTo(mock, pollId, testHolderId, lastPolled, slice1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scored := 0
+	priorPollTime := now
+	var resume pageResume
+	err := catchUp(ctx, dbPoll, createMockScoreDb(t), &windowSource{}, testLease,
+		&priorPollTime, now, &resume, countScored(&scored))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, scored)
+	assert.Equal(t, slice1, priorPollTime)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 type MockScoreDB struct {
 	t                *testing.T
 	assertParameters bool
 
-	selectPriorScore     *types.ParticipantStruct
-	selectPriorMsg       *types.ScoringMessage
-	selectPriorOldPoints float64
-
-	insertEvtParticipant *types.ParticipantStruct
-	insertEvtMsg         *types.ScoringMessage
-	insertEvtNewPoints   int
-	insertEvtError       error
+	beginScoreTxError error
 
-	updateScoreParticipant *types.ParticipantStruct
-	updateScoreDelta       float64
-	updateScoreError       error
+	deadLetters      *[]types.DeadLetter
+	deadLetterErrors []error
 }
 
 func (m MockScoreDB) GetDb() (db *sql.DB) {
@@ -805,32 +1170,21 @@ func createMockScoreDb(t *testing.T) (scoreDb *MockScoreDB) {
 	return &MockScoreDB{
 		t:                t,
 		assertParameters: true,
+		deadLetters:      &[]types.DeadLetter{},
 	}
 }
 
-func (m MockScoreDB) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
-	if m.assertParameters {
-		assert.Equal(m.t, m.selectPriorScore, participantToScore)
-		assert.Equal(m.t, m.selectPriorMsg, msg)
-	}
-	return m.selectPriorOldPoints
-}
-
-func (m MockScoreDB) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
-	if m.assertParameters {
-		assert.Equal(m.t, m.insertEvtParticipant, participantToScore)
-		assert.Equal(m.t, m.insertEvtMsg, msg)
-		assert.Equal(m.t, m.insertEvtNewPoints, newPoints)
-	}
-	return m.insertEvtError
+func (m MockScoreDB) BeginScoreTx() (scoreTx db.IScoreTx, err error) {
+	return nil, m.beginScoreTxError
 }
 
-func (m MockScoreDB) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
-	if m.assertParameters {
-		assert.Equal(m.t, m.updateScoreParticipant, participant)
-		assert.Equal(m.t, m.updateScoreDelta, delta)
+// InsertDeadLetter records each dead letter, and returns the next of deadLetterErrors (if any)
+func (m MockScoreDB) InsertDeadLetter(deadLetter *types.DeadLetter) (err error) {
+	if len(m.deadLetterErrors) > len(*m.deadLetters) {
+		err = m.deadLetterErrors[len(*m.deadLetters)]
 	}
-	return m.updateScoreError
+	*m.deadLetters = append(*m.deadLetters, *deadLetter)
+	return
 }
 
 var _ db.IScoreDB = (*MockScoreDB)(nil)
@@ -842,30 +1196,77 @@ func TestProcessLogsZeroLogs(t *testing.T) {
 func TestProcessLogsOneWithError(t *testing.T) {
 	scoreDb := createMockScoreDb(t)
 
-	logs := []ddLog{
+	logs := []ScoreEvent{
 		{},
 	}
 	now := time.Now()
 	forcedError := fmt.Errorf("forced process logs error")
-	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (scoredCount int, err error) {
 		assert.Equal(t, scoreDb, scoreDbCalled)
 		assert.Equal(t, now, nowCalled)
 		assert.Equal(t, &types.ScoringMessage{}, msgCalled)
-		return forcedError
+		return 0, forcedError
 	}
 
 	err := processLogs(scoreDb, logs, now, processScoringMessage)
-	assert.EqualError(t, forcedError, err.Error())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.DeadLetter{
+		{Payload: `{"id":"","baseTime":"0001-01-01T00:00:00Z","scoringMessage":{"eventSource":"","repositoryOwner":"","repositoryName":"","triggerUser":"","fixed-bugs":0,"fixed-bug-types":null,"pullRequestId":0}}`, Error: forcedError.Error()},
+	}, *scoreDb.deadLetters)
+}
+
+func TestProcessLogsContinuesPastErrors(t *testing.T) {
+	scoreDb := createMockScoreDb(t)
+
+	parseErr := fmt.Errorf("forced parse error")
+	logs := []ScoreEvent{
+		{Id: "bad", ParseErr: parseErr, Raw: []byte(`{"id": "bad"}`)},
+		{Id: "failed"},
+		{Id: "scored"},
+	}
+	var processed []string
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		processed = append(processed, msg.SourceId)
+		if msg.SourceId == "failed" {
+			err = fmt.Errorf("forced scoring error")
+		}
+		return
+	}
+
+	assert.NoError(t, processLogs(scoreDb, logs, time.Now(), processScoringMessage))
+	assert.Equal(t, []string{"failed", "scored"}, processed)
+	assert.Equal(t, 2, len(*scoreDb.deadLetters))
+	assert.Equal(t, types.DeadLetter{SourceId: "bad", Payload: `{"id": "bad"}`, Error: parseErr.Error()}, (*scoreDb.deadLetters)[0])
+	assert.Equal(t, "failed", (*scoreDb.deadLetters)[1].SourceId)
+	assert.Equal(t, "forced scoring error", (*scoreDb.deadLetters)[1].Error)
+
+	// a dead letter payload can be read back for a retry
+	event, err := ParseImportRecord([]byte((*scoreDb.deadLetters)[1].Payload))
+	assert.NoError(t, err)
+	assert.Equal(t, "failed", event.Id)
+}
+
+func TestProcessLogsDeadLetterError(t *testing.T) {
+	scoreDb := createMockScoreDb(t)
+	forcedError := fmt.Errorf("forced dead letter error")
+	scoreDb.deadLetterErrors = []error{forcedError, fmt.Errorf("second dead letter error")}
+
+	logs := []ScoreEvent{
+		{Id: "bad1", ParseErr: fmt.Errorf("forced parse error")},
+		{Id: "bad2", ParseErr: fmt.Errorf("forced parse error")},
+	}
+	assert.EqualError(t, processLogs(scoreDb, logs, time.Now(), nil), forcedError.Error())
+	assert.Equal(t, 2, len(*scoreDb.deadLetters))
 }
 
 func TestProcessLogsOne(t *testing.T) {
 	scoreDb := createMockScoreDb(t)
 
-	logs := []ddLog{
+	logs := []ScoreEvent{
 		{},
 	}
 	now := time.Now()
-	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (scoredCount int, err error) {
 		assert.Equal(t, scoreDb, scoreDbCalled)
 		assert.Equal(t, now, nowCalled)
 		assert.Equal(t, &types.ScoringMessage{}, msgCalled)
@@ -876,25 +1277,121 @@ func TestProcessLogsOne(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestProcessLogsSetsSourceId(t *testing.T) {
+	logs := []ScoreEvent{
+		{Id: "myLogId"},
+		{Id: "ignoredLogId", ScoringMessage: types.ScoringMessage{SourceId: "mySourceId"}},
+	}
+	var sourceIds []string
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		sourceIds = append(sourceIds, msg.SourceId)
+		return
+	}
+
+	assert.NoError(t, processLogs(createMockScoreDb(t), logs, time.Now(), processScoringMessage))
+	assert.Equal(t, []string{"myLogId", "mySourceId"}, sourceIds)
+}
+
+const testHolderId = "myHolderId"
+
+var testLease = pollLease{pollId: db.PollId, holderId: testHolderId, ttl: time.Minute}
+
+func TestNewLeaseHolderId(t *testing.T) {
+	holderId := NewLeaseHolderId()
+	hostname, _ := os.Hostname()
+	assert.True(t, strings.HasPrefix(holderId, fmt.Sprintf("%s-%d-", hostname, os.Getpid())), holderId)
+	assert.NotEqual(t, holderId, NewLeaseHolderId())
+}
+
+func TestChaseTailLeaseError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced lease error")
+	mock.ExpectQuery(db.PollConvertSqlToDbMockExpect("INSERT INTO poll_lease")).
+		WillReturnError(forcedError)
+
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		assert.Fail(t, "this should never run")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), NewDatadogSource(), 1, testHolderId, processScoringMessage)
+	time.Sleep(1500 * time.Millisecond)
+	cancel()
+
+	assert.EqualError(t, <-done, forcedError.Error())
+	pollStatus := Status()
+	assert.False(t, pollStatus.Running)
+	assert.Equal(t, 1, len(pollStatus.RecentErrors))
+	assert.Equal(t, forcedError.Error(), pollStatus.RecentErrors[0].Error)
+}
+
+func TestUseLogger(t *testing.T) {
+	useLogger := zaptest.NewLogger(t)
+	UseLogger(useLogger)
+	assert.Equal(t, useLogger, logger)
+}
+
+func TestChaseTailLeaseHeldElsewhere(t *testing.T) {
+	testLogger := zaptest.NewLogger(t)
+	logger = testLogger
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := dbPoll.NewPoll()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, false)
+	db.SetupMockPollReleaseLease(mock, poll.Id, testHolderId)
+
+	source := &fakeScoreSource{}
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		assert.Fail(t, "this should never run")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), source, 1, testHolderId, processScoringMessage)
+	time.Sleep(1500 * time.Millisecond)
+	cancel()
+	assert.Nil(t, <-done)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Nil(t, source.cursorsGot)
+	// polling must not replace the logger read by request handlers
+	assert.Equal(t, testLogger, logger)
+}
+
 func TestChaseTailPollError(t *testing.T) {
 	logger = zaptest.NewLogger(t)
+	tracker.reset()
 
 	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
 	defer closeDbFunc()
 
 	poll := dbPoll.NewPoll()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, true)
 	forcedError := fmt.Errorf("forced poll db error")
 	db.SetupMockPollSelectForcedError(mock, forcedError, poll.Id)
 
-	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
 		assert.Fail(t, "this should never run")
 		return
 	}
 
-	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
-	defer close(quitChan)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), NewDatadogSource(), 1, testHolderId, processScoringMessage)
+	time.Sleep(1500 * time.Millisecond)
+	cancel()
 
-	assert.EqualError(t, <-errChan, forcedError.Error())
+	assert.EqualError(t, <-done, forcedError.Error())
+	pollStatus := Status()
+	assert.False(t, pollStatus.Running)
+	assert.Equal(t, 1, len(pollStatus.RecentErrors))
+	assert.Equal(t, forcedError.Error(), pollStatus.RecentErrors[0].Error)
 }
 
 func TestChaseTailQuit(t *testing.T) {
@@ -904,17 +1401,57 @@ func TestChaseTailQuit(t *testing.T) {
 	defer closeDbFunc()
 
 	poll := dbPoll.NewPoll()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, true)
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
+	db.SetupMockPollSelectAndUpdateHeldAnyUpdateTime(mock, poll.Id, testHolderId, now)
+
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		assert.Fail(t, "this should never run")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), NewDatadogSource(), 1, testHolderId, processScoringMessage)
+	cancel()
+	assert.Nil(t, <-done)
+}
 
-	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+func TestChaseTailStopsDuringRetryWait(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := dbPoll.NewPoll()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, true)
+	db.SetupMockPollSelect(mock, poll.Id, time.Now())
+
+	_, closeStandIn := startDatadogStandIn(t, standInResponse{status: http.StatusServiceUnavailable})
+	defer closeStandIn()
+	source := NewDatadogSource()
+	source.retry.baseDelay = time.Hour
+	source.retry.maxDelay = time.Hour
+
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
 		assert.Fail(t, "this should never run")
 		return
 	}
 
-	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
-	close(quitChan)
-	assert.Nil(t, <-errChan)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), source, 1, testHolderId, processScoringMessage)
+	time.Sleep(1500 * time.Millisecond)
+	stopStart := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "polling did not stop during the retry wait")
+	}
+	assert.True(t, time.Since(stopStart) < 5*time.Second, time.Since(stopStart))
+	assert.Equal(t, 0, len(Status().RecentErrors))
 }
 
 func TestChaseTailProcessLogsError(t *testing.T) {
@@ -924,8 +1461,9 @@ func TestChaseTailProcessLogsError(t *testing.T) {
 	defer closeDbFunc()
 
 	poll := dbPoll.NewPoll()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, true)
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
+	db.SetupMockPollSelectAndUpdateHeldAnyUpdateTime(mock, poll.Id, testHolderId, now)
 
 	logId := "myLogId"
 	eventSource := "myEventSource"
@@ -963,19 +1501,19 @@ func TestChaseTailProcessLogsError(t *testing.T) {
 
 	msgProcessed := false
 	forcedError := fmt.Errorf("forced process logs error")
-	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
 		msgProcessed = true
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
 		assert.Equal(t, eventSource, msg.EventSource)
 		err = forcedError
 		return
 	}
 
-	quitChan, _ := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), NewDatadogSource(), 1, testHolderId, processScoringMessage)
 
 	time.Sleep(2 * time.Second)
-	close(quitChan)
+	cancel()
+	<-done
 	assert.True(t, msgProcessed)
 }
 
@@ -986,8 +1524,9 @@ func TestChaseTailOneLog(t *testing.T) {
 	defer closeDbFunc()
 
 	poll := dbPoll.NewPoll()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, true)
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
+	db.SetupMockPollSelectAndUpdateHeldAnyUpdateTime(mock, poll.Id, testHolderId, now)
 
 	logId := "myLogId"
 	eventSource := "myEventSource"
@@ -1024,18 +1563,18 @@ func TestChaseTailOneLog(t *testing.T) {
 	defer closeApiClient()
 
 	msgProcessed := false
-	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
 		msgProcessed = true
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
 		assert.Equal(t, eventSource, msg.EventSource)
 		return
 	}
 
-	quitChan, _ := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), NewDatadogSource(), 1, testHolderId, processScoringMessage)
 
 	time.Sleep(2 * time.Second)
-	close(quitChan)
+	cancel()
+	<-done
 	assert.True(t, msgProcessed)
 }
 
@@ -1046,8 +1585,9 @@ func TestChaseTailOneLogWithOptMap(t *testing.T) {
 	defer closeDbFunc()
 
 	poll := dbPoll.NewPoll()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, true)
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
+	db.SetupMockPollSelectAndUpdateHeldAnyUpdateTime(mock, poll.Id, testHolderId, now)
 
 	eventSource := "myEventSource"
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1095,18 +1635,18 @@ func TestChaseTailOneLogWithOptMap(t *testing.T) {
 	defer closeApiClient()
 
 	msgProcessed := false
-	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
 		msgProcessed = true
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
 		assert.Equal(t, eventSource, msg.EventSource)
 		return
 	}
 
-	quitChan, _ := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), NewDatadogSource(), 1, testHolderId, processScoringMessage)
 
 	time.Sleep(2 * time.Second)
-	close(quitChan)
+	cancel()
+	<-done
 	assert.True(t, msgProcessed)
 }
 
@@ -1121,6 +1661,35 @@ func TestApplyDuctTapeToScoringMessageWrongPRIdPrefix(t *testing.T) {
 	assert.EqualError(t, err, "unexpected prefix in PR id: nonNumeric")
 }
 
+func TestApplyDuctTapeToScoringMessageWrongPRIdType(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	valueMap := map[string]interface{}{
+		"pullRequestId": true,
+	}
+	extra := extraFields{}
+	err := applyDuctTapeToScoringMessage(valueMap, &extra)
+	assert.EqualError(t, err, "unexpected PR id type: bool")
+}
+
+func TestApplyDuctTapeToScoringMessageWrongFieldType(t *testing.T) {
+	valueMap := map[string]interface{}{
+		"pullRequestId":   bogusPRidPrefix + "5",
+		"repositoryOwner": float64(3),
+	}
+	extra := extraFields{}
+	err := applyDuctTapeToScoringMessage(valueMap, &extra)
+	assert.EqualError(t, err, "unexpected repositoryOwner type: float64")
+}
+
+func TestTrimQuotes(t *testing.T) {
+	valueMap := map[string]interface{}{"quoted": `"text"`, "empty": nil}
+	assert.NoError(t, trimQuotes(valueMap, "quoted"))
+	assert.NoError(t, trimQuotes(valueMap, "empty"))
+	assert.NoError(t, trimQuotes(valueMap, "missing"))
+	assert.Equal(t, map[string]interface{}{"quoted": "text", "empty": nil}, valueMap)
+}
+
 func TestApplyDuctTapeToScoringMessageFloatParseError(t *testing.T) {
 	valueMap := map[string]interface{}{
 		"pullRequestId": bogusPRidPrefix + "nonNumeric",
@@ -1171,21 +1740,20 @@ func xxxTestChaseTailLive(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
+	db.SetupMockPollAcquireLease(mock, poll.Id, testHolderId, true)
 	// simulate day old poll
 	yesterday := now.Add(time.Hour * -24)
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, yesterday, 1)
+	db.SetupMockPollSelectAndUpdateHeldAnyUpdateTime(mock, poll.Id, testHolderId, yesterday)
 
-	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
 		assert.Equal(t, "github", msg.EventSource)
 		return
 	}
 
-	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
-	//defer close(quitChan)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), NewDatadogSource(), 1, testHolderId, processScoringMessage)
 
 	time.Sleep(3 * time.Second)
-	close(quitChan)
-	assert.Equal(t, nil, <-errChan)
+	cancel()
+	assert.Equal(t, nil, <-done)
 }
@@ -0,0 +1,115 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"io"
+	"time"
+)
+
+// maxImportLineBytes allows for raw Datadog log exports, which can be much larger than a bare scoring message.
+const maxImportLineBytes = 1024 * 1024
+
+// ParseImportRecord reads a single JSONL record, which may be a raw Datadog log export, a ScoreEvent (as read by
+// FileSource) or a bare ScoringMessage.
+func ParseImportRecord(line []byte) (event ScoreEvent, err error) {
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(line, &keys); err != nil {
+		return
+	}
+
+	if _, isDatadogLog := keys["attributes"]; isDatadogLog {
+		ddLogExport := datadog.Log{}
+		if err = json.Unmarshal(line, &ddLogExport); err != nil {
+			return
+		}
+		if ddLogExport.Id == nil || ddLogExport.Attributes == nil {
+			err = fmt.Errorf("datadog log is missing id or attributes")
+			return
+		}
+		var logs []ddLog
+		if logs, err = processResponseData([]datadog.Log{ddLogExport}); err != nil {
+			return
+		}
+		event = ScoreEvent{
+			Id:             logs[0].Id,
+			BaseTime:       logs[0].Fields.envBaseTime,
+			ScoringMessage: logs[0].Fields.scoringMessage,
+		}
+		return
+	}
+
+	if _, isScoreEvent := keys["scoringMessage"]; isScoreEvent {
+		err = json.Unmarshal(line, &event)
+		return
+	}
+
+	err = json.Unmarshal(line, &event.ScoringMessage)
+	return
+}
+
+// ImportScoringEvents runs each JSONL record through the given scoring function, and reports the outcome of every line.
+// When asOf is zero, each record is scored as of its own logged time (if it has one), or else as of right now.
+// Re-importing the same records is safe, because scoring an already scored pull request leaves its points unchanged.
+func ImportScoringEvents(reader io.Reader, asOf time.Time,
+	scoreMessage func(now time.Time, msg *types.ScoringMessage) (scoredCount int, err error)) (report types.ImportReport, err error) {
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxImportLineBytes)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		result := types.ImportResult{Line: lineNumber}
+		event, parseErr := ParseImportRecord(line)
+		if parseErr != nil {
+			result.Status = types.ImportFailed
+			result.Error = parseErr.Error()
+			report.Add(result)
+			continue
+		}
+		result.SourceId = event.Id
+
+		msg := event.Message()
+		scoredCount, scoreErr := scoreMessage(event.ScoreTime(asOf), &msg)
+		switch {
+		case scoreErr != nil:
+			result.Status = types.ImportFailed
+			result.Error = scoreErr.Error()
+		case scoredCount == 0:
+			result.Status = types.ImportSkipped
+		default:
+			result.Status = types.ImportAccepted
+		}
+		report.Add(result)
+	}
+	err = scanner.Err()
+	return
+}
@@ -0,0 +1,131 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseImportRecordInvalidJson(t *testing.T) {
+	_, err := ParseImportRecord([]byte(`[1, 2]`))
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "json: cannot unmarshal array into Go value of type map[string]"), err.Error())
+}
+
+func TestParseImportRecordScoringMessage(t *testing.T) {
+	event, err := ParseImportRecord([]byte(`{"eventSource": "github", "triggerUser": "someone", "fixed-bugs": 1, "pullRequestId": 3}`))
+	assert.NoError(t, err)
+	assert.Equal(t, ScoreEvent{
+		ScoringMessage: types.ScoringMessage{EventSource: "github", TriggerUser: "someone", TotalFixed: 1, PullRequest: 3},
+	}, event)
+}
+
+func TestParseImportRecordScoreEvent(t *testing.T) {
+	event, err := ParseImportRecord([]byte(`{"id": "myId", "baseTime": "2022-05-16T10:00:00Z", "scoringMessage": {"triggerUser": "someone"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "myId", event.Id)
+	assert.Equal(t, "2022-05-16T10:00:00Z", event.BaseTime.Format(time.RFC3339))
+	assert.Equal(t, "someone", event.ScoringMessage.TriggerUser)
+}
+
+func TestParseImportRecordDatadogLog(t *testing.T) {
+	line := `{"id": "myLogId", "type": "log", "attributes": {"attributes": {"env": {"envBaseTime": "2022-05-16T10:00:00Z",
+		"envExtraJsonFields": {"eventSource": "github", "triggerUser": "someone", "fixed-bugs": 2, "pullRequestId": 4}}}}}`
+	event, err := ParseImportRecord([]byte(line))
+	assert.NoError(t, err)
+	assert.Equal(t, "myLogId", event.Id)
+	assert.Equal(t, "2022-05-16T10:00:00Z", event.BaseTime.Format(time.RFC3339))
+	assert.Equal(t, types.ScoringMessage{EventSource: "github", TriggerUser: "someone", TotalFixed: 2, PullRequest: 4}, event.ScoringMessage)
+}
+
+func TestParseImportRecordDatadogLogWithBogusPRId(t *testing.T) {
+	line := `{"id": "myLogId", "attributes": {"attributes": {"env": {
+		"envExtraJsonFields": {"eventSource": "\"github\"", "repositoryOwner": "\"owner\"", "repositoryName": "\"repo\"", "pullRequestId": "PullRequestId 12"}}}}}`
+	event, err := ParseImportRecord([]byte(line))
+	assert.NoError(t, err)
+	assert.Equal(t, types.ScoringMessage{EventSource: "github", RepoOwner: "owner", RepoName: "repo", PullRequest: 12}, event.ScoringMessage)
+}
+
+func TestParseImportRecordDatadogLogMissingId(t *testing.T) {
+	_, err := ParseImportRecord([]byte(`{"attributes": {"attributes": {}}}`))
+	assert.EqualError(t, err, "datadog log is missing id or attributes")
+}
+
+func TestParseImportRecordDatadogLogMissingEnv(t *testing.T) {
+	_, err := ParseImportRecord([]byte(`{"id": "myLogId", "attributes": {"attributes": {}}}`))
+	assert.EqualError(t, err, "unexpected attribute map type in map[]")
+}
+
+func TestImportScoringEvents(t *testing.T) {
+	asOf := time.Now()
+	input := `{"triggerUser": "accepted"}
+
+{"triggerUser": "skipped"}
+{"triggerUser": "failed"}
+{bogus
+`
+	var usersScored []string
+	report, err := ImportScoringEvents(strings.NewReader(input), asOf, func(now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		assert.Equal(t, asOf, now)
+		usersScored = append(usersScored, msg.TriggerUser)
+		switch msg.TriggerUser {
+		case "accepted":
+			scoredCount = 1
+		case "failed":
+			err = fmt.Errorf("forced import error")
+		}
+		return
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"accepted", "skipped", "failed"}, usersScored)
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, []types.ImportResult{
+		{Line: 1, Status: types.ImportAccepted},
+		{Line: 3, Status: types.ImportSkipped},
+		{Line: 4, Status: types.ImportFailed, Error: "forced import error"},
+		{Line: 5, Status: types.ImportFailed, Error: "invalid character 'b' looking for beginning of object key string"},
+	}, report.Results)
+}
+
+func TestImportScoringEventsUsesRecordTime(t *testing.T) {
+	input := `{"id": "myId", "baseTime": "2022-05-16T10:00:00Z", "scoringMessage": {"triggerUser": "someone"}}`
+	report, err := ImportScoringEvents(strings.NewReader(input), time.Time{}, func(now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		assert.Equal(t, "2022-05-16T10:00:00Z", now.Format(time.RFC3339))
+		return 2, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ImportResult{{Line: 1, SourceId: "myId", Status: types.ImportAccepted}}, report.Results)
+}
+
+func TestImportScoringEventsDefaultsToNow(t *testing.T) {
+	before := time.Now()
+	_, err := ImportScoringEvents(strings.NewReader(`{"triggerUser": "someone"}`), time.Time{}, func(now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		assert.False(t, now.Before(before))
+		return
+	})
+	assert.NoError(t, err)
+}
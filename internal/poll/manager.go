@@ -0,0 +1,103 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"context"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sync"
+)
+
+type IManager interface {
+	Start() (err error)
+	Stop() (lastPollErr error)
+	Restart() (err error)
+	Status() types.PollStatus
+}
+
+// Manager owns the poller goroutine, so polling can be safely started, stopped and restarted by concurrent admin requests.
+type Manager struct {
+	// begin starts polling until the context is cancelled, and sends the last poll error on done once stopped
+	begin func(ctx context.Context) (done <-chan error, err error)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   <-chan error
+}
+
+// enforce implementation of interface
+var _ IManager = (*Manager)(nil)
+
+func NewManager(begin func(ctx context.Context) (done <-chan error, err error)) *Manager {
+	return &Manager{begin: begin}
+}
+
+// Start begins polling, unless already polling.
+func (m *Manager) Start() (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.start()
+}
+
+func (m *Manager) start() (err error) {
+	if m.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var done <-chan error
+	done, err = m.begin(ctx)
+	if err != nil {
+		cancel()
+		return
+	}
+	m.cancel = cancel
+	m.done = done
+	return
+}
+
+// Stop ends polling, unless already stopped. It waits for any in-flight poll to drain, and returns the last poll error.
+func (m *Manager) Stop() (lastPollErr error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop()
+}
+
+func (m *Manager) stop() (lastPollErr error) {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	lastPollErr = <-m.done
+	m.cancel = nil
+	m.done = nil
+	return
+}
+
+// Restart stops polling (if running), and starts it again. The last poll error is still in the Status.
+func (m *Manager) Restart() (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.stop()
+	return m.start()
+}
+
+func (m *Manager) Status() types.PollStatus {
+	return tracker.get()
+}
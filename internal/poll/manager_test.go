@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePoller counts how often polling begins, and stops polling (after the given drain time) when cancelled
+type fakePoller struct {
+	beginCount int32
+	beginErr   error
+	lastErr    error
+	drainTime  time.Duration
+	drained    int32
+}
+
+func (f *fakePoller) begin(ctx context.Context) (done <-chan error, err error) {
+	atomic.AddInt32(&f.beginCount, 1)
+	if f.beginErr != nil {
+		err = f.beginErr
+		return
+	}
+	doneChan := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		time.Sleep(f.drainTime)
+		atomic.AddInt32(&f.drained, 1)
+		doneChan <- f.lastErr
+	}()
+	done = doneChan
+	return
+}
+
+func TestManagerStartTwice(t *testing.T) {
+	poller := &fakePoller{}
+	manager := NewManager(poller.begin)
+
+	assert.NoError(t, manager.Start())
+	assert.NoError(t, manager.Start())
+	assert.Equal(t, int32(1), poller.beginCount)
+	assert.NoError(t, manager.Stop())
+}
+
+func TestManagerStartError(t *testing.T) {
+	forcedError := fmt.Errorf("forced begin error")
+	poller := &fakePoller{beginErr: forcedError}
+	manager := NewManager(poller.begin)
+
+	assert.EqualError(t, manager.Start(), forcedError.Error())
+	assert.Nil(t, manager.cancel)
+	assert.NoError(t, manager.Stop())
+}
+
+func TestManagerStopWhenStopped(t *testing.T) {
+	manager := NewManager((&fakePoller{}).begin)
+
+	assert.NoError(t, manager.Stop())
+	assert.NoError(t, manager.Start())
+	assert.NoError(t, manager.Stop())
+	assert.NoError(t, manager.Stop())
+}
+
+func TestManagerStopWaitsForDrain(t *testing.T) {
+	forcedError := fmt.Errorf("forced last poll error")
+	poller := &fakePoller{lastErr: forcedError, drainTime: 100 * time.Millisecond}
+	manager := NewManager(poller.begin)
+
+	assert.NoError(t, manager.Start())
+	assert.EqualError(t, manager.Stop(), forcedError.Error())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&poller.drained))
+}
+
+func TestManagerRestart(t *testing.T) {
+	poller := &fakePoller{}
+	manager := NewManager(poller.begin)
+
+	assert.NoError(t, manager.Restart())
+	assert.NoError(t, manager.Restart())
+	assert.Equal(t, int32(2), poller.beginCount)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&poller.drained))
+	assert.NoError(t, manager.Stop())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&poller.drained))
+}
+
+func TestManagerConcurrentCalls(t *testing.T) {
+	poller := &fakePoller{drainTime: time.Millisecond}
+	manager := NewManager(poller.begin)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_ = manager.Start()
+		}()
+		go func() {
+			defer wg.Done()
+			_ = manager.Stop()
+		}()
+		go func() {
+			defer wg.Done()
+			_ = manager.Restart()
+		}()
+	}
+	wg.Wait()
+	_ = manager.Stop()
+
+	// every poller that began was stopped, and drained
+	assert.Equal(t, atomic.LoadInt32(&poller.beginCount), atomic.LoadInt32(&poller.drained))
+}
+
+func TestManagerStatus(t *testing.T) {
+	tracker.reset()
+	tracker.started(time.Minute)
+	defer tracker.reset()
+
+	assert.True(t, NewManager((&fakePoller{}).begin).Status().Running)
+}
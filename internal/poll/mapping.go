@@ -0,0 +1,136 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// Mapping describes which Datadog logs hold scoring messages, and where to find the scoring message fields in each log.
+// Attribute paths are dotted, e.g. "env.envBaseTime" is the envBaseTime attribute inside the env attribute.
+type Mapping struct {
+	Query string `yaml:"query"`
+	// Indexes to search, or all indexes when empty
+	Indexes []string `yaml:"indexes"`
+	// BaseTimePath is the attribute path of the RFC3339 time the scoring event was logged
+	BaseTimePath string `yaml:"baseTimePath"`
+	// MessagePath is the attribute path of the object holding the scoring message fields
+	MessagePath string `yaml:"messagePath"`
+	// Fields maps a scoring message field (e.g. "triggerUser") to its path inside the message object, for fields that
+	// are not logged under their own name
+	Fields map[string]string `yaml:"fields"`
+}
+
+// DefaultMapping reads the scoring logs of production Lift
+func DefaultMapping() Mapping {
+	return Mapping{
+		Query:        fmt.Sprintf("@%s.%s.%s:>0 kube_namespace:lift-production-lift", qryEnv, qryEnvExtraJsonFields, qryFldFixedBugs),
+		BaseTimePath: qryEnv + "." + qryEnvBaseTime,
+		MessagePath:  qryEnv + "." + qryEnvExtraJsonFields,
+	}
+}
+
+// logMapping is used to query and parse Datadog logs, by polling and by imports of Datadog log exports
+var logMapping = DefaultMapping()
+
+// UseMapping replaces the mapping used to query and parse Datadog logs. Call it before polling begins.
+func UseMapping(mapping Mapping) {
+	logMapping = mapping
+}
+
+// LoadMapping reads a YAML mapping file. Any setting missing from the file keeps its default value.
+func LoadMapping(path string) (mapping Mapping, err error) {
+	var content []byte
+	content, err = ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	mapping = DefaultMapping()
+	err = yaml.Unmarshal(content, &mapping)
+	if err != nil {
+		return
+	}
+	err = mapping.validate()
+	return
+}
+
+func (m Mapping) validate() (err error) {
+	if strings.TrimSpace(m.Query) == "" {
+		return fmt.Errorf("datadog mapping requires a query")
+	}
+	if strings.TrimSpace(m.MessagePath) == "" {
+		return fmt.Errorf("datadog mapping requires a messagePath")
+	}
+	knownFields := scoringMessageFields()
+	for field := range m.Fields {
+		if !knownFields[field] {
+			return fmt.Errorf("datadog mapping has unknown scoring message field: %s", field)
+		}
+	}
+	return
+}
+
+// scoringMessageFields are the json names of the ScoringMessage fields
+func scoringMessageFields() (fields map[string]bool) {
+	fields = map[string]bool{}
+	msgType := reflect.TypeOf(types.ScoringMessage{})
+	for i := 0; i < msgType.NumField(); i++ {
+		name := strings.Split(msgType.Field(i).Tag.Get("json"), ",")[0]
+		fields[name] = true
+	}
+	return
+}
+
+// attributeAt finds the value at the dotted path. The parent map holding the value is nil when the path leads nowhere.
+func attributeAt(attributes map[string]interface{}, path string) (value interface{}, parent map[string]interface{}) {
+	keys := strings.Split(path, ".")
+	parent = attributes
+	for _, key := range keys[:len(keys)-1] {
+		child, isMap := parent[key].(map[string]interface{})
+		if !isMap {
+			return nil, nil
+		}
+		parent = child
+	}
+	value = parent[keys[len(keys)-1]]
+	return
+}
+
+// messageValues returns the logged message values, with each mapped field copied to its scoring message name
+func (m Mapping) messageValues(logged map[string]interface{}) (valueMap map[string]interface{}) {
+	if len(m.Fields) == 0 {
+		return logged
+	}
+	valueMap = make(map[string]interface{}, len(logged))
+	for key, value := range logged {
+		valueMap[key] = value
+	}
+	for field, path := range m.Fields {
+		if value, parent := attributeAt(logged, path); parent != nil && value != nil {
+			valueMap[field] = value
+		}
+	}
+	return
+}
@@ -0,0 +1,215 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeMappingFile(t *testing.T, content string) (path string) {
+	path = filepath.Join(t.TempDir(), "mapping.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return
+}
+
+// useTestMapping should always be followed by a deferred call to the restore func
+func useTestMapping(mapping Mapping) (restore func()) {
+	origMapping := logMapping
+	UseMapping(mapping)
+	return func() {
+		UseMapping(origMapping)
+	}
+}
+
+func TestDefaultMapping(t *testing.T) {
+	assert.Equal(t, Mapping{
+		Query:        "@env.envExtraJsonFields.fixed-bugs:>0 kube_namespace:lift-production-lift",
+		BaseTimePath: "env.envBaseTime",
+		MessagePath:  "env.envExtraJsonFields",
+	}, DefaultMapping())
+}
+
+func TestLoadMappingMissingFile(t *testing.T) {
+	_, err := LoadMapping(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.True(t, os.IsNotExist(err), err)
+}
+
+func TestLoadMappingInvalidYaml(t *testing.T) {
+	_, err := LoadMapping(writeMappingFile(t, "query: [unclosed"))
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "yaml: "), err.Error())
+}
+
+func TestLoadMappingEmptyQuery(t *testing.T) {
+	_, err := LoadMapping(writeMappingFile(t, `query: ""`))
+	assert.EqualError(t, err, "datadog mapping requires a query")
+}
+
+func TestLoadMappingEmptyMessagePath(t *testing.T) {
+	_, err := LoadMapping(writeMappingFile(t, `messagePath: " "`))
+	assert.EqualError(t, err, "datadog mapping requires a messagePath")
+}
+
+func TestLoadMappingUnknownField(t *testing.T) {
+	_, err := LoadMapping(writeMappingFile(t, `
+fields:
+  bogusField: someKey
+`))
+	assert.EqualError(t, err, "datadog mapping has unknown scoring message field: bogusField")
+}
+
+func TestLoadMappingKeepsDefaults(t *testing.T) {
+	mapping, err := LoadMapping(writeMappingFile(t, `query: "@scan.fixed:>0 env:staging"`))
+	assert.NoError(t, err)
+	expected := DefaultMapping()
+	expected.Query = "@scan.fixed:>0 env:staging"
+	assert.Equal(t, expected, mapping)
+}
+
+func TestLoadMapping(t *testing.T) {
+	mapping, err := LoadMapping(writeMappingFile(t, `
+query: "@scan.fixed:>0 env:staging"
+indexes:
+  - main
+  - analyzers
+baseTimePath: scan.loggedAt
+messagePath: scan.result
+fields:
+  triggerUser: author.login
+  fixed-bugs: fixedCount
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, Mapping{
+		Query:        "@scan.fixed:>0 env:staging",
+		Indexes:      []string{"main", "analyzers"},
+		BaseTimePath: "scan.loggedAt",
+		MessagePath:  "scan.result",
+		Fields:       map[string]string{"triggerUser": "author.login", "fixed-bugs": "fixedCount"},
+	}, mapping)
+}
+
+func TestAttributeAt(t *testing.T) {
+	attributes := map[string]interface{}{
+		"top": "topValue",
+		"a":   map[string]interface{}{"b": map[string]interface{}{"c": "deepValue"}},
+	}
+
+	value, parent := attributeAt(attributes, "top")
+	assert.Equal(t, "topValue", value)
+	assert.Equal(t, attributes, parent)
+
+	value, parent = attributeAt(attributes, "a.b.c")
+	assert.Equal(t, "deepValue", value)
+	assert.NotNil(t, parent)
+
+	value, parent = attributeAt(attributes, "a.b.missing")
+	assert.Nil(t, value)
+	assert.NotNil(t, parent)
+
+	value, parent = attributeAt(attributes, "a.missing.c")
+	assert.Nil(t, value)
+	assert.Nil(t, parent)
+
+	value, parent = attributeAt(attributes, "top.c")
+	assert.Nil(t, value)
+	assert.Nil(t, parent)
+}
+
+func TestProcessResponseDataCustomMapping(t *testing.T) {
+	defer useTestMapping(Mapping{
+		Query:        "myQuery",
+		BaseTimePath: "scan.loggedAt",
+		MessagePath:  "scan.result",
+		Fields:       map[string]string{"triggerUser": "author.login", "fixed-bugs": "fixedCount"},
+	})()
+
+	logId := "myLogId"
+	responseData := []datadog.Log{
+		{
+			Id: &logId,
+			Attributes: &datadog.LogAttributes{Attributes: map[string]interface{}{
+				"unrelated": "ignored",
+				"scan": map[string]interface{}{
+					"loggedAt": "2022-05-16T10:00:00Z",
+					"result": map[string]interface{}{
+						"eventSource":   "github",
+						"author":        map[string]interface{}{"login": "someone"},
+						"fixedCount":    float64(3),
+						"pullRequestId": float64(5),
+						"analyzerName":  "ignored too",
+					},
+				},
+			}},
+		},
+	}
+
+	logs, err := processResponseData(responseData)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, "2022-05-16T10:00:00Z", logs[0].Fields.envBaseTime.Format(time.RFC3339))
+	assert.Equal(t, types.ScoringMessage{EventSource: "github", TriggerUser: "someone", TotalFixed: 3, PullRequest: 5},
+		logs[0].Fields.scoringMessage)
+}
+
+func TestProcessResponseDataCustomMappingMissingMessage(t *testing.T) {
+	defer useTestMapping(Mapping{Query: "myQuery", MessagePath: "scan.result"})()
+
+	logId := "myLogId"
+	responseData := []datadog.Log{
+		{Id: &logId, Attributes: &datadog.LogAttributes{Attributes: map[string]interface{}{"env": "prod"}}},
+	}
+
+	_, err := processResponseData(responseData)
+	assert.EqualError(t, err, "unexpected attribute map type in map[env:prod]")
+}
+
+func TestFetchLogPageUsesMapping(t *testing.T) {
+	defer useTestMapping(Mapping{Query: "myQuery", Indexes: []string{"myIndex"}, MessagePath: "myMessage"})()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request datadog.LogsListRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "myQuery", request.Filter.GetQuery())
+		assert.Equal(t, []string{"myIndex"}, request.Filter.GetIndexes())
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	urlTs, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+	defer setupMockDDogApiClient(urlTs)()
+
+	pageCursor := ""
+	_, _, _, _, _, err = fetchLogPage(context.Background(), time.Now(), time.Now(), &pageCursor)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,126 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const headerRateLimitRemaining = "X-RateLimit-Remaining"
+const headerRateLimitReset = "X-RateLimit-Reset"
+
+// rateLimit is read from the Datadog rate limit headers of a response
+type rateLimit struct {
+	// remaining requests allowed in the current period, or -1 when unknown
+	remaining int
+	// reset is the time until the current period ends, or zero when unknown
+	reset time.Duration
+}
+
+func readRateLimit(r *http.Response) (limit rateLimit) {
+	limit.remaining = -1
+	if r == nil {
+		return
+	}
+	if remaining, err := strconv.Atoi(r.Header.Get(headerRateLimitRemaining)); err == nil {
+		limit.remaining = remaining
+	}
+	if resetSeconds, err := strconv.Atoi(r.Header.Get(headerRateLimitReset)); err == nil && resetSeconds > 0 {
+		limit.reset = time.Duration(resetSeconds) * time.Second
+	}
+	return
+}
+
+// exhausted is true when no more requests are allowed until the rate limit resets
+func (l rateLimit) exhausted() bool {
+	return l.remaining == 0 && l.reset > 0
+}
+
+// retryableError is a fetch error that may go away if the fetch is tried again later, e.g. being rate limited
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+func isRetryable(err error) bool {
+	var retryable *retryableError
+	return errors.As(err, &retryable)
+}
+
+// isRetryableResponse is true for failed requests that were rate limited, hit a server error, or got no response at all
+func isRetryableResponse(r *http.Response) bool {
+	return r == nil || r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= http.StatusInternalServerError
+}
+
+// retryPolicy is exponential backoff with jitter, except when a rate limit says exactly how long to wait
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	// sleep waits for the given duration, or returns the context error as soon as the context is cancelled
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{
+		maxRetries: 5,
+		baseDelay:  time.Second,
+		maxDelay:   30 * time.Second,
+		sleep:      sleepContext,
+	}
+}
+
+// sleepContext waits for the given duration, unless the context is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// delay before the given retry attempt (starting at zero). The backoff is randomly reduced by up to half, so many
+// clients do not all retry at once.
+func (p retryPolicy) delay(attempt int, limit rateLimit) time.Duration {
+	if limit.exhausted() {
+		return limit.reset
+	}
+	backoff := p.maxDelay
+	if attempt < 30 && p.baseDelay<<attempt < p.maxDelay {
+		backoff = p.baseDelay << attempt
+	}
+	half := int64(backoff / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
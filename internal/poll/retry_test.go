@@ -0,0 +1,405 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
+	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestDatadogSource records the delays it would have slept, instead of sleeping
+func newTestDatadogSource() (source *DatadogSource, sleeps *[]time.Duration) {
+	sleeps = &[]time.Duration{}
+	source = NewDatadogSource()
+	source.retry.sleep = func(_ context.Context, d time.Duration) error {
+		*sleeps = append(*sleeps, d)
+		return nil
+	}
+	return
+}
+
+type standInResponse struct {
+	status  int
+	headers map[string]string
+	body    datadog.LogsListResponse
+	// errorBody is sent instead of the body when set
+	errorBody string
+}
+
+// datadogStandIn is a local stand-in for the Datadog logs API, that sends the given responses in order, and records
+// the requests it got. The last response is repeated once the others have been sent.
+type datadogStandIn struct {
+	mu        sync.Mutex
+	responses []standInResponse
+	requests  []datadog.LogsListRequest
+}
+
+func startDatadogStandIn(t *testing.T, responses ...standInResponse) (standIn *datadogStandIn, closeStandIn func()) {
+	standIn = &datadogStandIn{responses: responses}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		standIn.mu.Lock()
+		defer standIn.mu.Unlock()
+
+		var request datadog.LogsListRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		standIn.requests = append(standIn.requests, request)
+
+		response := standIn.responses[0]
+		if len(standIn.responses) > 1 {
+			standIn.responses = standIn.responses[1:]
+		}
+		for key, value := range response.headers {
+			w.Header().Set(key, value)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(response.status)
+		if response.errorBody != "" {
+			_, _ = w.Write([]byte(response.errorBody))
+			return
+		}
+		body, err := json.Marshal(response.body)
+		assert.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+	urlTs, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+	closeApiClient := setupMockDDogApiClient(urlTs)
+	closeStandIn = func() {
+		closeApiClient()
+		ts.Close()
+	}
+	return
+}
+
+func standInPage(after string, logIds ...string) (response standInResponse) {
+	var logs []datadog.Log
+	for _, logId := range logIds {
+		id := logId
+		logs = append(logs, datadog.Log{
+			Id: &id,
+			Attributes: &datadog.LogAttributes{Attributes: map[string]interface{}{
+				qryEnv: map[string]interface{}{qryEnvExtraJsonFields: map[string]interface{}{"triggerUser": id}},
+			}},
+		})
+	}
+	meta := &datadog.LogsResponseMetadata{Page: &datadog.LogsResponseMetadataPage{}}
+	if after != "" {
+		meta.Page.After = datadog.PtrString(after)
+	}
+	return standInResponse{status: http.StatusOK, body: datadog.LogsListResponse{Data: &logs, Meta: meta}}
+}
+
+func rateLimitHeaders(remaining, resetSeconds int) map[string]string {
+	return map[string]string{
+		headerRateLimitRemaining: fmt.Sprintf("%d", remaining),
+		headerRateLimitReset:     fmt.Sprintf("%d", resetSeconds),
+	}
+}
+
+func TestReadRateLimitNoResponse(t *testing.T) {
+	assert.Equal(t, rateLimit{remaining: -1}, readRateLimit(nil))
+}
+
+func TestReadRateLimitMissingHeaders(t *testing.T) {
+	assert.Equal(t, rateLimit{remaining: -1}, readRateLimit(&http.Response{Header: http.Header{}}))
+}
+
+func TestReadRateLimit(t *testing.T) {
+	r := &http.Response{Header: http.Header{}}
+	r.Header.Set(headerRateLimitRemaining, "0")
+	r.Header.Set(headerRateLimitReset, "12")
+	limit := readRateLimit(r)
+	assert.Equal(t, rateLimit{remaining: 0, reset: 12 * time.Second}, limit)
+	assert.True(t, limit.exhausted())
+}
+
+func TestRateLimitNotExhausted(t *testing.T) {
+	assert.False(t, rateLimit{remaining: 1, reset: time.Second}.exhausted())
+	assert.False(t, rateLimit{remaining: 0}.exhausted())
+	assert.False(t, rateLimit{remaining: -1, reset: time.Second}.exhausted())
+}
+
+func TestIsRetryableResponse(t *testing.T) {
+	assert.True(t, isRetryableResponse(nil))
+	assert.True(t, isRetryableResponse(&http.Response{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, isRetryableResponse(&http.Response{StatusCode: http.StatusServiceUnavailable}))
+	assert.False(t, isRetryableResponse(&http.Response{StatusCode: http.StatusBadRequest}))
+	assert.False(t, isRetryableResponse(&http.Response{StatusCode: http.StatusForbidden}))
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.False(t, isRetryable(fmt.Errorf("not retryable")))
+	assert.True(t, isRetryable(&retryableError{err: fmt.Errorf("retryable")}))
+	assert.True(t, isRetryable(fmt.Errorf("wrapped: %w", &retryableError{err: fmt.Errorf("retryable")})))
+}
+
+func TestRetryPolicyDelayRateLimited(t *testing.T) {
+	assert.Equal(t, 9*time.Second, defaultRetryPolicy().delay(0, rateLimit{remaining: 0, reset: 9 * time.Second}))
+}
+
+func TestRetryPolicyDelayBackoff(t *testing.T) {
+	policy := retryPolicy{baseDelay: time.Second, maxDelay: 10 * time.Second}
+	for attempt, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			delay := policy.delay(attempt, rateLimit{remaining: -1})
+			assert.True(t, delay >= backoff/2 && delay <= backoff, "attempt %d delay %s", attempt, delay)
+		}
+	}
+	// no overflow on silly attempt counts
+	delay := policy.delay(100, rateLimit{remaining: -1})
+	assert.True(t, delay >= 5*time.Second && delay <= 10*time.Second, delay)
+}
+
+func TestFetchPageRetriesRateLimited(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	standIn, closeStandIn := startDatadogStandIn(t,
+		standInResponse{status: http.StatusTooManyRequests, headers: rateLimitHeaders(0, 7)},
+		standInPage("", "myLogId"),
+	)
+	defer closeStandIn()
+
+	source, sleeps := newTestDatadogSource()
+	events, nextCursor, err := source.FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", nextCursor)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "myLogId", events[0].Id)
+	assert.Equal(t, []time.Duration{7 * time.Second}, *sleeps)
+	assert.Equal(t, 2, len(standIn.requests))
+}
+
+func TestFetchPageRetriesServerErrorsWithBackoff(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	_, closeStandIn := startDatadogStandIn(t,
+		standInResponse{status: http.StatusServiceUnavailable},
+		standInResponse{status: http.StatusBadGateway},
+		standInPage("", "myLogId"),
+	)
+	defer closeStandIn()
+
+	source, sleeps := newTestDatadogSource()
+	events, _, err := source.FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, 2, len(*sleeps))
+	assert.True(t, (*sleeps)[0] >= 500*time.Millisecond && (*sleeps)[0] <= time.Second, (*sleeps)[0])
+	assert.True(t, (*sleeps)[1] >= time.Second && (*sleeps)[1] <= 2*time.Second, (*sleeps)[1])
+}
+
+func TestFetchPageRetriesTimeoutStatus(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	timeoutResponse := standInPage("")
+	timeoutResponse.body.Meta.Status = datadog.LOGSAGGREGATERESPONSESTATUS_TIMEOUT.Ptr()
+	_, closeStandIn := startDatadogStandIn(t, timeoutResponse, standInPage("", "myLogId"))
+	defer closeStandIn()
+
+	source, sleeps := newTestDatadogSource()
+	events, _, err := source.FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, 1, len(*sleeps))
+}
+
+func TestFetchPageDoesNotRetryBadRequest(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	standIn, closeStandIn := startDatadogStandIn(t, standInResponse{status: http.StatusBadRequest, errorBody: `{"errors": ["invalid query"]}`})
+	defer closeStandIn()
+
+	source, sleeps := newTestDatadogSource()
+	_, _, err := source.FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.EqualError(t, err, "400 Bad Request")
+	assert.Equal(t, 0, len(*sleeps))
+	assert.Equal(t, 1, len(standIn.requests))
+}
+
+func TestFetchPageGivesUpAfterMaxRetries(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	standIn, closeStandIn := startDatadogStandIn(t, standInResponse{status: http.StatusInternalServerError})
+	defer closeStandIn()
+
+	source, sleeps := newTestDatadogSource()
+	_, _, err := source.FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.EqualError(t, err, "500 Internal Server Error")
+	assert.True(t, isRetryable(err))
+	assert.Equal(t, source.retry.maxRetries, len(*sleeps))
+	assert.Equal(t, source.retry.maxRetries+1, len(standIn.requests))
+}
+
+func TestFetchPageWaitsForExhaustedRateLimit(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	page := standInPage("myCursor", "myLogId")
+	page.headers = rateLimitHeaders(0, 3)
+	_, closeStandIn := startDatadogStandIn(t, page)
+	defer closeStandIn()
+
+	source, sleeps := newTestDatadogSource()
+	_, nextCursor, err := source.FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "myCursor", nextCursor)
+	assert.Equal(t, []time.Duration{3 * time.Second}, *sleeps)
+}
+
+func TestFetchPageLastPageDoesNotWaitForRateLimit(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	page := standInPage("", "myLogId")
+	page.headers = rateLimitHeaders(0, 3)
+	_, closeStandIn := startDatadogStandIn(t, page)
+	defer closeStandIn()
+
+	source, sleeps := newTestDatadogSource()
+	_, _, err := source.FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(*sleeps))
+}
+
+func TestSleepContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, sleepContext(ctx, time.Hour))
+}
+
+func TestSleepContextWaits(t *testing.T) {
+	assert.NoError(t, sleepContext(context.Background(), time.Millisecond))
+}
+
+func TestFetchPageStopsRetryWaitWhenCancelled(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	standIn, closeStandIn := startDatadogStandIn(t, standInResponse{status: http.StatusServiceUnavailable})
+	defer closeStandIn()
+
+	source := NewDatadogSource()
+	source.retry.baseDelay = time.Hour
+	source.retry.maxDelay = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, _, err := source.FetchPage(ctx, time.Now(), time.Now(), "")
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < 5*time.Second, time.Since(start))
+	assert.Equal(t, 1, len(standIn.requests))
+}
+
+func TestFetchPageStopsRateLimitWaitWhenCancelled(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	page := standInPage("myCursor", "myLogId")
+	page.headers = rateLimitHeaders(0, 3600)
+	_, closeStandIn := startDatadogStandIn(t, page)
+	defer closeStandIn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, _, err := NewDatadogSource().FetchPage(ctx, time.Now(), time.Now(), "")
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < 5*time.Second, time.Since(start))
+}
+
+func TestPollTheDogResumesFromFailedPage(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+
+	standIn, closeStandIn := startDatadogStandIn(t,
+		standInPage("cursor1", "firstLogId"),
+		standInResponse{status: http.StatusBadRequest, errorBody: `{"errors": ["invalid query"]}`},
+		standInPage("", "secondLogId"),
+	)
+	defer closeStandIn()
+	source, _ := newTestDatadogSource()
+
+	firstNow := time.Now().Truncate(time.Second)
+	db.SetupMockPollSelect(mock, pollId, firstNow.Add(-time.Minute))
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, true)
+	var resume pageResume
+	var events []ScoreEvent
+	_, _, err := pollTheDog(context.Background(), dbPoll, source, testLease, firstNow, firstNow, &resume, collectEvents(&events))
+	assert.EqualError(t, err, "400 Bad Request")
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "firstLogId", events[0].Id)
+	assert.Equal(t, "cursor1", resume.cursor)
+	assert.Equal(t, firstNow, resume.to)
+
+	// the next poll carries on from the failed page, and completes the original poll window
+	secondNow := firstNow.Add(time.Minute)
+	db.SetupMockPollSelectAndUpdateHeld(mock, pollId, testHolderId, firstNow)
+	events = nil
+	_, _, err = pollTheDog(context.Background(), dbPoll, source, testLease, secondNow, secondNow, &resume, collectEvents(&events))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "secondLogId", events[0].Id)
+	assert.Equal(t, pageResume{}, resume)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 3, len(standIn.requests))
+	resumed := standIn.requests[2]
+	assert.Equal(t, "cursor1", resumed.Page.GetCursor())
+	assert.Equal(t, standIn.requests[0].Filter.GetFrom(), resumed.Filter.GetFrom())
+	assert.Equal(t, firstNow.Format(time.RFC3339), resumed.Filter.GetTo())
+}
+
+func TestChaseTailScoresPagesFetchedBeforeError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	tracker.reset()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	pollId := dbPoll.NewPoll().Id
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, true)
+	db.SetupMockPollSelect(mock, pollId, time.Now())
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, true)
+
+	_, closeStandIn := startDatadogStandIn(t,
+		standInPage("cursor1", "firstLogId"),
+		standInResponse{status: http.StatusBadRequest, errorBody: `{"errors": ["invalid query"]}`},
+	)
+	defer closeStandIn()
+	source, _ := newTestDatadogSource()
+
+	var scoredUsers []string
+	scoreMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		scoredUsers = append(scoredUsers, msg.TriggerUser)
+		return 1, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ChaseTail(ctx, dbPoll, createMockScoreDb(t), source, 1, testHolderId, scoreMessage)
+	time.Sleep(1500 * time.Millisecond)
+	cancel()
+
+	assert.EqualError(t, <-done, "400 Bad Request")
+	assert.Equal(t, []string{"firstLogId"}, scoredUsers)
+	assert.Equal(t, int64(1), Status().Accepted)
+}
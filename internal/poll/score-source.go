@@ -0,0 +1,117 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"os"
+	"time"
+)
+
+// ScoreEvent is a single scoring message read from a ScoreSource.
+type ScoreEvent struct {
+	// Id uniquely identifies the event within its source, e.g. the Datadog log id.
+	Id string `json:"id"`
+	// BaseTime is when the event was logged by the source.
+	BaseTime       time.Time            `json:"baseTime"`
+	ScoringMessage types.ScoringMessage `json:"scoringMessage"`
+	// ParseErr is set when the source could not read the event, which is then dead lettered rather than scored.
+	ParseErr error `json:"-"`
+	// Raw is the unreadable event, as it was read from the source.
+	Raw []byte `json:"-"`
+}
+
+// Message returns the event's scoring message, identified by the event id unless it already has a source id.
+func (e ScoreEvent) Message() types.ScoringMessage {
+	msg := e.ScoringMessage
+	if msg.SourceId == "" {
+		msg.SourceId = e.Id
+	}
+	return msg
+}
+
+// ScoreTime is when the event should be scored: asOf if given, or else when the event was logged, or else right now.
+func (e ScoreEvent) ScoreTime(asOf time.Time) time.Time {
+	if !asOf.IsZero() {
+		return asOf
+	}
+	if !e.BaseTime.IsZero() {
+		return e.BaseTime
+	}
+	return time.Now()
+}
+
+// ScoreSource provides the scoring events logged between two times. Sources that page through their results return
+// a non-empty cursor until the final page has been read, and expect that cursor to be passed back to get the next page.
+// A fetch stops waiting, and returns the context error, when the context is cancelled.
+type ScoreSource interface {
+	FetchPage(ctx context.Context, from, to time.Time, cursor string) (events []ScoreEvent, nextCursor string, err error)
+}
+
+const SourceDatadog = "datadog"
+const SourceJsonl = "jsonl"
+
+// FileSource is a ScoreSource that reads ScoreEvent records from a JSONL file, one record per line.
+// The file is re-read on every fetch, so records may be appended while polling is running.
+type FileSource struct {
+	path string
+}
+
+var _ ScoreSource = (*FileSource)(nil)
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// FetchPage returns all records with a BaseTime in the range (from, to], as a single page.
+func (s *FileSource) FetchPage(_ context.Context, from, to time.Time, _ string) (events []ScoreEvent, nextCursor string, err error) {
+	var file *os.File
+	file, err = os.Open(s.path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		event := ScoreEvent{}
+		if err = json.Unmarshal(line, &event); err != nil {
+			err = fmt.Errorf("invalid score event on line %d of %s: %w", lineNumber, s.path, err)
+			return
+		}
+		if event.BaseTime.After(from) && !event.BaseTime.After(to) {
+			events = append(events, event)
+		}
+	}
+	err = scanner.Err()
+	return
+}
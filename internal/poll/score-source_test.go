@@ -0,0 +1,155 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"context"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeScoreSource returns its pages in order, one per call, and records the cursors it was given.
+// fakeScoreSource returns its pages, then err (if any) for the page after them
+type fakeScoreSource struct {
+	pages      [][]ScoreEvent
+	err        error
+	cursorsGot []string
+}
+
+var _ ScoreSource = (*fakeScoreSource)(nil)
+
+func (f *fakeScoreSource) FetchPage(_ context.Context, _, _ time.Time, cursor string) (events []ScoreEvent, nextCursor string, err error) {
+	f.cursorsGot = append(f.cursorsGot, cursor)
+	pageIndex := len(f.cursorsGot) - 1
+	if f.err != nil && pageIndex == len(f.pages) {
+		err = f.err
+		return
+	}
+	events = f.pages[pageIndex]
+	if pageIndex < len(f.pages)-1 || f.err != nil {
+		nextCursor = fmt.Sprintf("cursor%d", pageIndex+1)
+	}
+	return
+}
+
+func TestPollTheDogFakeSourceMultiplePages(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	pollId := dbPoll.NewPoll().Id
+	db.SetupMockPollSelect(mock, pollId, now)
+	// the lease is renewed before the second page
+	db.SetupMockPollAcquireLease(mock, pollId, testHolderId, true)
+	db.SetupMockPollUpdateHeld(mock, pollId, testHolderId, now, true)
+
+	source := &fakeScoreSource{
+		pages: [][]ScoreEvent{
+			{{Id: "one"}, {Id: "two"}},
+			{{Id: "three"}},
+		},
+	}
+	var events []ScoreEvent
+	_, _, err := pollTheDog(context.Background(), dbPoll, source, testLease, now, now, &pageResume{}, collectEvents(&events))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "cursor1"}, source.cursorsGot)
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "three", events[2].Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollTheDogFakeSourceError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	db.SetupMockPollSelect(mock, dbPoll.NewPoll().Id, now)
+
+	forcedError := fmt.Errorf("forced source error")
+	var events []ScoreEvent
+	_, _, err := pollTheDog(context.Background(), dbPoll, &fakeScoreSource{err: forcedError}, testLease, now, now, &pageResume{}, collectEvents(&events))
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, events)
+}
+
+func writeJsonlFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestFileSourceMissingFile(t *testing.T) {
+	events, cursor, err := NewFileSource("no-such-file.jsonl").FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.Error(t, err)
+	assert.Nil(t, events)
+	assert.Equal(t, "", cursor)
+}
+
+func TestFileSourceInvalidLine(t *testing.T) {
+	path := writeJsonlFile(t, "\n{bogus\n")
+	events, _, err := NewFileSource(path).FetchPage(context.Background(), time.Now(), time.Now(), "")
+	assert.EqualError(t, err, fmt.Sprintf("invalid score event on line 2 of %s: invalid character 'b' looking for beginning of object key string", path))
+	assert.Nil(t, events)
+}
+
+func TestFileSourceFiltersByTime(t *testing.T) {
+	path := writeJsonlFile(t, `{"id": "early", "baseTime": "2022-05-16T10:00:00Z", "scoringMessage": {"triggerUser": "a"}}
+{"id": "inRange", "baseTime": "2022-05-16T11:00:00Z", "scoringMessage": {"triggerUser": "b", "fixed-bugs": 2, "pullRequestId": 5}}
+
+{"id": "late", "baseTime": "2022-05-16T13:00:00Z", "scoringMessage": {"triggerUser": "c"}}
+`)
+	from, err := time.Parse(time.RFC3339, "2022-05-16T10:00:00Z")
+	assert.NoError(t, err)
+	to := from.Add(2 * time.Hour)
+
+	events, cursor, err := NewFileSource(path).FetchPage(context.Background(), from, to, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", cursor)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "inRange", events[0].Id)
+	assert.Equal(t, types.ScoringMessage{TriggerUser: "b", TotalFixed: 2, PullRequest: 5}, events[0].ScoringMessage)
+}
+
+func TestScoreEventMessage(t *testing.T) {
+	assert.Equal(t, types.ScoringMessage{SourceId: "myId"}, ScoreEvent{Id: "myId"}.Message())
+	assert.Equal(t, types.ScoringMessage{SourceId: "mySourceId"},
+		ScoreEvent{Id: "myId", ScoringMessage: types.ScoringMessage{SourceId: "mySourceId"}}.Message())
+}
+
+func TestScoreEventScoreTime(t *testing.T) {
+	asOf := time.Now().Add(-time.Hour)
+	baseTime := asOf.Add(-time.Hour)
+	assert.Equal(t, asOf, ScoreEvent{BaseTime: baseTime}.ScoreTime(asOf))
+	assert.Equal(t, baseTime, ScoreEvent{BaseTime: baseTime}.ScoreTime(time.Time{}))
+
+	before := time.Now()
+	assert.False(t, ScoreEvent{}.ScoreTime(time.Time{}).Before(before))
+}
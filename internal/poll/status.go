@@ -0,0 +1,122 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is the number of most recent poll errors kept for the poll status
+const maxRecentErrors = 20
+
+// statusTracker is updated by the poller, and read by status requests
+type statusTracker struct {
+	mu     sync.Mutex
+	status types.PollStatus
+	// nextError is the oldest error in the RecentErrors ring buffer, once it is full
+	nextError int
+}
+
+var tracker = &statusTracker{}
+
+// Status returns the polling status of this server instance, with recent errors oldest first
+func Status() types.PollStatus {
+	return tracker.get()
+}
+
+func (s *statusTracker) get() (pollStatus types.PollStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pollStatus = s.status
+	pollStatus.RecentErrors = make([]types.PollError, 0, len(s.status.RecentErrors))
+	pollStatus.RecentErrors = append(pollStatus.RecentErrors, s.status.RecentErrors[s.nextError:]...)
+	pollStatus.RecentErrors = append(pollStatus.RecentErrors, s.status.RecentErrors[:s.nextError]...)
+	return
+}
+
+func (s *statusTracker) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = types.PollStatus{}
+	s.nextError = 0
+}
+
+func (s *statusTracker) started(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = true
+	s.status.IntervalSeconds = interval.Seconds()
+}
+
+func (s *statusTracker) stopped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.LeaseHeld = false
+}
+
+func (s *statusTracker) setLeaseHeld(held bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LeaseHeld = held
+}
+
+// polled records the window of a poll slice, and how far it is behind the time being caught up to
+func (s *statusTracker) polled(windowStart, windowEnd time.Time, logsFetched int, catchUpTo time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.WindowStart = windowStart
+	s.status.WindowEnd = windowEnd
+	s.status.LogsFetched = logsFetched
+	s.status.CatchingUp = windowEnd.Before(catchUpTo)
+	s.status.CatchUpRemainingSeconds = 0
+	if s.status.CatchingUp {
+		s.status.CatchUpRemainingSeconds = catchUpTo.Sub(windowEnd).Seconds()
+	}
+}
+
+// processed counts the outcome of processing a single message
+func (s *statusTracker) processed(scoredCount int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err != nil:
+		s.status.Failed++
+	case scoredCount == 0:
+		s.status.Skipped++
+	default:
+		s.status.Accepted++
+	}
+}
+
+func (s *statusTracker) addError(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pollError := types.PollError{Time: now, Error: err.Error()}
+	if len(s.status.RecentErrors) < maxRecentErrors {
+		s.status.RecentErrors = append(s.status.RecentErrors, pollError)
+		return
+	}
+	s.status.RecentErrors[s.nextError] = pollError
+	s.nextError = (s.nextError + 1) % maxRecentErrors
+}
@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStatusEmpty(t *testing.T) {
+	tracker.reset()
+	assert.Equal(t, types.PollStatus{RecentErrors: []types.PollError{}}, Status())
+}
+
+func TestStatusRunning(t *testing.T) {
+	tracker.reset()
+	tracker.started(time.Minute)
+	tracker.setLeaseHeld(true)
+	assert.True(t, Status().Running)
+	assert.True(t, Status().LeaseHeld)
+	assert.Equal(t, float64(60), Status().IntervalSeconds)
+
+	tracker.stopped()
+	assert.False(t, Status().Running)
+	assert.False(t, Status().LeaseHeld)
+}
+
+func TestStatusPolled(t *testing.T) {
+	tracker.reset()
+	now := time.Now()
+	tracker.polled(now.Add(-time.Minute), now, 3, now)
+
+	pollStatus := Status()
+	assert.Equal(t, now.Add(-time.Minute), pollStatus.WindowStart)
+	assert.Equal(t, now, pollStatus.WindowEnd)
+	assert.Equal(t, 3, pollStatus.LogsFetched)
+	assert.False(t, pollStatus.CatchingUp)
+	assert.Equal(t, float64(0), pollStatus.CatchUpRemainingSeconds)
+}
+
+func TestStatusCatchingUp(t *testing.T) {
+	tracker.reset()
+	now := time.Now()
+	tracker.polled(now.Add(-3*time.Hour), now.Add(-2*time.Hour), 3, now)
+
+	pollStatus := Status()
+	assert.True(t, pollStatus.CatchingUp)
+	assert.Equal(t, (2 * time.Hour).Seconds(), pollStatus.CatchUpRemainingSeconds)
+
+	tracker.polled(now.Add(-time.Hour), now, 0, now)
+	pollStatus = Status()
+	assert.False(t, pollStatus.CatchingUp)
+	assert.Equal(t, float64(0), pollStatus.CatchUpRemainingSeconds)
+}
+
+func TestStatusRecentErrorsWrapAround(t *testing.T) {
+	tracker.reset()
+	now := time.Now()
+	for i := 0; i < maxRecentErrors+2; i++ {
+		tracker.addError(now, fmt.Errorf("error %d", i))
+	}
+
+	recentErrors := Status().RecentErrors
+	assert.Equal(t, maxRecentErrors, len(recentErrors))
+	assert.Equal(t, "error 2", recentErrors[0].Error)
+	assert.Equal(t, fmt.Sprintf("error %d", maxRecentErrors+1), recentErrors[maxRecentErrors-1].Error)
+}
+
+func TestStatusIsACopy(t *testing.T) {
+	tracker.reset()
+	tracker.addError(time.Now(), fmt.Errorf("original"))
+
+	Status().RecentErrors[0].Error = "changed"
+	assert.Equal(t, "original", Status().RecentErrors[0].Error)
+}
+
+func TestStatusCountsProcessedLogs(t *testing.T) {
+	tracker.reset()
+	events := []ScoreEvent{
+		{ScoringMessage: types.ScoringMessage{TriggerUser: "accepted"}},
+		{ScoringMessage: types.ScoringMessage{TriggerUser: "skipped"}},
+		{ScoringMessage: types.ScoringMessage{TriggerUser: "failed"}},
+		{ParseErr: fmt.Errorf("unreadable"), Raw: []byte("bogus")},
+	}
+	scoreMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+		switch msg.TriggerUser {
+		case "accepted":
+			scoredCount = 2
+		case "failed":
+			err = fmt.Errorf("forced score error")
+		}
+		return
+	}
+
+	assert.NoError(t, processLogs(createMockScoreDb(t), events, time.Now(), scoreMessage))
+	pollStatus := Status()
+	assert.Equal(t, int64(1), pollStatus.Accepted)
+	assert.Equal(t, int64(1), pollStatus.Skipped)
+	assert.Equal(t, int64(2), pollStatus.Failed)
+}
@@ -0,0 +1,97 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sort"
+)
+
+// Breakdown explains the score of a participant by the pull requests they scored, with the points scored in each
+// repository and the bugs fixed in each category path, both ordered from the most down. The breakdown keeps the
+// participant score, which may differ from the total of the pull requests after an admin changed it.
+func Breakdown(participant *types.ParticipantStruct, pullRequests []types.ScoredPullRequest) (breakdown types.ScoreBreakdown) {
+	breakdown = types.ScoreBreakdown{
+		CampaignName: participant.CampaignName,
+		ScpName:      participant.ScpName,
+		LoginName:    participant.LoginName,
+		Score:        participant.Score,
+		PullRequests: pullRequests,
+		Repositories: repositoryScores(pullRequests),
+		Categories:   []types.CategoryScore{},
+	}
+	if breakdown.PullRequests == nil {
+		breakdown.PullRequests = []types.ScoredPullRequest{}
+	}
+
+	categories := map[string]int{}
+	for _, pullRequest := range pullRequests {
+		// a bug count of an unexpected type scored nothing, so is left out here too
+		counts, _ := Categories(pullRequest.BugCounts)
+		for category, fixed := range counts {
+			i, found := categories[category]
+			if !found {
+				i = len(breakdown.Categories)
+				categories[category] = i
+				breakdown.Categories = append(breakdown.Categories, types.CategoryScore{Category: category})
+			}
+			breakdown.Categories[i].Fixed += fixed
+			breakdown.Categories[i].PullRequests++
+		}
+	}
+
+	sort.SliceStable(breakdown.Categories, func(i, j int) bool {
+		left, right := breakdown.Categories[i], breakdown.Categories[j]
+		if left.Fixed != right.Fixed {
+			return left.Fixed > right.Fixed
+		}
+		return left.Category < right.Category
+	})
+	return
+}
+
+// repositoryScores totals the points scored in each repository, ordered from the most points down, then by name.
+func repositoryScores(pullRequests []types.ScoredPullRequest) (repositories []types.RepositoryScore) {
+	repositories = []types.RepositoryScore{}
+	indexes := map[string]int{}
+	for _, pullRequest := range pullRequests {
+		repository := pullRequest.RepoOwner + "/" + pullRequest.RepoName
+		i, found := indexes[repository]
+		if !found {
+			i = len(repositories)
+			indexes[repository] = i
+			repositories = append(repositories, types.RepositoryScore{RepoOwner: pullRequest.RepoOwner, RepoName: pullRequest.RepoName})
+		}
+		repositories[i].PullRequests++
+		repositories[i].Points = RoundPoints(repositories[i].Points + pullRequest.Points)
+	}
+
+	sort.SliceStable(repositories, func(i, j int) bool {
+		left, right := repositories[i], repositories[j]
+		if left.Points != right.Points {
+			return left.Points > right.Points
+		}
+		if left.RepoOwner != right.RepoOwner {
+			return left.RepoOwner < right.RepoOwner
+		}
+		return left.RepoName < right.RepoName
+	})
+	return
+}
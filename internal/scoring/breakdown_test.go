@@ -0,0 +1,67 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBreakdownNoPullRequests(t *testing.T) {
+	participant := &types.ParticipantStruct{CampaignName: "myCampaign", ScpName: "myScp", LoginName: "myLogin", Score: 2}
+	assert.Equal(t, types.ScoreBreakdown{
+		CampaignName: "myCampaign",
+		ScpName:      "myScp",
+		LoginName:    "myLogin",
+		Score:        2,
+		PullRequests: []types.ScoredPullRequest{},
+		Repositories: []types.RepositoryScore{},
+		Categories:   []types.CategoryScore{},
+	}, Breakdown(participant, nil))
+}
+
+func TestBreakdown(t *testing.T) {
+	scoredOn := time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC)
+	pullRequests := []types.ScoredPullRequest{
+		{RepoOwner: "owner", RepoName: "small", PullRequest: 1, Points: 0.1,
+			BugCounts: map[string]interface{}{"opt": map[string]interface{}{"semgrep": float64(1)}}, ScoredOn: &scoredOn},
+		{RepoOwner: "owner", RepoName: "big", PullRequest: 2, Points: 5,
+			BugCounts: map[string]interface{}{"opt": map[string]interface{}{"semgrep": float64(2)}, "NULL_DEREFERENCE": float64(1)}},
+		{RepoOwner: "owner", RepoName: "small", PullRequest: 3, Points: 0.2,
+			BugCounts: map[string]interface{}{"bogus": "count", "NULL_DEREFERENCE": float64(2)}},
+		{RepoOwner: "other", RepoName: "big", PullRequest: 4, Points: 5},
+	}
+	participant := &types.ParticipantStruct{CampaignName: "myCampaign", ScpName: "myScp", LoginName: "myLogin", Score: 10.3}
+
+	breakdown := Breakdown(participant, pullRequests)
+	assert.Equal(t, 10.3, breakdown.Score)
+	assert.Equal(t, pullRequests, breakdown.PullRequests)
+	assert.Equal(t, []types.RepositoryScore{
+		{RepoOwner: "other", RepoName: "big", PullRequests: 1, Points: 5},
+		{RepoOwner: "owner", RepoName: "big", PullRequests: 1, Points: 5},
+		{RepoOwner: "owner", RepoName: "small", PullRequests: 2, Points: 0.3},
+	}, breakdown.Repositories)
+	assert.Equal(t, []types.CategoryScore{
+		{Category: "NULL_DEREFERENCE", Fixed: 3, PullRequests: 2},
+		{Category: "opt.semgrep", Fixed: 3, PullRequests: 2},
+	}, breakdown.Categories)
+}
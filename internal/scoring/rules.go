@@ -0,0 +1,269 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"math"
+	"strings"
+)
+
+// DefaultRules are the rules of a campaign without rules of its own: each bug is worth its bug category point value,
+// and each unclassified fixed bug is worth one point.
+func DefaultRules() types.ScoringRules {
+	return types.ScoringRules{UnclassifiedPoints: 1}
+}
+
+// PointDecimals is the number of decimal places points are stored with.
+const PointDecimals = 2
+
+// maxPoints is the largest number of points that can be stored, as points are NUMERIC(12, 2).
+const maxPoints = 1e10
+
+// RoundPoints rounds points to the PointDecimals stored.
+func RoundPoints(points float64) float64 {
+	scale := math.Pow10(PointDecimals)
+	return math.Round(points*scale) / scale
+}
+
+// ValidatePoints reports points that can not be stored as they are, since they have more than PointDecimals decimal
+// places or are too large.
+func ValidatePoints(name string, points float64) (err error) {
+	switch {
+	case math.IsNaN(points) || math.Abs(points) >= maxPoints:
+		return fmt.Errorf("%s is out of range: %v", name, points)
+	case math.Abs(points-RoundPoints(points)) > 1e-9:
+		return fmt.Errorf("%s must not have more than %d decimal places: %v", name, PointDecimals, points)
+	}
+	return
+}
+
+// Validate reports the first rule that can not be applied.
+func Validate(rules *types.ScoringRules) (err error) {
+	if err = ValidatePoints("unclassifiedPoints", rules.UnclassifiedPoints); err != nil {
+		return
+	}
+	if err = ValidatePoints("pullRequestCap", rules.PullRequestCap); err != nil {
+		return
+	}
+	if err = ValidatePoints("dailyCap", rules.DailyCap); err != nil {
+		return
+	}
+	if err = ValidatePoints("firstFixBonus", rules.FirstFixBonus); err != nil {
+		return
+	}
+	switch {
+	case rules.UnclassifiedPoints < 0:
+		return fmt.Errorf("unclassifiedPoints must not be negative: %v", rules.UnclassifiedPoints)
+	case rules.PullRequestCap < 0:
+		return fmt.Errorf("pullRequestCap must not be negative: %v", rules.PullRequestCap)
+	case rules.DailyCap < 0:
+		return fmt.Errorf("dailyCap must not be negative: %v", rules.DailyCap)
+	case rules.FirstFixBonus < 0:
+		return fmt.Errorf("firstFixBonus must not be negative: %v", rules.FirstFixBonus)
+	}
+	for _, multiplier := range rules.Multipliers {
+		if multiplier.RepoOwner == "" {
+			return fmt.Errorf("multiplier is missing repositoryOwner: %+v", multiplier)
+		}
+		if multiplier.Factor < 0 {
+			return fmt.Errorf("multiplier factor must not be negative: %+v", multiplier)
+		}
+	}
+	for _, category := range rules.ZeroedCategories {
+		if category == "" {
+			return fmt.Errorf("zeroedCategories must not be empty")
+		}
+	}
+	return validateTeamScore(rules)
+}
+
+// CategorySeparator joins the keys of nested bug counts into a category path, e.g. "ErrorProne.NullAway".
+const CategorySeparator = "."
+
+// Score prices the fixed bugs of a scoring message by the campaign rules. pointValue looks up the campaign point value
+// of a bug category, and history is what the participant scored before, in other pull requests. resolved maps each
+// category path of the message to the bug category that priced it (see Resolve), or to "" when no bug category did.
+// A bug count of an unexpected type is reported in err, but the rest of the message is still priced. Points are
+// rounded to PointDecimals decimal places.
+//
+// The rules are applied in order: bug category points (zero for zeroed categories, and unclassified points for a
+// category without a point value), unclassified points, the repository multiplier, the first fix bonus for each
+// category new to the participant, the pull request cap, and finally the daily cap, which limits the points to those
+// left for the participant today.
+func Score(rules *types.ScoringRules, msg *types.ScoringMessage, pointValue func(category string) (value float64, found bool),
+	history *types.ScoringHistory) (points float64, resolved map[string]string, err error) {
+
+	var counts map[string]float64
+	counts, err = Categories(msg.BugCounts)
+
+	zeroed := zeroedCategories(rules)
+	fixedBefore := map[string]bool{}
+	for _, bugCounts := range history.BugCounts {
+		// history was priced when it was scored, so a bad bug count has already been reported
+		priorCounts, _ := Categories(bugCounts)
+		for category, count := range priorCounts {
+			if count > 0 {
+				fixedBefore[category] = true
+			}
+		}
+	}
+
+	resolved = map[string]string{}
+	classified := float64(0)
+	newCategories := 0
+	for category, count := range counts {
+		classified += count
+		if isZeroed(zeroed, category) {
+			resolved[category] = ""
+			continue
+		}
+		value, resolvedCategory := Resolve(category, pointValue)
+		resolved[category] = resolvedCategory
+		if resolvedCategory == "" {
+			value = rules.UnclassifiedPoints
+		}
+		points += count * value
+		if count > 0 && !fixedBefore[category] {
+			newCategories++
+		}
+	}
+
+	if classified < float64(msg.TotalFixed) {
+		points += (float64(msg.TotalFixed) - classified) * rules.UnclassifiedPoints
+	}
+
+	points *= multiplier(rules, msg)
+	points += float64(newCategories) * rules.FirstFixBonus
+
+	if rules.PullRequestCap > 0 {
+		points = math.Min(points, rules.PullRequestCap)
+	}
+	if rules.DailyCap > 0 {
+		points = math.Min(points, math.Max(rules.DailyCap-history.PointsToday, 0))
+	}
+
+	points = RoundPoints(points)
+	return
+}
+
+// Resolve finds the point value of a category path from the most specific bug category with a point value: the path
+// itself, then its last key alone (as bug categories were named before paths), then each parent path, nearest first.
+// e.g. "ErrorProne.NullAway" tries "ErrorProne.NullAway", "NullAway", then "ErrorProne". resolvedCategory is "" when
+// none of them has a point value.
+func Resolve(categoryPath string, pointValue func(category string) (value float64, found bool)) (value float64, resolvedCategory string) {
+	for _, candidate := range candidates(categoryPath) {
+		if candidateValue, found := pointValue(candidate); found {
+			return candidateValue, candidate
+		}
+	}
+	return
+}
+
+func candidates(categoryPath string) (paths []string) {
+	keys := strings.Split(categoryPath, CategorySeparator)
+	paths = append(paths, categoryPath)
+	if len(keys) > 1 {
+		paths = append(paths, keys[len(keys)-1])
+	}
+	for i := len(keys) - 1; i > 0; i-- {
+		paths = append(paths, strings.Join(keys[:i], CategorySeparator))
+	}
+	return
+}
+
+// ValidCategoryPath is true when no key of the category path is empty, e.g. "ErrorProne." is not valid.
+func ValidCategoryPath(categoryPath string) bool {
+	for _, key := range strings.Split(categoryPath, CategorySeparator) {
+		if key == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func zeroedCategories(rules *types.ScoringRules) (zeroed map[string]bool) {
+	zeroed = map[string]bool{}
+	for _, category := range rules.ZeroedCategories {
+		zeroed[category] = true
+	}
+	return
+}
+
+// isZeroed is true when the category path, its last key or any parent path is zeroed.
+func isZeroed(zeroed map[string]bool, categoryPath string) bool {
+	for _, candidate := range candidates(categoryPath) {
+		if zeroed[candidate] {
+			return true
+		}
+	}
+	return false
+}
+
+// Categories totals the bug counts of each category path, e.g. {"opt": {"semgrep": {"node_password": 1}}} is one bug
+// of category path "opt.semgrep.node_password". A count of an unexpected type is skipped, and the last one is reported
+// in err.
+func Categories(bugCounts map[string]interface{}) (counts map[string]float64, err error) {
+	counts = map[string]float64{}
+	err = addCategories(counts, "", bugCounts)
+	return
+}
+
+func addCategories(counts map[string]float64, parentPath string, bugCounts map[string]interface{}) (err error) {
+	for bugType, bugValue := range bugCounts {
+		categoryPath := bugType
+		if parentPath != "" {
+			categoryPath = parentPath + CategorySeparator + bugType
+		}
+		switch v := bugValue.(type) {
+		case float64:
+			counts[categoryPath] += v
+		case map[string]interface{}:
+			// oh joy, recursion.
+			if nestedErr := addCategories(counts, categoryPath, v); nestedErr != nil {
+				err = nestedErr
+			}
+		default:
+			err = fmt.Errorf("bugType: %+v has unexpected bugValue type: %+v", categoryPath, v)
+		}
+	}
+	return
+}
+
+// multiplier is the factor of the most specific multiplier matching the repository of the message, where a repository
+// multiplier beats an organization multiplier, or one when none match.
+func multiplier(rules *types.ScoringRules, msg *types.ScoringMessage) (factor float64) {
+	factor = 1
+	matchedRepo := false
+	for _, m := range rules.Multipliers {
+		if !strings.EqualFold(m.RepoOwner, msg.RepoOwner) {
+			continue
+		}
+		switch {
+		case m.RepoName == "" && !matchedRepo:
+			factor = m.Factor
+		case m.RepoName != "" && strings.EqualFold(m.RepoName, msg.RepoName):
+			factor = m.Factor
+			matchedRepo = true
+		}
+	}
+	return
+}
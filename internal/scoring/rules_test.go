@@ -0,0 +1,308 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   types.ScoringRules
+		wantErr string
+	}{
+		{name: "default", rules: DefaultRules()},
+		{name: "all rules", rules: types.ScoringRules{
+			UnclassifiedPoints: 2,
+			Multipliers:        []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: 2}, {RepoOwner: "myOrg", RepoName: "myRepo", Factor: 0}},
+			PullRequestCap:     10,
+			DailyCap:           50,
+			FirstFixBonus:      3,
+			ZeroedCategories:   []string{"ShellCheck"},
+		}},
+		{name: "negative unclassified points", rules: types.ScoringRules{UnclassifiedPoints: -1},
+			wantErr: "unclassifiedPoints must not be negative: -1"},
+		{name: "negative pull request cap", rules: types.ScoringRules{PullRequestCap: -1},
+			wantErr: "pullRequestCap must not be negative: -1"},
+		{name: "negative daily cap", rules: types.ScoringRules{DailyCap: -2},
+			wantErr: "dailyCap must not be negative: -2"},
+		{name: "negative first fix bonus", rules: types.ScoringRules{FirstFixBonus: -3},
+			wantErr: "firstFixBonus must not be negative: -3"},
+		{name: "multiplier without owner", rules: types.ScoringRules{Multipliers: []types.ScoringMultiplier{{RepoName: "myRepo", Factor: 2}}},
+			wantErr: "multiplier is missing repositoryOwner: {RepoOwner: RepoName:myRepo Factor:2}"},
+		{name: "negative multiplier", rules: types.ScoringRules{Multipliers: []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: -1}}},
+			wantErr: "multiplier factor must not be negative: {RepoOwner:myOrg RepoName: Factor:-1}"},
+		{name: "fractional points", rules: types.ScoringRules{UnclassifiedPoints: 0.5, PullRequestCap: 12.25, DailyCap: 40.1, FirstFixBonus: 0.01}},
+		{name: "unclassified points with too many decimals", rules: types.ScoringRules{UnclassifiedPoints: 0.125},
+			wantErr: "unclassifiedPoints must not have more than 2 decimal places: 0.125"},
+		{name: "pull request cap with too many decimals", rules: types.ScoringRules{PullRequestCap: 1.001},
+			wantErr: "pullRequestCap must not have more than 2 decimal places: 1.001"},
+		{name: "daily cap out of range", rules: types.ScoringRules{DailyCap: 1e10},
+			wantErr: "dailyCap is out of range: 1e+10"},
+		{name: "first fix bonus with too many decimals", rules: types.ScoringRules{FirstFixBonus: 0.333},
+			wantErr: "firstFixBonus must not have more than 2 decimal places: 0.333"},
+		{name: "empty zeroed category", rules: types.ScoringRules{ZeroedCategories: []string{""}},
+			wantErr: "zeroedCategories must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.rules)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCategories(t *testing.T) {
+	tests := []struct {
+		name       string
+		bugCounts  map[string]interface{}
+		wantCounts map[string]float64
+		wantErr    string
+	}{
+		{name: "nil", wantCounts: map[string]float64{}},
+		{name: "flat", bugCounts: map[string]interface{}{"G104": float64(1), "ShellCheck": float64(2)},
+			wantCounts: map[string]float64{"G104": 1, "ShellCheck": 2}},
+		{name: "nested", bugCounts: map[string]interface{}{
+			"G104": float64(1),
+			"opt":  map[string]interface{}{"semgrep": map[string]interface{}{"node_password": float64(2)}},
+		}, wantCounts: map[string]float64{"G104": 1, "opt.semgrep.node_password": 2}},
+		{name: "same category under two parents", bugCounts: map[string]interface{}{
+			"a": map[string]interface{}{"G104": float64(1)},
+			"b": map[string]interface{}{"G104": float64(2)},
+		}, wantCounts: map[string]float64{"a.G104": 1, "b.G104": 2}},
+		{name: "unexpected type", bugCounts: map[string]interface{}{"G104": float64(1), "bogus": "value"},
+			wantCounts: map[string]float64{"G104": 1}, wantErr: "bugType: bogus has unexpected bugValue type: value"},
+		{name: "nested unexpected type", bugCounts: map[string]interface{}{"opt": map[string]interface{}{"bogus": true}},
+			wantCounts: map[string]float64{}, wantErr: "bugType: opt.bogus has unexpected bugValue type: true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts, err := Categories(tt.bugCounts)
+			assert.Equal(t, tt.wantCounts, counts)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoundPoints(t *testing.T) {
+	assert.Equal(t, float64(3), RoundPoints(3))
+	assert.Equal(t, 0.5, RoundPoints(0.5))
+	assert.Equal(t, 0.33, RoundPoints(1.0/3))
+	assert.Equal(t, 0.67, RoundPoints(2.0/3))
+	assert.Equal(t, 0.3, RoundPoints(0.1+0.2))
+	assert.Equal(t, -1.25, RoundPoints(-1.249))
+}
+
+func TestValidatePoints(t *testing.T) {
+	assert.NoError(t, ValidatePoints("points", 0))
+	assert.NoError(t, ValidatePoints("points", 0.5))
+	assert.NoError(t, ValidatePoints("points", -2.25))
+	assert.NoError(t, ValidatePoints("points", 0.1+0.2))
+	assert.NoError(t, ValidatePoints("points", 9999999999.99))
+	assert.EqualError(t, ValidatePoints("points", 0.125), "points must not have more than 2 decimal places: 0.125")
+	assert.EqualError(t, ValidatePoints("points", 1e10), "points is out of range: 1e+10")
+	assert.EqualError(t, ValidatePoints("points", math.NaN()), "points is out of range: NaN")
+}
+
+func TestCandidates(t *testing.T) {
+	assert.Equal(t, []string{"G104"}, candidates("G104"))
+	assert.Equal(t, []string{"ErrorProne.NullAway", "NullAway", "ErrorProne"}, candidates("ErrorProne.NullAway"))
+	assert.Equal(t, []string{"opt.semgrep.node_password", "node_password", "opt.semgrep", "opt"},
+		candidates("opt.semgrep.node_password"))
+}
+
+func TestValidCategoryPath(t *testing.T) {
+	assert.True(t, ValidCategoryPath("G104"))
+	assert.True(t, ValidCategoryPath("opt.semgrep.node_password"))
+	assert.False(t, ValidCategoryPath(""))
+	assert.False(t, ValidCategoryPath(".NullAway"))
+	assert.False(t, ValidCategoryPath("ErrorProne."))
+	assert.False(t, ValidCategoryPath("ErrorProne..NullAway"))
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name         string
+		categoryPath string
+		wantValue    float64
+		wantResolved string
+	}{
+		{name: "flat category", categoryPath: "G104", wantValue: 2, wantResolved: "G104"},
+		{name: "exact path", categoryPath: "ErrorProne.NullAway", wantValue: 7, wantResolved: "ErrorProne.NullAway"},
+		{name: "exact path beats leaf", categoryPath: "gosec.G104", wantValue: 4, wantResolved: "gosec.G104"},
+		{name: "leaf", categoryPath: "opt.semgrep.node_password", wantValue: 5, wantResolved: "node_password"},
+		{name: "nearest parent", categoryPath: "ErrorProne.MissingOverride", wantValue: 6, wantResolved: "ErrorProne"},
+		{name: "nearest of many parents", categoryPath: "opt.semgrep.node_username", wantValue: 1, wantResolved: "opt.semgrep"},
+		{name: "unknown", categoryPath: "unknown.category", wantValue: 0, wantResolved: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, resolvedCategory := Resolve(tt.categoryPath, testPointValue)
+			assert.Equal(t, tt.wantValue, value)
+			assert.Equal(t, tt.wantResolved, resolvedCategory)
+		})
+	}
+}
+
+// testPointValues are the bug category point values of the Score tests
+var testPointValues = map[string]float64{
+	"G104": 2, "gosec.G104": 4, "ShellCheck": 3, "node_password": 5, "opt.semgrep": 1, "ErrorProne": 6, "ErrorProne.NullAway": 7, "half": 0.5,
+}
+
+func testPointValue(category string) (value float64, found bool) {
+	value, found = testPointValues[category]
+	return
+}
+
+func TestScore(t *testing.T) {
+	msg := func(owner, repo string, totalFixed int, bugCounts map[string]interface{}) *types.ScoringMessage {
+		return &types.ScoringMessage{RepoOwner: owner, RepoName: repo, TotalFixed: totalFixed, BugCounts: bugCounts}
+	}
+	defaults := DefaultRules()
+	tests := []struct {
+		name         string
+		rules        types.ScoringRules
+		msg          *types.ScoringMessage
+		history      types.ScoringHistory
+		wantPoints   float64
+		wantResolved map[string]string
+		wantErr      string
+	}{
+		{name: "nothing fixed", rules: defaults, msg: msg("myOrg", "myRepo", 0, nil), wantPoints: 0},
+		{name: "category point values", rules: defaults,
+			msg:        msg("myOrg", "myRepo", 3, map[string]interface{}{"G104": float64(1), "ShellCheck": float64(2)}),
+			wantPoints: 8, wantResolved: map[string]string{"G104": "G104", "ShellCheck": "ShellCheck"}},
+		{name: "nested category", rules: defaults,
+			msg:        msg("myOrg", "myRepo", 1, map[string]interface{}{"opt": map[string]interface{}{"semgrep": map[string]interface{}{"node_password": float64(1)}}}),
+			wantPoints: 5, wantResolved: map[string]string{"opt.semgrep.node_password": "node_password"}},
+		{name: "nested category falls back to parent", rules: defaults,
+			msg: msg("myOrg", "myRepo", 2, map[string]interface{}{"ErrorProne": map[string]interface{}{
+				"NullAway": float64(1), "MissingOverride": float64(1)}}),
+			wantPoints: 13, wantResolved: map[string]string{"ErrorProne.NullAway": "ErrorProne.NullAway", "ErrorProne.MissingOverride": "ErrorProne"}},
+		{name: "category without point value is unclassified", rules: types.ScoringRules{UnclassifiedPoints: 3},
+			msg: msg("myOrg", "myRepo", 1, map[string]interface{}{"unknown": float64(1)}), wantPoints: 3,
+			wantResolved: map[string]string{"unknown": ""}},
+		{name: "unclassified default point", rules: defaults,
+			msg: msg("myOrg", "myRepo", 3, map[string]interface{}{"G104": float64(1)}), wantPoints: 4},
+		{name: "unclassified points", rules: types.ScoringRules{UnclassifiedPoints: 3},
+			msg: msg("myOrg", "myRepo", 2, nil), wantPoints: 6},
+		{name: "unclassified worth nothing", rules: types.ScoringRules{},
+			msg: msg("myOrg", "myRepo", 2, nil), wantPoints: 0},
+		{name: "unexpected bug count type still scores the rest", rules: defaults,
+			msg:        msg("myOrg", "myRepo", 0, map[string]interface{}{"G104": float64(2), "bogus": "value"}),
+			wantPoints: 4, wantErr: "bugType: bogus has unexpected bugValue type: value"},
+		{name: "zeroed category", rules: types.ScoringRules{UnclassifiedPoints: 1, ZeroedCategories: []string{"ShellCheck"}},
+			msg:        msg("myOrg", "myRepo", 3, map[string]interface{}{"G104": float64(1), "ShellCheck": float64(2)}),
+			wantPoints: 2, wantResolved: map[string]string{"G104": "G104", "ShellCheck": ""}},
+		{name: "zeroed parent category", rules: types.ScoringRules{ZeroedCategories: []string{"ErrorProne"}},
+			msg: msg("myOrg", "myRepo", 2, map[string]interface{}{"ErrorProne": map[string]interface{}{
+				"NullAway": float64(1), "MissingOverride": float64(1)}}),
+			wantPoints: 0, wantResolved: map[string]string{"ErrorProne.NullAway": "", "ErrorProne.MissingOverride": ""}},
+		{name: "zeroed leaf category", rules: types.ScoringRules{ZeroedCategories: []string{"node_password"}},
+			msg:        msg("myOrg", "myRepo", 1, map[string]interface{}{"opt": map[string]interface{}{"semgrep": map[string]interface{}{"node_password": float64(1)}}}),
+			wantPoints: 0, wantResolved: map[string]string{"opt.semgrep.node_password": ""}},
+		{name: "organization multiplier", rules: types.ScoringRules{
+			Multipliers: []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: 2}},
+		}, msg: msg("MyOrg", "myRepo", 1, map[string]interface{}{"ShellCheck": float64(1)}), wantPoints: 6},
+		{name: "repository multiplier beats organization multiplier", rules: types.ScoringRules{
+			Multipliers: []types.ScoringMultiplier{
+				{RepoOwner: "myOrg", RepoName: "myRepo", Factor: 3},
+				{RepoOwner: "myOrg", Factor: 2},
+			},
+		}, msg: msg("myOrg", "myRepo", 1, map[string]interface{}{"ShellCheck": float64(1)}), wantPoints: 9},
+		{name: "other repository gets organization multiplier", rules: types.ScoringRules{
+			Multipliers: []types.ScoringMultiplier{
+				{RepoOwner: "myOrg", RepoName: "myRepo", Factor: 3},
+				{RepoOwner: "myOrg", Factor: 2},
+			},
+		}, msg: msg("myOrg", "otherRepo", 1, map[string]interface{}{"ShellCheck": float64(1)}), wantPoints: 6},
+		{name: "other organization is not multiplied", rules: types.ScoringRules{
+			Multipliers: []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: 2}},
+		}, msg: msg("otherOrg", "myRepo", 1, map[string]interface{}{"ShellCheck": float64(1)}), wantPoints: 3},
+		{name: "multiplied points keep decimals", rules: types.ScoringRules{
+			Multipliers: []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: 1.5}},
+		}, msg: msg("myOrg", "myRepo", 1, map[string]interface{}{"ShellCheck": float64(1)}), wantPoints: 4.5},
+		{name: "multiplied points are rounded to hundredths", rules: types.ScoringRules{
+			Multipliers: []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: 1.111}},
+		}, msg: msg("myOrg", "myRepo", 1, map[string]interface{}{"ShellCheck": float64(1)}), wantPoints: 3.33},
+		{name: "fractional point values", rules: types.ScoringRules{UnclassifiedPoints: 0.25},
+			msg:        msg("myOrg", "myRepo", 4, map[string]interface{}{"half": float64(3)}),
+			wantPoints: 1.75},
+		{name: "first fix bonus for each new category", rules: types.ScoringRules{FirstFixBonus: 10},
+			msg:        msg("myOrg", "myRepo", 3, map[string]interface{}{"G104": float64(1), "ShellCheck": float64(2)}),
+			wantPoints: 28},
+		{name: "no first fix bonus for categories fixed before", rules: types.ScoringRules{FirstFixBonus: 10},
+			msg:        msg("myOrg", "myRepo", 3, map[string]interface{}{"G104": float64(1), "ShellCheck": float64(2)}),
+			history:    types.ScoringHistory{BugCounts: []map[string]interface{}{{"G104": float64(1)}}},
+			wantPoints: 18},
+		{name: "first fix bonus for category fixed before under another parent", rules: types.ScoringRules{FirstFixBonus: 10},
+			msg:        msg("myOrg", "myRepo", 1, map[string]interface{}{"G104": float64(1)}),
+			history:    types.ScoringHistory{BugCounts: []map[string]interface{}{{"opt": map[string]interface{}{"G104": float64(1)}}}},
+			wantPoints: 12},
+		{name: "no first fix bonus for zeroed category", rules: types.ScoringRules{FirstFixBonus: 10, ZeroedCategories: []string{"G104"}},
+			msg: msg("myOrg", "myRepo", 1, map[string]interface{}{"G104": float64(1)}), wantPoints: 0},
+		{name: "first fix bonus is not multiplied", rules: types.ScoringRules{
+			FirstFixBonus: 10,
+			Multipliers:   []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: 2}},
+		}, msg: msg("myOrg", "myRepo", 1, map[string]interface{}{"G104": float64(1)}), wantPoints: 14},
+		{name: "pull request cap", rules: types.ScoringRules{PullRequestCap: 5},
+			msg: msg("myOrg", "myRepo", 2, map[string]interface{}{"ShellCheck": float64(2)}), wantPoints: 5},
+		{name: "pull request cap includes bonus", rules: types.ScoringRules{PullRequestCap: 5, FirstFixBonus: 10},
+			msg: msg("myOrg", "myRepo", 1, map[string]interface{}{"G104": float64(1)}), wantPoints: 5},
+		{name: "under daily cap", rules: types.ScoringRules{DailyCap: 20},
+			msg:     msg("myOrg", "myRepo", 2, map[string]interface{}{"ShellCheck": float64(2)}),
+			history: types.ScoringHistory{PointsToday: 10}, wantPoints: 6},
+		{name: "daily cap limits to points left today", rules: types.ScoringRules{DailyCap: 20},
+			msg:     msg("myOrg", "myRepo", 2, map[string]interface{}{"ShellCheck": float64(2)}),
+			history: types.ScoringHistory{PointsToday: 16}, wantPoints: 4},
+		{name: "daily cap used up", rules: types.ScoringRules{DailyCap: 20},
+			msg:     msg("myOrg", "myRepo", 2, map[string]interface{}{"ShellCheck": float64(2)}),
+			history: types.ScoringHistory{PointsToday: 25}, wantPoints: 0},
+		{name: "pull request cap then daily cap", rules: types.ScoringRules{PullRequestCap: 5, DailyCap: 20},
+			msg:     msg("myOrg", "myRepo", 3, map[string]interface{}{"ShellCheck": float64(3)}),
+			history: types.ScoringHistory{PointsToday: 17}, wantPoints: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, resolved, err := Score(&tt.rules, tt.msg, testPointValue, &tt.history)
+			assert.Equal(t, tt.wantPoints, points)
+			if tt.wantResolved != nil {
+				assert.Equal(t, tt.wantResolved, resolved)
+			}
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
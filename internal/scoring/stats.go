@@ -0,0 +1,126 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sort"
+	"time"
+)
+
+// statsTopCount is the number of repositories and organizations listed in campaign stats.
+const statsTopCount = 10
+
+const statsDayLayout = "2006-01-02"
+
+// CampaignStats sums up the pull requests scored in a campaign. The nominal points of a bug category are the bugs fixed
+// times what the rules give each of them at current point values (zero when zeroed, the point value of the bug
+// category resolving it, or else the unclassified points), before any multipliers, caps or bonuses. days are the
+// points scored each day from the ledger of the campaign, the first first; the days when nothing was scored between
+// the first and the last of them are added.
+func CampaignStats(campaignName string, rules *types.ScoringRules, pullRequests []types.ScoredPullRequest, days []types.DayScore,
+	pointValue func(category string) (value float64, found bool)) (stats types.CampaignStats) {
+
+	stats = types.CampaignStats{
+		CampaignName:  campaignName,
+		PullRequests:  len(pullRequests),
+		Categories:    []types.CategoryPoints{},
+		Repositories:  repositoryScores(pullRequests),
+		Organizations: []types.OrganizationScore{},
+		Days:          []types.DayScore{},
+	}
+
+	zeroed := zeroedCategories(rules)
+	categories := map[string]int{}
+	organizations := map[string]int{}
+	for _, pullRequest := range pullRequests {
+		stats.Points = RoundPoints(stats.Points + pullRequest.Points)
+
+		// a bug count of an unexpected type scored nothing, so is left out here too
+		counts, _ := Categories(pullRequest.BugCounts)
+		for category, fixed := range counts {
+			stats.BugsFixed += fixed
+			i, found := categories[category]
+			if !found {
+				i = len(stats.Categories)
+				categories[category] = i
+				stats.Categories = append(stats.Categories, types.CategoryPoints{Category: category})
+			}
+			value := float64(0)
+			if !isZeroed(zeroed, category) {
+				var resolvedCategory string
+				if value, resolvedCategory = Resolve(category, pointValue); resolvedCategory == "" {
+					value = rules.UnclassifiedPoints
+				}
+			}
+			stats.Categories[i].Fixed += fixed
+			stats.Categories[i].NominalPoints = RoundPoints(stats.Categories[i].NominalPoints + fixed*value)
+		}
+
+		i, found := organizations[pullRequest.RepoOwner]
+		if !found {
+			i = len(stats.Organizations)
+			organizations[pullRequest.RepoOwner] = i
+			stats.Organizations = append(stats.Organizations, types.OrganizationScore{Organization: pullRequest.RepoOwner})
+		}
+		stats.Organizations[i].PullRequests++
+		stats.Organizations[i].Points = RoundPoints(stats.Organizations[i].Points + pullRequest.Points)
+	}
+
+	var nextDay time.Time
+	for _, dayScore := range days {
+		// a day that is not formatted as expected is listed as it is, with no days added around it
+		day, parseErr := time.Parse(statsDayLayout, dayScore.Day)
+		for ; parseErr == nil && !nextDay.IsZero() && day.After(nextDay); nextDay = nextDay.AddDate(0, 0, 1) {
+			stats.Days = append(stats.Days, types.DayScore{Day: nextDay.Format(statsDayLayout)})
+		}
+		dayScore.Points = RoundPoints(dayScore.Points)
+		stats.Days = append(stats.Days, dayScore)
+		nextDay = time.Time{}
+		if parseErr == nil {
+			nextDay = day.AddDate(0, 0, 1)
+		}
+	}
+
+	sort.SliceStable(stats.Categories, func(i, j int) bool {
+		left, right := stats.Categories[i], stats.Categories[j]
+		if left.NominalPoints != right.NominalPoints {
+			return left.NominalPoints > right.NominalPoints
+		}
+		if left.Fixed != right.Fixed {
+			return left.Fixed > right.Fixed
+		}
+		return left.Category < right.Category
+	})
+	sort.SliceStable(stats.Organizations, func(i, j int) bool {
+		left, right := stats.Organizations[i], stats.Organizations[j]
+		if left.Points != right.Points {
+			return left.Points > right.Points
+		}
+		return left.Organization < right.Organization
+	})
+	if len(stats.Repositories) > statsTopCount {
+		stats.Repositories = stats.Repositories[:statsTopCount]
+	}
+	if len(stats.Organizations) > statsTopCount {
+		stats.Organizations = stats.Organizations[:statsTopCount]
+	}
+	return
+}
@@ -0,0 +1,107 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCampaignStatsNoPullRequests(t *testing.T) {
+	assert.Equal(t, types.CampaignStats{
+		CampaignName:  "myCampaign",
+		Categories:    []types.CategoryPoints{},
+		Repositories:  []types.RepositoryScore{},
+		Organizations: []types.OrganizationScore{},
+		Days:          []types.DayScore{},
+	}, CampaignStats("myCampaign", &types.ScoringRules{}, nil, nil, testPointValue))
+}
+
+func TestCampaignStats(t *testing.T) {
+	pullRequests := []types.ScoredPullRequest{
+		{RepoOwner: "owner", RepoName: "small", PullRequest: 1, Points: 0.5,
+			BugCounts: map[string]interface{}{"opt": map[string]interface{}{"semgrep": float64(1)}}},
+		{RepoOwner: "owner", RepoName: "big", PullRequest: 2, Points: 6,
+			BugCounts: map[string]interface{}{"opt": map[string]interface{}{"semgrep": float64(2)}, "ShellCheck": float64(1)}},
+		{RepoOwner: "other", RepoName: "big", PullRequest: 3, Points: 2,
+			BugCounts: map[string]interface{}{"unpriced": float64(2)}},
+		{RepoOwner: "other", RepoName: "old", PullRequest: 4, Points: 1},
+	}
+	days := []types.DayScore{
+		{Day: "2022-05-16", PullRequests: 2, Points: 2.5},
+		{Day: "2022-05-19", PullRequests: 2, Points: 7.004},
+		{Day: "2022-05-20", PullRequests: 1, Points: -1},
+	}
+	rules := &types.ScoringRules{UnclassifiedPoints: 1.5, ZeroedCategories: []string{"ShellCheck"}}
+	stats := CampaignStats("myCampaign", rules, pullRequests, days, testPointValue)
+	assert.Equal(t, "myCampaign", stats.CampaignName)
+	assert.Equal(t, 4, stats.PullRequests)
+	assert.Equal(t, float64(6), stats.BugsFixed)
+	assert.Equal(t, 9.5, stats.Points)
+	assert.Equal(t, []types.CategoryPoints{
+		{Category: "opt.semgrep", Fixed: 3, NominalPoints: 3},
+		{Category: "unpriced", Fixed: 2, NominalPoints: 3},
+		{Category: "ShellCheck", Fixed: 1, NominalPoints: 0},
+	}, stats.Categories)
+	assert.Equal(t, []types.RepositoryScore{
+		{RepoOwner: "owner", RepoName: "big", PullRequests: 1, Points: 6},
+		{RepoOwner: "other", RepoName: "big", PullRequests: 1, Points: 2},
+		{RepoOwner: "other", RepoName: "old", PullRequests: 1, Points: 1},
+		{RepoOwner: "owner", RepoName: "small", PullRequests: 1, Points: 0.5},
+	}, stats.Repositories)
+	assert.Equal(t, []types.OrganizationScore{
+		{Organization: "owner", PullRequests: 2, Points: 6.5},
+		{Organization: "other", PullRequests: 2, Points: 3},
+	}, stats.Organizations)
+	assert.Equal(t, []types.DayScore{
+		{Day: "2022-05-16", PullRequests: 2, Points: 2.5},
+		{Day: "2022-05-17"},
+		{Day: "2022-05-18"},
+		{Day: "2022-05-19", PullRequests: 2, Points: 7},
+		{Day: "2022-05-20", PullRequests: 1, Points: -1},
+	}, stats.Days)
+}
+
+func TestCampaignStatsUnexpectedDay(t *testing.T) {
+	days := []types.DayScore{
+		{Day: "2022-05-16", PullRequests: 1, Points: 1},
+		{Day: "someday", PullRequests: 1, Points: 2},
+		{Day: "2022-05-18", PullRequests: 1, Points: 3},
+	}
+	stats := CampaignStats("myCampaign", &types.ScoringRules{}, nil, days, testPointValue)
+	assert.Equal(t, days, stats.Days)
+}
+
+func TestCampaignStatsTopRepositoriesAndOrganizations(t *testing.T) {
+	var pullRequests []types.ScoredPullRequest
+	for i := 1; i <= statsTopCount+2; i++ {
+		pullRequests = append(pullRequests, types.ScoredPullRequest{
+			RepoOwner: fmt.Sprintf("owner%d", i), RepoName: "repo", PullRequest: i, Points: float64(i)})
+	}
+
+	stats := CampaignStats("myCampaign", &types.ScoringRules{}, pullRequests, nil, testPointValue)
+	assert.Equal(t, statsTopCount+2, stats.PullRequests)
+	assert.Equal(t, statsTopCount, len(stats.Repositories))
+	assert.Equal(t, fmt.Sprintf("owner%d", statsTopCount+2), stats.Repositories[0].RepoOwner)
+	assert.Equal(t, statsTopCount, len(stats.Organizations))
+	assert.Equal(t, "owner3", stats.Organizations[statsTopCount-1].Organization)
+}
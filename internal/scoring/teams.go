@@ -0,0 +1,97 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sort"
+)
+
+// validateTeamScore reports a team score rule that can not be applied.
+func validateTeamScore(rules *types.ScoringRules) (err error) {
+	switch rules.TeamScore {
+	case "", types.TeamScoreSum, types.TeamScoreAverage:
+		if rules.TeamTopMembers != 0 {
+			return fmt.Errorf("teamTopMembers only applies to teamScore %q: %d", types.TeamScoreTop, rules.TeamTopMembers)
+		}
+	case types.TeamScoreTop:
+		if rules.TeamTopMembers < 1 {
+			return fmt.Errorf("teamTopMembers must be at least 1: %d", rules.TeamTopMembers)
+		}
+	default:
+		return fmt.Errorf("teamScore must be one of %q, %q or %q: %q",
+			types.TeamScoreSum, types.TeamScoreAverage, types.TeamScoreTop, rules.TeamScore)
+	}
+	return
+}
+
+// TeamScore adds up the scores of the members of a team by the team score rule: the sum of all member scores (the
+// default), their average, or the sum of the scores of the TeamTopMembers best members. A team without members scores
+// nothing.
+func TeamScore(rules *types.ScoringRules, memberScores []float64) (score float64) {
+	if len(memberScores) == 0 {
+		return
+	}
+
+	scores := memberScores
+	if rules.TeamScore == types.TeamScoreTop && rules.TeamTopMembers < len(memberScores) {
+		scores = append([]float64(nil), memberScores...)
+		sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+		scores = scores[:rules.TeamTopMembers]
+	}
+	for _, memberScore := range scores {
+		score += memberScore
+	}
+	if rules.TeamScore == types.TeamScoreAverage {
+		score /= float64(len(scores))
+	}
+	return RoundPoints(score)
+}
+
+// RankTeams scores each team by the team score rule, and orders the teams from the highest score down, then by name.
+// Teams with equal scores share a rank, and the next lower score takes the next rank, e.g. 1, 1, 2.
+func RankTeams(rules *types.ScoringRules, teams []types.TeamMemberScores) (ranked []types.TeamStruct) {
+	ranked = make([]types.TeamStruct, 0, len(teams))
+	for _, team := range teams {
+		rankedTeam := team.Team
+		rankedTeam.MemberCount = len(team.MemberScores)
+		rankedTeam.Score = TeamScore(rules, team.MemberScores)
+		ranked = append(ranked, rankedTeam)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	for i := range ranked {
+		switch {
+		case i == 0:
+			ranked[i].Rank = 1
+		case ranked[i].Score == ranked[i-1].Score:
+			ranked[i].Rank = ranked[i-1].Rank
+		default:
+			ranked[i].Rank = ranked[i-1].Rank + 1
+		}
+	}
+	return
+}
@@ -0,0 +1,115 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scoring
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateTeamScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   types.ScoringRules
+		wantErr string
+	}{
+		{name: "default", rules: types.ScoringRules{}},
+		{name: "sum", rules: types.ScoringRules{TeamScore: types.TeamScoreSum}},
+		{name: "average", rules: types.ScoringRules{TeamScore: types.TeamScoreAverage}},
+		{name: "top", rules: types.ScoringRules{TeamScore: types.TeamScoreTop, TeamTopMembers: 3}},
+		{name: "top without members", rules: types.ScoringRules{TeamScore: types.TeamScoreTop},
+			wantErr: "teamTopMembers must be at least 1: 0"},
+		{name: "top members without top", rules: types.ScoringRules{TeamScore: types.TeamScoreAverage, TeamTopMembers: 3},
+			wantErr: `teamTopMembers only applies to teamScore "top": 3`},
+		{name: "unknown", rules: types.ScoringRules{TeamScore: "median"},
+			wantErr: `teamScore must be one of "sum", "average" or "top": "median"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.rules)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTeamScore(t *testing.T) {
+	memberScores := []float64{3, 10, 0.5, 7}
+	tests := []struct {
+		name         string
+		rules        types.ScoringRules
+		memberScores []float64
+		wantScore    float64
+	}{
+		{name: "no members", rules: types.ScoringRules{TeamScore: types.TeamScoreAverage}, wantScore: 0},
+		{name: "default sum", memberScores: memberScores, wantScore: 20.5},
+		{name: "sum", rules: types.ScoringRules{TeamScore: types.TeamScoreSum}, memberScores: memberScores, wantScore: 20.5},
+		{name: "average", rules: types.ScoringRules{TeamScore: types.TeamScoreAverage}, memberScores: memberScores, wantScore: 5.13},
+		{name: "top", rules: types.ScoringRules{TeamScore: types.TeamScoreTop, TeamTopMembers: 2},
+			memberScores: memberScores, wantScore: 17},
+		{name: "top of fewer members", rules: types.ScoringRules{TeamScore: types.TeamScoreTop, TeamTopMembers: 5},
+			memberScores: memberScores, wantScore: 20.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantScore, TeamScore(&tt.rules, tt.memberScores))
+		})
+	}
+	// member scores are left in order
+	assert.Equal(t, []float64{3, 10, 0.5, 7}, memberScores)
+}
+
+func TestRankTeams(t *testing.T) {
+	team := func(name string, memberScores ...float64) types.TeamMemberScores {
+		return types.TeamMemberScores{Team: types.TeamStruct{Id: name + "Id", CampaignName: "myCampaign", Name: name}, MemberScores: memberScores}
+	}
+	ranked := RankTeams(&types.ScoringRules{}, []types.TeamMemberScores{
+		team("empty"),
+		team("bravo", 5, 2),
+		team("alpha", 4, 3),
+		team("charlie", 10),
+	})
+	assert.Equal(t, []types.TeamStruct{
+		{Id: "charlieId", CampaignName: "myCampaign", Name: "charlie", MemberCount: 1, Score: 10, Rank: 1},
+		{Id: "alphaId", CampaignName: "myCampaign", Name: "alpha", MemberCount: 2, Score: 7, Rank: 2},
+		{Id: "bravoId", CampaignName: "myCampaign", Name: "bravo", MemberCount: 2, Score: 7, Rank: 2},
+		{Id: "emptyId", CampaignName: "myCampaign", Name: "empty", MemberCount: 0, Score: 0, Rank: 3},
+	}, ranked)
+}
+
+func TestRankTeamsByAverage(t *testing.T) {
+	ranked := RankTeams(&types.ScoringRules{TeamScore: types.TeamScoreAverage}, []types.TeamMemberScores{
+		{Team: types.TeamStruct{Name: "many"}, MemberScores: []float64{4, 4, 4}},
+		{Team: types.TeamStruct{Name: "few"}, MemberScores: []float64{5}},
+	})
+	assert.Equal(t, "few", ranked[0].Name)
+	assert.Equal(t, float64(5), ranked[0].Score)
+	assert.Equal(t, "many", ranked[1].Name)
+	assert.Equal(t, float64(4), ranked[1].Score)
+	assert.Equal(t, 3, ranked[1].MemberCount)
+}
+
+func TestRankTeamsNone(t *testing.T) {
+	assert.Equal(t, []types.TeamStruct{}, RankTeams(&types.ScoringRules{}, nil))
+}
@@ -54,8 +54,12 @@ type ScoringMessage struct {
 	TotalFixed  int                    `json:"fixed-bugs"`
 	BugCounts   map[string]interface{} `json:"fixed-bug-types"`
 	PullRequest int                    `json:"pullRequestId"`
+	// SourceId identifies where the message came from, e.g. the Datadog log id or the GitHub webhook delivery id.
+	SourceId string `json:"sourceId,omitempty"`
 }
 
+// ParticipantStruct is a participant of a campaign, as seen by admins. Public endpoints must only return its
+// PublicParticipant. Rank is only filled in for participant lists, where participants with equal scores share a rank.
 type ParticipantStruct struct {
 	ID           string    `json:"guid"`
 	CampaignName string    `json:"campaignName"`
@@ -63,22 +67,263 @@ type ParticipantStruct struct {
 	LoginName    string    `json:"loginName"`
 	Email        string    `json:"email"`
 	DisplayName  string    `json:"displayName"`
-	Score        int       `json:"score"`
+	Score        float64   `json:"score"`
 	TeamName     string    `json:"teamName"`
 	JoinedAt     time.Time `json:"joinedAt"`
+	Rank         int       `json:"rank,omitempty"`
+	// Private participants are shown on public pages by display name only
+	Private bool `json:"private"`
 }
 
+// PublicParticipant is a participant of a campaign, as seen by anyone. It has no email or id, and a private
+// participant has no source control provider or login name either.
+type PublicParticipant struct {
+	CampaignName string  `json:"campaignName"`
+	ScpName      string  `json:"scpName,omitempty"`
+	LoginName    string  `json:"loginName,omitempty"`
+	DisplayName  string  `json:"displayName"`
+	Score        float64 `json:"score"`
+	TeamName     string  `json:"teamName"`
+	Rank         int     `json:"rank,omitempty"`
+}
+
+// Public is what anyone may see of the participant.
+func (p *ParticipantStruct) Public() (public PublicParticipant) {
+	public = PublicParticipant{
+		CampaignName: p.CampaignName,
+		DisplayName:  p.DisplayName,
+		Score:        p.Score,
+		TeamName:     p.TeamName,
+		Rank:         p.Rank,
+	}
+	if !p.Private {
+		public.ScpName = p.ScpName
+		public.LoginName = p.LoginName
+	}
+	return
+}
+
+// ParticipantFilter selects a page of the participants of a campaign, optionally only those in a team or of a source
+// control provider.
+type ParticipantFilter struct {
+	TeamName string
+	ScpName  string
+	Limit    int
+	Offset   int
+}
+
+// TeamStruct is a team of participants. MemberCount, Score and Rank are only filled in for the team leaderboard, where
+// teams with equal scores share a rank.
 type TeamStruct struct {
-	Id           string `json:"guid"`
-	CampaignName string `json:"campaignName"`
-	Name         string `json:"name"`
+	Id           string  `json:"guid"`
+	CampaignName string  `json:"campaignName"`
+	Name         string  `json:"name"`
+	MemberCount  int     `json:"memberCount"`
+	Score        float64 `json:"score"`
+	Rank         int     `json:"rank"`
+}
+
+// PublicTeam is a team on the team leaderboard, as seen by anyone. It has no id.
+type PublicTeam struct {
+	CampaignName string  `json:"campaignName"`
+	Name         string  `json:"name"`
+	MemberCount  int     `json:"memberCount"`
+	Score        float64 `json:"score"`
+	Rank         int     `json:"rank"`
+}
+
+// Public is what anyone may see of the team.
+func (t *TeamStruct) Public() PublicTeam {
+	return PublicTeam{
+		CampaignName: t.CampaignName,
+		Name:         t.Name,
+		MemberCount:  t.MemberCount,
+		Score:        t.Score,
+		Rank:         t.Rank,
+	}
+}
+
+// TeamMemberScores are the scores of the members of a team.
+type TeamMemberScores struct {
+	Team         TeamStruct
+	MemberScores []float64
 }
 
 type BugStruct struct {
-	Id         string `json:"guid"`
-	Campaign   string `json:"campaign"`
-	Category   string `json:"category"`
-	PointValue int    `json:"pointValue"`
+	Id         string  `json:"guid"`
+	Campaign   string  `json:"campaign"`
+	Category   string  `json:"category"`
+	PointValue float64 `json:"pointValue"`
+}
+
+// ScoringRules are how the fixed bugs of a campaign are scored. A cap of zero is not applied.
+type ScoringRules struct {
+	// UnclassifiedPoints are the points of each fixed bug without a bug category
+	UnclassifiedPoints float64             `json:"unclassifiedPoints"`
+	Multipliers        []ScoringMultiplier `json:"multipliers"`
+	PullRequestCap     float64             `json:"pullRequestCap"`
+	DailyCap           float64             `json:"dailyCap"`
+	// FirstFixBonus is added for each bug category fixed by a participant for the first time in the campaign
+	FirstFixBonus    float64  `json:"firstFixBonus"`
+	ZeroedCategories []string `json:"zeroedCategories"`
+	// TeamScore is how member scores add up to a team score, one of the TeamScore constants, "sum" when empty
+	TeamScore string `json:"teamScore,omitempty"`
+	// TeamTopMembers is the number of best members whose scores are summed, for TeamScoreTop
+	TeamTopMembers int `json:"teamTopMembers,omitempty"`
+}
+
+const (
+	TeamScoreSum     = "sum"
+	TeamScoreAverage = "average"
+	TeamScoreTop     = "top"
+)
+
+// ScoringMultiplier multiplies the points of a repository, or of every repository of an organization when RepoName is
+// empty.
+type ScoringMultiplier struct {
+	RepoOwner string  `json:"repositoryOwner"`
+	RepoName  string  `json:"repositoryName,omitempty"`
+	Factor    float64 `json:"factor"`
+}
+
+// ScoringHistory is what a participant scored in the other pull requests of a campaign, before a pull request is scored.
+type ScoringHistory struct {
+	// PointsToday are the points scored on the same day (UTC)
+	PointsToday float64
+	BugCounts   []map[string]interface{}
+}
+
+// LedgerEntry records a single scoring decision for a participant. Entries are never updated once written.
+// ResolvedCategories maps each category path of the bug counts to the bug category that priced it, "" when none did.
+type LedgerEntry struct {
+	Id                 string                 `json:"guid"`
+	CampaignName       string                 `json:"campaignName"`
+	ScpName            string                 `json:"scpName"`
+	LoginName          string                 `json:"loginName"`
+	SourceId           string                 `json:"sourceId"`
+	RepoOwner          string                 `json:"repositoryOwner"`
+	RepoName           string                 `json:"repositoryName"`
+	PullRequest        int                    `json:"pullRequestId"`
+	OldPoints          float64                `json:"oldPoints"`
+	NewPoints          float64                `json:"newPoints"`
+	BugCounts          map[string]interface{} `json:"fixed-bug-types"`
+	ScoringMessage     ScoringMessage         `json:"scoringMessage"`
+	ResolvedCategories map[string]string      `json:"resolvedCategories"`
+	CreatedOn          time.Time              `json:"createdOn"`
+}
+
+// ScoredPullRequest is a pull request a participant scored, as of its latest scoring. BugCounts and ScoredOn are empty
+// for pull requests scored before they were recorded.
+type ScoredPullRequest struct {
+	RepoOwner   string                 `json:"repositoryOwner"`
+	RepoName    string                 `json:"repositoryName"`
+	PullRequest int                    `json:"pullRequestId"`
+	Points      float64                `json:"points"`
+	BugCounts   map[string]interface{} `json:"fixed-bug-types"`
+	ScoredOn    *time.Time             `json:"scoredOn"`
+}
+
+// RepositoryScore is the total a participant scored in a repository.
+type RepositoryScore struct {
+	RepoOwner    string  `json:"repositoryOwner"`
+	RepoName     string  `json:"repositoryName"`
+	PullRequests int     `json:"pullRequests"`
+	Points       float64 `json:"points"`
+}
+
+// CategoryScore is the number of bugs of a category path a participant fixed, over all their pull requests.
+type CategoryScore struct {
+	Category     string  `json:"category"`
+	Fixed        float64 `json:"fixed"`
+	PullRequests int     `json:"pullRequests"`
+}
+
+// ScoreBreakdown explains a participant's score, by pull request, repository and bug category.
+type ScoreBreakdown struct {
+	CampaignName string              `json:"campaignName"`
+	ScpName      string              `json:"scpName"`
+	LoginName    string              `json:"loginName"`
+	Score        float64             `json:"score"`
+	PullRequests []ScoredPullRequest `json:"pullRequests"`
+	Repositories []RepositoryScore   `json:"repositories"`
+	Categories   []CategoryScore     `json:"categories"`
+}
+
+// OrganizationScore is the total scored in the repositories of an organization.
+type OrganizationScore struct {
+	Organization string  `json:"organization"`
+	PullRequests int     `json:"pullRequests"`
+	Points       float64 `json:"points"`
+}
+
+// CategoryPoints is the number of bugs of a category path fixed in a campaign, and their nominal points: what the
+// current point values and rules give each bug before multipliers, caps and bonuses. Nominal points are not the points
+// scored, so they need not add up to the campaign points, and change with the point values.
+type CategoryPoints struct {
+	Category      string  `json:"category"`
+	Fixed         float64 `json:"fixed"`
+	NominalPoints float64 `json:"nominalPoints"`
+}
+
+// DayScore is what was scored on a day (UTC), formatted as 2006-01-02.
+type DayScore struct {
+	Day          string  `json:"day"`
+	PullRequests int     `json:"pullRequests"`
+	Points       float64 `json:"points"`
+}
+
+// CampaignStats sums up what was scored in a campaign.
+type CampaignStats struct {
+	CampaignName           string              `json:"campaignName"`
+	PullRequests           int                 `json:"pullRequests"`
+	BugsFixed              float64             `json:"bugsFixed"`
+	Points                 float64             `json:"points"`
+	RegisteredParticipants int                 `json:"registeredParticipants"`
+	ActiveParticipants     int                 `json:"activeParticipants"`
+	Categories             []CategoryPoints    `json:"categories"`
+	Repositories           []RepositoryScore   `json:"topRepositories"`
+	Organizations          []OrganizationScore `json:"topOrganizations"`
+	Days                   []DayScore          `json:"days"`
+}
+
+// LeaderboardDelta is a change to the score of a participant on the leaderboard of a campaign. The delta of a private
+// participant has no source control provider or login name.
+type LeaderboardDelta struct {
+	CampaignName string  `json:"campaignName"`
+	ScpName      string  `json:"scpName,omitempty"`
+	LoginName    string  `json:"loginName,omitempty"`
+	OldScore     float64 `json:"oldScore"`
+	Score        float64 `json:"score"`
+}
+
+// LedgerSourceRecompute is the ledger source id of scoring events re-priced by a campaign recompute, and of the score
+// corrections it makes, which have no pull request and hold the old and new score of the participant.
+const LedgerSourceRecompute = "recompute"
+
+// ScoreChange is a participant whose score was changed by a campaign recompute.
+type ScoreChange struct {
+	ScpName   string  `json:"scpName"`
+	LoginName string  `json:"loginName"`
+	Private   bool    `json:"private"`
+	OldScore  float64 `json:"oldScore"`
+	NewScore  float64 `json:"newScore"`
+}
+
+type RecomputeReport struct {
+	CampaignName   string        `json:"campaignName"`
+	RepricedEvents int           `json:"repricedEvents"`
+	Changes        []ScoreChange `json:"changes"`
+}
+
+// DeadLetter is a scoring event that could not be read or scored. Payload holds the event as read from its source.
+type DeadLetter struct {
+	Id            string    `json:"guid"`
+	SourceId      string    `json:"sourceId"`
+	Payload       string    `json:"payload"`
+	Error         string    `json:"error"`
+	Attempts      int       `json:"attempts"`
+	CreatedOn     time.Time `json:"createdOn"`
+	LastAttemptOn time.Time `json:"lastAttemptOn"`
 }
 
 type Poll struct {
@@ -87,3 +332,60 @@ type Poll struct {
 	EnvBaseTime       time.Time `json:"envBaseTime"`
 	LastPollCompleted time.Time `json:"lastPollCompleted"`
 }
+
+type PollError struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// PollStatus describes the scoring event polling of a server instance. Only the instance holding the poll lease
+// actually polls, and the counts are only for the messages it processed since it started.
+type PollStatus struct {
+	Running                 bool        `json:"running"`
+	LeaseHeld               bool        `json:"leaseHeld"`
+	IntervalSeconds         float64     `json:"intervalSeconds"`
+	WindowStart             time.Time   `json:"windowStart"`
+	WindowEnd               time.Time   `json:"windowEnd"`
+	LogsFetched             int         `json:"logsFetched"`
+	CatchingUp              bool        `json:"catchingUp"`
+	CatchUpRemainingSeconds float64     `json:"catchUpRemainingSeconds"`
+	Accepted                int64       `json:"accepted"`
+	Skipped                 int64       `json:"skipped"`
+	Failed                  int64       `json:"failed"`
+	RecentErrors            []PollError `json:"recentErrors"`
+	LastPolled              time.Time   `json:"lastPolledOn"`
+	LastPollCompleted       time.Time   `json:"lastPollCompleted"`
+	LagSeconds              float64     `json:"lagSeconds"`
+	Stale                   bool        `json:"stale"`
+}
+
+const ImportAccepted = "accepted"
+const ImportSkipped = "skipped"
+const ImportFailed = "failed"
+
+type ImportResult struct {
+	Line     int    `json:"line"`
+	SourceId string `json:"sourceId,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Accepted int            `json:"accepted"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Results  []ImportResult `json:"results"`
+}
+
+// Add appends the result to the report, and updates the totals for its status.
+func (r *ImportReport) Add(result ImportResult) {
+	switch result.Status {
+	case ImportAccepted:
+		r.Accepted++
+	case ImportSkipped:
+		r.Skipped++
+	default:
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
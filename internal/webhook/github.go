@@ -0,0 +1,209 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"strings"
+)
+
+const HeaderSignature = "X-Hub-Signature-256"
+const HeaderEvent = "X-GitHub-Event"
+const HeaderDelivery = "X-GitHub-Delivery"
+
+const EventPing = "ping"
+const EventPullRequest = "pull_request"
+const EventCheckRun = "check_run"
+
+// EventSourceGitHub matches the (lower case) event source sent by Lift, so webhook scores land in the same place.
+const EventSourceGitHub = "github"
+
+const signaturePrefix = "sha256="
+
+// LabelPrefixFixedBug is the label prefix used to mark a merged pull request as fixing a bug of the given category,
+// e.g. "fixed-bug:NullAway".
+const LabelPrefixFixedBug = "fixed-bug:"
+
+const actionClosed = "closed"
+const actionCompleted = "completed"
+
+// ValidSignature checks the HMAC-SHA256 signature GitHub sends in the X-Hub-Signature-256 header.
+func ValidSignature(secret, signatureHeader string, body []byte) bool {
+	if secret == "" || !strings.HasPrefix(signatureHeader, signaturePrefix) {
+		return false
+	}
+	signature, err := hex.DecodeString(signatureHeader[len(signaturePrefix):])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// Sign returns the X-Hub-Signature-256 header value for the given body. Useful for replaying recorded payloads.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+type gitHubUser struct {
+	Login string `json:"login"`
+}
+
+type gitHubRepository struct {
+	Name  string     `json:"name"`
+	Owner gitHubUser `json:"owner"`
+}
+
+type gitHubLabel struct {
+	Name string `json:"name"`
+}
+
+type gitHubPullRequest struct {
+	Number int           `json:"number"`
+	Merged bool          `json:"merged"`
+	User   gitHubUser    `json:"user"`
+	Labels []gitHubLabel `json:"labels"`
+}
+
+type pullRequestEvent struct {
+	Action      string            `json:"action"`
+	Number      int               `json:"number"`
+	PullRequest gitHubPullRequest `json:"pull_request"`
+	Repository  gitHubRepository  `json:"repository"`
+	Sender      gitHubUser        `json:"sender"`
+}
+
+type checkRunPullRequest struct {
+	Number int `json:"number"`
+}
+
+type checkRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text"`
+}
+
+type checkRun struct {
+	Status       string                `json:"status"`
+	Conclusion   string                `json:"conclusion"`
+	Output       checkRunOutput        `json:"output"`
+	PullRequests []checkRunPullRequest `json:"pull_requests"`
+}
+
+type checkRunEvent struct {
+	Action     string           `json:"action"`
+	CheckRun   checkRun         `json:"check_run"`
+	Repository gitHubRepository `json:"repository"`
+	Sender     gitHubUser       `json:"sender"`
+}
+
+// ConvertGitHubEvent converts a GitHub webhook payload into a ScoringMessage. A nil message (with no error) means
+// the event is valid, but has nothing to score.
+func ConvertGitHubEvent(eventType string, body []byte) (msg *types.ScoringMessage, err error) {
+	switch eventType {
+	case EventPing:
+		return
+	case EventPullRequest:
+		msg, err = convertPullRequest(body)
+	case EventCheckRun:
+		msg, err = convertCheckRun(body)
+	default:
+		err = fmt.Errorf("unsupported github event type: %s", eventType)
+	}
+	return
+}
+
+// convertPullRequest scores merged pull requests, using labels like "fixed-bug:NullAway" to count fixed bugs.
+func convertPullRequest(body []byte) (msg *types.ScoringMessage, err error) {
+	event := pullRequestEvent{}
+	if err = json.Unmarshal(body, &event); err != nil {
+		return
+	}
+	if event.Action != actionClosed || !event.PullRequest.Merged {
+		return
+	}
+
+	bugCounts := map[string]interface{}{}
+	totalFixed := 0
+	for _, label := range event.PullRequest.Labels {
+		if !strings.HasPrefix(label.Name, LabelPrefixFixedBug) {
+			continue
+		}
+		category := strings.TrimSpace(label.Name[len(LabelPrefixFixedBug):])
+		if category == "" {
+			continue
+		}
+		count, _ := bugCounts[category].(float64)
+		bugCounts[category] = count + 1
+		totalFixed++
+	}
+	if totalFixed == 0 {
+		return
+	}
+
+	msg = &types.ScoringMessage{
+		EventSource: EventSourceGitHub,
+		RepoOwner:   event.Repository.Owner.Login,
+		RepoName:    event.Repository.Name,
+		TriggerUser: event.PullRequest.User.Login,
+		TotalFixed:  totalFixed,
+		BugCounts:   bugCounts,
+		PullRequest: event.PullRequest.Number,
+	}
+	return
+}
+
+// convertCheckRun scores completed check runs whose output text holds a Lift style scoring document, e.g.
+// {"fixed-bugs": 2, "fixed-bug-types": {"NullAway": 2}, "triggerUser": "someone"}. The triggerUser is required.
+func convertCheckRun(body []byte) (msg *types.ScoringMessage, err error) {
+	event := checkRunEvent{}
+	if err = json.Unmarshal(body, &event); err != nil {
+		return
+	}
+	if event.Action != actionCompleted || len(event.CheckRun.PullRequests) == 0 {
+		return
+	}
+
+	scored := types.ScoringMessage{}
+	if jsonErr := json.Unmarshal([]byte(event.CheckRun.Output.Text), &scored); jsonErr != nil {
+		// not every check run is from an analyzer we understand, so quietly ignore it
+		return
+	}
+	if scored.TotalFixed < 1 || scored.TriggerUser == "" {
+		// check run payloads don't hold the pull request author, and the sender is the app that ran the check,
+		// so there is no one to credit unless the scoring document names them
+		return
+	}
+
+	scored.EventSource = EventSourceGitHub
+	scored.RepoOwner = event.Repository.Owner.Login
+	scored.RepoName = event.Repository.Name
+	scored.PullRequest = event.CheckRun.PullRequests[0].Number
+	msg = &scored
+	return
+}
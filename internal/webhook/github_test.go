@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package webhook
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const testSecret = "myWebhookSecret"
+
+func readFixture(t *testing.T, name string) []byte {
+	body, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return body
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"zen": "Keep it logically awesome."}`)
+	assert.True(t, ValidSignature(testSecret, Sign(testSecret, body), body))
+}
+
+func TestValidSignatureWrongSecret(t *testing.T) {
+	body := []byte(`{"zen": "Keep it logically awesome."}`)
+	assert.False(t, ValidSignature(testSecret, Sign("otherSecret", body), body))
+}
+
+func TestValidSignatureEmptySecret(t *testing.T) {
+	body := []byte(`{}`)
+	assert.False(t, ValidSignature("", Sign("", body), body))
+}
+
+func TestValidSignatureMissingPrefix(t *testing.T) {
+	body := []byte(`{}`)
+	assert.False(t, ValidSignature(testSecret, Sign(testSecret, body)[len(signaturePrefix):], body))
+}
+
+func TestValidSignatureNotHex(t *testing.T) {
+	assert.False(t, ValidSignature(testSecret, signaturePrefix+"zz", []byte(`{}`)))
+}
+
+func TestConvertGitHubEventPing(t *testing.T) {
+	msg, err := ConvertGitHubEvent(EventPing, []byte(`{"zen": "Design for failure."}`))
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventUnsupported(t *testing.T) {
+	msg, err := ConvertGitHubEvent("issues", []byte(`{}`))
+	assert.EqualError(t, err, "unsupported github event type: issues")
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventBadJson(t *testing.T) {
+	msg, err := ConvertGitHubEvent(EventPullRequest, []byte(`{`))
+	assert.EqualError(t, err, "unexpected end of JSON input")
+	assert.Nil(t, msg)
+
+	msg, err = ConvertGitHubEvent(EventCheckRun, []byte(`{`))
+	assert.EqualError(t, err, "unexpected end of JSON input")
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventPullRequestMerged(t *testing.T) {
+	msg, err := ConvertGitHubEvent(EventPullRequest, readFixture(t, "pull_request_closed_merged.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringMessage{
+		EventSource: EventSourceGitHub,
+		RepoOwner:   "my-organization",
+		RepoName:    "my-repo",
+		TriggerUser: "MyGitHubId",
+		TotalFixed:  3,
+		BugCounts:   map[string]interface{}{"NullAway": float64(2), "G104": float64(1)},
+		PullRequest: 42,
+	}, msg)
+}
+
+func TestConvertGitHubEventPullRequestNotMerged(t *testing.T) {
+	msg, err := ConvertGitHubEvent(EventPullRequest, readFixture(t, "pull_request_closed_unmerged.json"))
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventPullRequestNoFixedBugLabels(t *testing.T) {
+	body := []byte(`{"action": "closed", "pull_request": {"number": 1, "merged": true, "labels": [{"name": "fixed-bug:"}]}}`)
+	msg, err := ConvertGitHubEvent(EventPullRequest, body)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventCheckRun(t *testing.T) {
+	msg, err := ConvertGitHubEvent(EventCheckRun, readFixture(t, "check_run_completed.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringMessage{
+		EventSource: EventSourceGitHub,
+		RepoOwner:   "my-organization",
+		RepoName:    "my-repo",
+		TriggerUser: "MyGitHubId",
+		TotalFixed:  3,
+		BugCounts:   map[string]interface{}{"ErrorProne": map[string]interface{}{"NullAway": float64(2)}},
+		PullRequest: 42,
+	}, msg)
+}
+
+func TestConvertGitHubEventCheckRunPlainText(t *testing.T) {
+	msg, err := ConvertGitHubEvent(EventCheckRun, readFixture(t, "check_run_completed_plain_text.json"))
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventCheckRunNoTriggerUser(t *testing.T) {
+	msg, err := ConvertGitHubEvent(EventCheckRun, readFixture(t, "check_run_completed_no_trigger_user.json"))
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventCheckRunNoPullRequest(t *testing.T) {
+	body := []byte(`{"action": "completed", "check_run": {"output": {"text": "{\"fixed-bugs\": 1}"}}}`)
+	msg, err := ConvertGitHubEvent(EventCheckRun, body)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestConvertGitHubEventCheckRunNothingFixed(t *testing.T) {
+	body := []byte(`{"action": "completed", "check_run": {"output": {"text": "{\"fixed-bugs\": 0}"}, "pull_requests": [{"number": 7}]}}`)
+	msg, err := ConvertGitHubEvent(EventCheckRun, body)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
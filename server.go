@@ -17,20 +17,29 @@
 package main
 
 import (
+	"context"
 	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/leaderboard"
 	"github.com/sonatype-nexus-community/bbash/internal/poll"
+	"github.com/sonatype-nexus-community/bbash/internal/scoring"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/sonatype-nexus-community/bbash/internal/webhook"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sonatype-nexus-community/bbash/buildversion"
@@ -44,6 +53,9 @@ var postgresDB db.IBBashDB
 var scoreDB db.IScoreDB
 var pollDB db.IDBPoll
 
+// pollLeaseHolderId identifies this instance, so only one server instance polls at a time
+var pollLeaseHolderId = poll.NewLeaseHolderId()
+
 type creationResponse struct {
 	Id        string                 `json:"guid"`
 	Endpoints map[string]interface{} `json:"endpoints"`
@@ -63,6 +75,7 @@ const (
 	ParamBugCategory      string = "bugCategory"
 	ParamPointValue       string = "pointValue"
 	ParamOrganizationName string = "organizationName"
+	ParamDeadLetterId     string = "deadLetterId"
 	pathAdmin             string = "/admin"
 	SourceControlProvider string = "/scp"
 	Organization          string = "/organization"
@@ -78,6 +91,19 @@ const (
 	Bug                   string = "/bug"
 	Campaign              string = "/campaign"
 	Poll                  string = "/poll"
+	Webhook               string = "/webhook"
+	GitHub                string = "/github"
+	Score                 string = "/score"
+	Import                string = "/import"
+	Ledger                string = "/ledger"
+	Recompute             string = "/recompute"
+	DeadLetter            string = "/deadletter"
+	Retry                 string = "/retry"
+	Status                string = "/status"
+	Rules                 string = "/rules"
+	Scores                string = "/scores"
+	Stats                 string = "/stats"
+	Stream                string = "/stream"
 	buildLocation         string = "build"
 )
 
@@ -92,11 +118,36 @@ const envSSLMode = "SSL_MODE"
 const envAdminUsername = "ADMIN_USERNAME"
 const envAdminPassword = "ADMIN_PASSWORD"
 const envLogFilterIncludeHostname = "LOG_FILTER_INCLUDE_HOSTNAME"
+const envGitHubWebhookSecret = "GITHUB_WEBHOOK_SECRET"
+const envScoreSource = "SCORE_SOURCE"
+const envScoreSourceFile = "SCORE_SOURCE_FILE"
+const envProcessedEventRetentionDays = "PROCESSED_EVENT_RETENTION_DAYS"
+const envShutdownTimeoutSeconds = "SHUTDOWN_TIMEOUT_SECONDS"
+const envDDMappingFile = "DD_MAPPING_FILE"
+const envStreamMaxConnections = "STREAM_MAX_CONNECTIONS"
+
+const defaultProcessedEventRetentionDays = 90
+const processedEventCleanupInterval = time.Hour
+
+// defaultShutdownTimeoutSeconds fits within the default ECS stop timeout of 30 seconds
+const defaultShutdownTimeoutSeconds = 25
+
+const defaultStreamMaxConnections = 500
+
+// streamHeartbeatInterval is well within the default AWS load balancer idle timeout of 60 seconds
+const streamHeartbeatInterval = 15 * time.Second
+
+// pollStaleIntervals is the number of poll intervals without a completed poll, before polling is reported as stale
+const pollStaleIntervals = 5
 
 var errRecovered error
 var logger *zap.Logger
 
-var stopPoll chan bool
+// pollManager is the only way to start and stop polling, so concurrent admin requests are safe
+var pollManager poll.IManager = poll.NewManager(beginLogPolling)
+
+// leaderboardBroker publishes score changes to the leaderboard streams of this server
+var leaderboardBroker = leaderboard.NewBroker(defaultStreamMaxConnections)
 
 func main() {
 	e := echo.New()
@@ -167,29 +218,147 @@ func main() {
 		logger.Info("db migration complete")
 	}
 
+	scoreDB = postgresDB
+	pollDB = db.NewDBPoll(pg, logger)
+	poll.UseLogger(logger)
+
+	err = useDatadogMapping()
+	if err != nil {
+		logger.Error("datadog mapping", zap.Error(err))
+		panic(fmt.Errorf("failed to load datadog mapping. err: %+v", err))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == cmdImport {
+		err = runImportCommand(os.Args[2:], os.Stdout)
+		if err != nil {
+			logger.Error("import", zap.Error(err))
+			panic(fmt.Errorf("failed to import scoring events. err: %+v", err))
+		}
+		return
+	}
+
+	leaderboardBroker = leaderboard.NewBroker(streamMaxConnections())
 	setupRoutes(e, buildInfoMessage)
 
-	scoreDB = postgresDB
+	stopCleanup := startProcessedEventCleanup(processedEventRetention(), processedEventCleanupInterval)
+	defer close(stopCleanup)
+
 	if os.Getenv("DISABLE_DATADOG_POLL") == "" {
 		// polling voodoo
-		var errChan chan error
-		stopPoll, errChan, err = beginLogPolling()
+		// stopped during shutdown
+		err = pollManager.Start()
 		if err != nil {
-		    logger.Error("begin polling", zap.Error(err))
-		    panic(fmt.Errorf("failed to start polling. err: %+v", err))
+			logger.Error("begin polling", zap.Error(err))
+			panic(fmt.Errorf("failed to start polling. err: %+v", err))
 		}
+	}
+
+	// ECS sends SIGTERM to stop the task, and later SIGKILL if it has not stopped
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
 
-		defer func() {
-			close(stopPoll)
-			pollErr := <-errChan
-			logger.Error("defer poll error", zap.Error(pollErr))
-		}()
+	// deferred funcs above close the database and flush the logger once served
+	err = serve(ctx, e, defaultServicePort, shutdownTimeout())
+	logger.Info("application end", zap.Error(err))
+}
+
+// serve runs the server until the context is done (e.g. a shutdown signal was received), or the server fails, and then
+// shuts down.
+func serve(ctx context.Context, e *echo.Echo, address string, timeout time.Duration) (err error) {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.Start(address)
+	}()
+
+	select {
+	case err = <-serverErr:
+		logger.Error("server failed", zap.Error(err))
+	case <-ctx.Done():
+		logger.Info("shutdown signal received")
 	}
 
-	logger.Fatal("application end", zap.Error(e.Start(defaultServicePort)))
+	if shutdownErr := shutdown(e, timeout); err == nil {
+		err = shutdownErr
+	}
+	return
 }
 
-func beginLogPolling() (quit chan bool, errChan chan error, err error) {
+// shutdown ends the leaderboard streams, drains in-flight requests, and then stops polling, letting any in-flight poll
+// complete. Both must finish within the timeout.
+func shutdown(e *echo.Echo, timeout time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// streams never finish on their own, so would hold up the drain
+	leaderboardBroker.Close()
+	err = e.Shutdown(ctx)
+	if err != nil {
+		logger.Error("server shutdown", zap.Error(err))
+	}
+
+	pollStopped := make(chan error, 1)
+	go func(poller poll.IManager) {
+		pollStopped <- poller.Stop()
+	}(pollManager)
+	select {
+	case pollErr := <-pollStopped:
+		logger.Info("poll stopped", zap.Error(pollErr))
+	case <-ctx.Done():
+		logger.Error("poll stop timed out", zap.Duration("timeout", timeout))
+		if err == nil {
+			err = fmt.Errorf("timed out waiting for polling to stop: %w", ctx.Err())
+		}
+	}
+	return
+}
+
+// shutdownTimeout reads how long to wait for in-flight requests and polling to finish, after a shutdown signal.
+func shutdownTimeout() time.Duration {
+	timeoutSeconds, err := strconv.Atoi(os.Getenv(envShutdownTimeoutSeconds))
+	if err != nil || timeoutSeconds < 1 {
+		timeoutSeconds = defaultShutdownTimeoutSeconds
+		logger.Info("missing or invalid env var, using default",
+			zap.String("envVar", envShutdownTimeoutSeconds),
+			zap.Int("timeoutSeconds", timeoutSeconds),
+			zap.Error(err),
+		)
+	}
+	return time.Duration(timeoutSeconds) * time.Second
+}
+
+// streamMaxConnections reads how many leaderboard streams this server allows at once.
+func streamMaxConnections() int {
+	maxConnections, err := strconv.Atoi(os.Getenv(envStreamMaxConnections))
+	if err != nil || maxConnections < 1 {
+		maxConnections = defaultStreamMaxConnections
+		logger.Info("missing or invalid env var, using default",
+			zap.String("envVar", envStreamMaxConnections),
+			zap.Int("maxConnections", maxConnections),
+			zap.Error(err),
+		)
+	}
+	return maxConnections
+}
+
+// useDatadogMapping reads the Datadog query and log field mapping from the DD_MAPPING_FILE, if set. It applies to
+// both polling and imports.
+func useDatadogMapping() (err error) {
+	mappingFile := os.Getenv(envDDMappingFile)
+	if mappingFile == "" {
+		return
+	}
+	var mapping poll.Mapping
+	mapping, err = poll.LoadMapping(mappingFile)
+	if err != nil {
+		return
+	}
+	poll.UseMapping(mapping)
+	logger.Info("using datadog mapping", zap.String("mappingFile", mappingFile), zap.Any("mapping", mapping))
+	return
+}
+
+// beginLogPolling polls until the context is cancelled. Use the pollManager, rather than calling this directly.
+func beginLogPolling(ctx context.Context) (done <-chan error, err error) {
 	err = godotenv.Load(".env.dd")
 	if err != nil {
 		logger.Error(".env.dd load error", zap.Error(err))
@@ -209,24 +378,88 @@ func beginLogPolling() (quit chan bool, errChan chan error, err error) {
 		err = nil
 	}
 
-	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
-	quit, errChan = poll.ChaseTail(pollDB, scoreDB, time.Duration(pollDogIntervalSeconds), processScoringMessage)
+	var source poll.ScoreSource
+	source, err = newScoreSource()
+	if err != nil {
+		return
+	}
+
+	done = poll.ChaseTail(ctx, pollDB, scoreDB, source, time.Duration(pollDogIntervalSeconds), pollLeaseHolderId, scoreMessage)
 	return
 }
 
-//goland:noinspection GoUnusedParameter
-func restartPolling(c echo.Context) (err error) {
-	if stopPoll != nil {
-		close(stopPoll)
+// processedEventRetention reads how long processed event ids are kept. It must be longer than any poll rewind.
+func processedEventRetention() time.Duration {
+	retentionDays, err := strconv.Atoi(os.Getenv(envProcessedEventRetentionDays))
+	if err != nil || retentionDays < 1 {
+		retentionDays = defaultProcessedEventRetentionDays
+		logger.Info("missing or invalid env var, using default",
+			zap.String("envVar", envProcessedEventRetentionDays),
+			zap.Int("retentionDays", retentionDays),
+			zap.Error(err),
+		)
+	}
+	return time.Duration(retentionDays) * 24 * time.Hour
+}
+
+func deleteExpiredProcessedEvents(now time.Time, retention time.Duration) {
+	rowsAffected, err := postgresDB.DeleteProcessedEvents(now.Add(-retention))
+	if err != nil {
+		logger.Error("error deleting processed events", zap.Error(err))
+		return
+	}
+	logger.Debug("deleted processed events", zap.Int64("rowsAffected", rowsAffected), zap.Duration("retention", retention))
+}
+
+// startProcessedEventCleanup deletes expired processed event ids now, and then every interval, until stopped.
+func startProcessedEventCleanup(retention, interval time.Duration) (stop chan bool) {
+	stop = make(chan bool)
+	deleteExpiredProcessedEvents(time.Now(), retention)
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				deleteExpiredProcessedEvents(now, retention)
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return
+}
+
+// newScoreSource selects the backend polled for scoring events, defaulting to Datadog.
+func newScoreSource() (source poll.ScoreSource, err error) {
+	sourceType := os.Getenv(envScoreSource)
+	switch sourceType {
+	case "", poll.SourceDatadog:
+		source = poll.NewDatadogSource()
+	case poll.SourceJsonl:
+		sourceFile := os.Getenv(envScoreSourceFile)
+		if sourceFile == "" {
+			err = fmt.Errorf("score source %s requires env var %s", sourceType, envScoreSourceFile)
+			return
+		}
+		source = poll.NewFileSource(sourceFile)
+	default:
+		err = fmt.Errorf("unknown score source: %s", sourceType)
+		return
 	}
-	stopPoll, _, err = beginLogPolling()
+	logger.Info("score source", zap.String("sourceType", sourceType), zap.String("source", fmt.Sprintf("%T", source)))
 	return
 }
 
+//goland:noinspection GoUnusedParameter
+func restartPolling(c echo.Context) (err error) {
+	return pollManager.Restart()
+}
+
 //goland:noinspection GoUnusedParameter
 func stopPolling(c echo.Context) (err error) {
-	close(stopPoll)
-	stopPoll = nil
+	pollErr := pollManager.Stop()
+	logger.Info("poll stopped", zap.Error(pollErr))
 	return
 }
 
@@ -253,10 +486,51 @@ func setPollDate(c echo.Context) (err error) {
 	return
 }
 
-func setupRoutes(e *echo.Echo, buildInfoMessage string) (customRouteCount int) {
-	e.GET("/health", func(c echo.Context) error {
+// currentPollStatus adds the last poll recorded in the database, which may have been done by another server instance,
+// to the poll status of this instance. Polling is stale when it has begun, but no poll completed for a while.
+func currentPollStatus(now time.Time) (pollStatus types.PollStatus, err error) {
+	pollStatus = pollManager.Status()
+
+	pollFromDb := pollDB.NewPoll()
+	err = pollDB.SelectPoll(&pollFromDb)
+	if err != nil {
+		return
+	}
+	pollStatus.LastPolled = pollFromDb.LastPolled
+	pollStatus.LastPollCompleted = pollFromDb.LastPollCompleted
+	pollStatus.LagSeconds = now.Sub(pollFromDb.LastPolled).Seconds()
+	pollInterval := time.Duration(pollStatus.IntervalSeconds * float64(time.Second))
+	pollStatus.Stale = pollStatus.Running && now.Sub(pollFromDb.LastPollCompleted) > pollStaleIntervals*pollInterval
+	return
+}
+
+func getPollStatus(c echo.Context) (err error) {
+	pollStatus, err := currentPollStatus(time.Now())
+	if err != nil {
+		return
+	}
+	return c.JSON(http.StatusOK, pollStatus)
+}
+
+// getHealth reports polling problems as degraded, but still healthy, because restarting this instance will not fix them
+func getHealth(buildInfoMessage string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if pollManager.Status().Running {
+			pollStatus, err := currentPollStatus(time.Now())
+			if err != nil {
+				return c.String(http.StatusOK, fmt.Sprintf("I am DEGRADED, poll status error: %s. %s", err, buildInfoMessage))
+			}
+			if pollStatus.Stale {
+				return c.String(http.StatusOK, fmt.Sprintf("I am DEGRADED, polling is stale, last poll completed: %s. %s",
+					pollStatus.LastPollCompleted.Format(time.RFC3339), buildInfoMessage))
+			}
+		}
 		return c.String(http.StatusOK, fmt.Sprintf("I am ALIVE. %s", buildInfoMessage))
-	})
+	}
+}
+
+func setupRoutes(e *echo.Echo, buildInfoMessage string) (customRouteCount int) {
+	e.GET("/health", getHealth(buildInfoMessage))
 
 	// admin endpoint group
 	adminGroup := e.Group(pathAdmin, middleware.BasicAuth(infoBasicValidator))
@@ -280,12 +554,22 @@ func setupRoutes(e *echo.Echo, buildInfoMessage string) (customRouteCount int) {
 	publicParticipantGroup.GET(
 		fmt.Sprintf("%s/:%s", List, ParamCampaignName),
 		getParticipantsList).Name = "participant-list"
+	publicParticipantGroup.GET(
+		fmt.Sprintf("%s/:%s", Stream, ParamCampaignName),
+		streamParticipants).Name = "participant-stream"
+	publicParticipantGroup.GET(
+		fmt.Sprintf("/:%s/:%s/:%s%s", ParamCampaignName, ParamScpName, ParamLoginName, Scores),
+		getParticipantScores).Name = "participant-scores"
 
 	participantGroup := adminGroup.Group(Participant)
 	participantGroup.GET(
 		fmt.Sprintf("%s/:%s/:%s/:%s", Detail, ParamCampaignName, ParamScpName, ParamLoginName),
 		getParticipantDetail).Name = "participant-detail"
 
+	participantGroup.GET(
+		fmt.Sprintf("%s/:%s/:%s/:%s", Ledger, ParamCampaignName, ParamScpName, ParamLoginName),
+		getParticipantLedger).Name = "participant-ledger"
+
 	participantGroup.POST(Update, updateParticipant).Name = "participant-update"
 	participantGroup.PUT(Add, logAddParticipant).Name = "participant-add"
 	participantGroup.DELETE(
@@ -295,10 +579,16 @@ func setupRoutes(e *echo.Echo, buildInfoMessage string) (customRouteCount int) {
 
 	// Team related endpoints and group
 
+	publicTeamGroup := e.Group(Team)
+	publicTeamGroup.GET(fmt.Sprintf("%s/:%s", List, ParamCampaignName), getTeamsList).Name = "team-list"
+
 	teamGroup := adminGroup.Group(Team)
 
 	teamGroup.PUT(Add, addTeam)
+	teamGroup.POST(fmt.Sprintf("%s/:%s/:%s", Update, ParamCampaignName, ParamTeamName), updateTeam)
+	teamGroup.DELETE(fmt.Sprintf("%s/:%s/:%s", Delete, ParamCampaignName, ParamTeamName), deleteTeam)
 	teamGroup.PUT(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Person, ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName), addPersonToTeam)
+	teamGroup.DELETE(fmt.Sprintf("%s/:%s/:%s/:%s", Person, ParamCampaignName, ParamScpName, ParamLoginName), removePersonFromTeam)
 
 	// Bug related endpoints and group
 
@@ -313,11 +603,15 @@ func setupRoutes(e *echo.Echo, buildInfoMessage string) (customRouteCount int) {
 
 	publicCampaignGroup := e.Group(Campaign)
 	publicCampaignGroup.GET(active, getActiveCampaigns)
+	publicCampaignGroup.GET(fmt.Sprintf("/:%s%s", ParamCampaignName, Stats), getCampaignStats)
 
 	campaignGroup := adminGroup.Group(Campaign)
 	campaignGroup.GET(List, getCampaigns)
 	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Add, ParamCampaignName), addCampaign)
 	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Update, ParamCampaignName), updateCampaign)
+	campaignGroup.POST(fmt.Sprintf("/:%s%s", ParamCampaignName, Recompute), recomputeCampaignScores)
+	campaignGroup.GET(fmt.Sprintf("/:%s%s", ParamCampaignName, Rules), getScoringRules)
+	campaignGroup.PUT(fmt.Sprintf("/:%s%s", ParamCampaignName, Rules), putScoringRules)
 
 	// Poll related endpoints and group
 
@@ -325,6 +619,24 @@ func setupRoutes(e *echo.Echo, buildInfoMessage string) (customRouteCount int) {
 	pollGroup.PUT("/last", setPollDate)
 	pollGroup.DELETE("/stop", stopPolling)
 	pollGroup.GET("/restart", restartPolling)
+	pollGroup.GET(Status, getPollStatus)
+
+	// Score related endpoints and group
+
+	scoreGroup := adminGroup.Group(Score)
+	scoreGroup.POST(Import, importScoringEvents)
+
+	// Dead letter related endpoints and group
+
+	deadLetterGroup := adminGroup.Group(DeadLetter)
+	deadLetterGroup.GET(List, getDeadLetters)
+	deadLetterGroup.POST(fmt.Sprintf("%s/:%s", Retry, ParamDeadLetterId), retryDeadLetter)
+	deadLetterGroup.DELETE(fmt.Sprintf("%s/:%s", Delete, ParamDeadLetterId), deleteDeadLetter)
+
+	// Webhook related endpoints, authenticated via signature rather than basic auth
+
+	webhookGroup := e.Group(Webhook)
+	webhookGroup.POST(GitHub, githubWebhook).Name = "webhook-github"
 
 	e.Static("/", buildLocation)
 
@@ -521,44 +833,47 @@ func validScore(msg *types.ScoringMessage, now time.Time) (participantsToScore [
 	return
 }
 
-func scorePoints(msg *types.ScoringMessage, campaignName string) (points float64) {
-	points = 0
-	scored := float64(0)
-
-	err := traverseBugCounts(msg, campaignName, &points, &scored, &msg.BugCounts)
-	if err != nil {
-		logger.Error("error traversing bugCounts", zap.Error(err), zap.Any("scoringMsg", msg))
+// scorePoints prices the message for the participant by the scoring rules of the participant's campaign, given the
+// participant's scoring history as of the time it is scored, since the daily cap and first fix bonus depend on what the
+// participant scored before. resolved maps each category path of the message to the bug category that priced it.
+func scorePoints(participant *types.ParticipantStruct, msg *types.ScoringMessage, history *types.ScoringHistory) (points float64, resolved map[string]string, err error) {
+	rules := scoring.DefaultRules()
+	if err = postgresDB.SelectScoringRules(participant.CampaignName, &rules); err != nil {
+		return
 	}
 
-	// add 1 point for all non-classified fixed bugs
-	if scored < float64(msg.TotalFixed) {
-		points += float64(msg.TotalFixed) - scored
+	var pointValues map[string]float64
+	if len(msg.BugCounts) > 0 {
+		if pointValues, err = postgresDB.SelectPointValues(participant.CampaignName); err != nil {
+			return
+		}
 	}
 
+	points, resolved, traverseErr := scoring.Score(&rules, msg, func(category string) (value float64, found bool) {
+		value, found = pointValues[category]
+		return
+	}, history)
+	if traverseErr != nil {
+		logger.Error("error traversing bugCounts", zap.Error(traverseErr), zap.Any("scoringMsg", msg))
+	}
 	return
 }
 
-func traverseBugCounts(msg *types.ScoringMessage, campaignName string,
-	points, scored *float64, bugTypes *map[string]interface{}) (err error) {
-
-	for bugType, bugValue := range *bugTypes {
-		switch v := bugValue.(type) {
-		case float64:
-			value := postgresDB.SelectPointValue(msg, campaignName, bugType)
-			*points += v * value
-			*scored += v
-		case map[string]interface{}:
-			// oh joy, recursion.
-			err = traverseBugCounts(msg, campaignName, points, scored, &v)
-		default:
-			err = fmt.Errorf("bugType: %+v has unexpected bugValue type: %+v", bugType, v)
-			logger.Error("traverseBugCounts", zap.Error(err), zap.Any("scoringMsg", msg))
-		}
-	}
+// repriceScoredEvent prices a scored pull request again, given the participant's scoring history as of the time its
+// latest scoring message was scored.
+func repriceScoredEvent(entry *types.LedgerEntry, _ time.Time, history *types.ScoringHistory) (points float64, err error) {
+	participant := &types.ParticipantStruct{CampaignName: entry.CampaignName, ScpName: entry.ScpName, LoginName: entry.LoginName}
+	points, entry.ResolvedCategories, err = scorePoints(participant, &entry.ScoringMessage, history)
 	return
 }
 
 func processScoringMessage(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+	_, err = scoreMessage(scoreDb, now, msg)
+	return
+}
+
+// scoreMessage scores the message for each active campaign of the participant, returning the number of participants scored.
+func scoreMessage(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
 	// force triggerUser to lower case to match database values
 	msg.TriggerUser = strings.ToLower(msg.TriggerUser)
 
@@ -573,27 +888,235 @@ func processScoringMessage(scoreDb db.IScoreDB, now time.Time, msg *types.Scorin
 		return
 	}
 	for _, participantToScore := range activeParticipantsToScore {
+		var scored bool
+		scored, err = scoreParticipant(scoreDb, now, &participantToScore, msg)
+		if err != nil {
+			return
+		}
+		if scored {
+			scoredCount++
+		}
+	}
+	return
+}
 
-		newPoints := scorePoints(msg, participantToScore.CampaignName)
-
-		oldPoints := scoreDb.SelectPriorScore(&participantToScore, msg)
+// newLeaderboardDelta is the public change to the score of a participant, which names the participant only when they
+// are not private.
+func newLeaderboardDelta(campaignName, scpName, loginName string, private bool, oldScore, score float64) (delta types.LeaderboardDelta) {
+	delta = types.LeaderboardDelta{CampaignName: campaignName, OldScore: oldScore, Score: score}
+	if !private {
+		delta.ScpName = scpName
+		delta.LoginName = loginName
+	}
+	return
+}
 
-		err = scoreDb.InsertScoringEvent(&participantToScore, msg, newPoints)
+// scoreParticipant updates the participant's points for the pull request in a single transaction, so a failure part way
+// through never leaves a partial score. A message from an already processed source event is skipped. The points are
+// priced, and the scoring event and ledger entry stamped, as of now.
+func scoreParticipant(scoreDb db.IScoreDB, now time.Time, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (scored bool, err error) {
+	var scoreTx db.IScoreTx
+	scoreTx, err = scoreDb.BeginScoreTx()
+	if err != nil {
+		return
+	}
+	var oldPoints, newPoints float64
+	defer func() {
 		if err != nil {
+			if rollbackErr := scoreTx.Rollback(); rollbackErr != nil {
+				logger.Error("error rolling back score", zap.Error(rollbackErr), zap.Any("ScoringMessage", msg))
+			}
 			return
 		}
+		if err = scoreTx.Commit(); err == nil && scored && newPoints != oldPoints {
+			leaderboardBroker.Publish(newLeaderboardDelta(participantToScore.CampaignName, participantToScore.ScpName,
+				participantToScore.LoginName, participantToScore.Private,
+				participantToScore.Score-(newPoints-oldPoints), participantToScore.Score))
+		}
+	}()
 
-		err = scoreDb.UpdateParticipantScore(&participantToScore, newPoints-oldPoints)
+	if msg.SourceId != "" {
+		var firstTime bool
+		firstTime, err = scoreTx.InsertProcessedEvent(msg.SourceId, participantToScore)
 		if err != nil {
 			return
 		}
+		if !firstTime {
+			logger.Debug("skip already processed event", zap.String("sourceId", msg.SourceId), zap.Any("ScoringMessage", msg))
+			return
+		}
+	}
 
-		logger.Debug("score updated",
-			zap.Float64("newPoints", newPoints), zap.Float64("oldPoints", oldPoints), zap.Any("ScoringMessage", msg))
+	// the history is read while the participant is locked, so concurrent scoring cannot both pay a first fix bonus,
+	// or both fit under the daily cap
+	var history *types.ScoringHistory
+	history, err = scoreTx.SelectScoringHistory(participantToScore, msg, now)
+	if err != nil {
+		return
 	}
+	var resolvedCategories map[string]string
+	newPoints, resolvedCategories, err = scorePoints(participantToScore, msg, history)
+	if err != nil {
+		return
+	}
+
+	oldPoints, err = scoreTx.SelectPriorScore(participantToScore, msg)
+	if err != nil {
+		return
+	}
+
+	err = scoreTx.InsertScoringEvent(participantToScore, msg, newPoints, now)
+	if err != nil {
+		return
+	}
+
+	err = scoreTx.UpdateParticipantScore(participantToScore, newPoints-oldPoints)
+	if err != nil {
+		return
+	}
+
+	err = scoreTx.InsertLedgerEntry(&types.LedgerEntry{
+		CampaignName:       participantToScore.CampaignName,
+		ScpName:            participantToScore.ScpName,
+		LoginName:          participantToScore.LoginName,
+		SourceId:           msg.SourceId,
+		RepoOwner:          msg.RepoOwner,
+		RepoName:           msg.RepoName,
+		PullRequest:        msg.PullRequest,
+		OldPoints:          oldPoints,
+		NewPoints:          newPoints,
+		BugCounts:          msg.BugCounts,
+		ScoringMessage:     *msg,
+		ResolvedCategories: resolvedCategories,
+		CreatedOn:          now,
+	})
+	if err != nil {
+		return
+	}
+
+	scored = true
+	logger.Debug("score updated",
+		zap.Float64("newPoints", newPoints), zap.Float64("oldPoints", oldPoints), zap.Any("ScoringMessage", msg))
+	return
+}
+
+const qpAsOf = "asOf"
+const qpReprice = "reprice"
+const cmdImport = "import"
+
+func parseAsOf(asOfValue string) (asOf time.Time, err error) {
+	if asOfValue == "" {
+		return
+	}
+	asOf, err = time.Parse(time.RFC3339, asOfValue)
+	return
+}
+
+func importScoringMessage(now time.Time, msg *types.ScoringMessage) (scoredCount int, err error) {
+	return scoreMessage(scoreDB, now, msg)
+}
+
+// importScoringEvents backfills scores from a JSONL body of scoring messages or raw Datadog log exports.
+func importScoringEvents(c echo.Context) (err error) {
+	var asOf time.Time
+	asOf, err = parseAsOf(c.QueryParam(qpAsOf))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	var report types.ImportReport
+	report, err = poll.ImportScoringEvents(c.Request().Body, asOf, importScoringMessage)
+	if err != nil {
+		return
+	}
+
+	logger.Info("import scoring events",
+		zap.Time("asOf", asOf), zap.Int("accepted", report.Accepted),
+		zap.Int("skipped", report.Skipped), zap.Int("failed", report.Failed))
+	return c.JSON(http.StatusOK, report)
+}
+
+// runImportCommand handles the "import" CLI subcommand, e.g.: bbash import -as-of 2022-05-16T12:00:00Z missed.jsonl
+func runImportCommand(args []string, out io.Writer) (err error) {
+	flags := flag.NewFlagSet(cmdImport, flag.ContinueOnError)
+	flags.SetOutput(out)
+	asOfValue := flags.String("as-of", "", "RFC3339 time to score every record as of (default: each record's own time)")
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if flags.NArg() != 1 {
+		err = fmt.Errorf("usage: bbash %s [-as-of <RFC3339 time>] <file.jsonl>", cmdImport)
+		return
+	}
+
+	var asOf time.Time
+	asOf, err = parseAsOf(*asOfValue)
+	if err != nil {
+		return
+	}
+
+	var file *os.File
+	file, err = os.Open(flags.Arg(0))
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var report types.ImportReport
+	report, err = poll.ImportScoringEvents(file, asOf, importScoringMessage)
+	if err != nil {
+		return
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
 	return
 }
 
+// githubWebhook accepts signed GitHub pull_request and check_run events, and scores them just like polled Lift logs.
+func githubWebhook(c echo.Context) (err error) {
+	secret := os.Getenv(envGitHubWebhookSecret)
+	if secret == "" {
+		logger.Error("github webhook called, but no secret is configured", zap.String("envVar", envGitHubWebhookSecret))
+		return c.NoContent(http.StatusServiceUnavailable)
+	}
+
+	var body []byte
+	body, err = ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return
+	}
+
+	if !webhook.ValidSignature(secret, c.Request().Header.Get(webhook.HeaderSignature), body) {
+		logger.Info("invalid github webhook signature",
+			zap.String("delivery", c.Request().Header.Get(webhook.HeaderDelivery)))
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	eventType := c.Request().Header.Get(webhook.HeaderEvent)
+	var msg *types.ScoringMessage
+	msg, err = webhook.ConvertGitHubEvent(eventType, body)
+	if err != nil {
+		logger.Info("unusable github webhook event", zap.String("eventType", eventType), zap.Error(err))
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if msg == nil {
+		logger.Debug("nothing to score in github webhook event", zap.String("eventType", eventType))
+		return c.NoContent(http.StatusNoContent)
+	}
+	msg.SourceId = c.Request().Header.Get(webhook.HeaderDelivery)
+
+	err = processScoringMessage(scoreDB, time.Now(), msg)
+	if err != nil {
+		logger.Error("error scoring github webhook event", zap.Any("scoringMsg", msg), zap.Error(err))
+		return
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
 func getParticipantDetail(c echo.Context) (err error) {
 	campaignName := c.Param(ParamCampaignName)
 	scpName := c.Param(ParamScpName)
@@ -610,19 +1133,129 @@ func getParticipantDetail(c echo.Context) (err error) {
 	return c.JSON(http.StatusOK, participant)
 }
 
+// getParticipantLedger returns every scoring decision made for the participant, oldest first.
+func getParticipantLedger(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+	logger.Debug("getting ledger for participant",
+		zap.String("campaignName", campaignName), zap.String("scpName", scpName), zap.String("loginName", loginName))
+
+	var entries []types.LedgerEntry
+	entries, err = postgresDB.SelectParticipantLedger(campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// getParticipantScores explains the score of a participant, by each pull request they scored, with the totals per
+// repository and per bug category. Private participants are not found, as their login name is not public.
+func getParticipantScores(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+	logger.Debug("getting scores for participant",
+		zap.String("campaignName", campaignName), zap.String("scpName", scpName), zap.String("loginName", loginName))
+
+	var participant *types.ParticipantStruct
+	participant, err = postgresDB.SelectParticipantDetail(campaignName, scpName, loginName)
+	if err == sql.ErrNoRows || (err == nil && participant.Private) {
+		return c.String(http.StatusNotFound, "Participant not found")
+	}
+	if err != nil {
+		return
+	}
+
+	var pullRequests []types.ScoredPullRequest
+	pullRequests, err = postgresDB.SelectScoredPullRequests(campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, scoring.Breakdown(participant, pullRequests))
+}
+
+// streamParticipants streams each change to a participant score of the campaign as a server-sent event, as it is
+// scored by this server. Clients should reload the participant list whenever the stream (re)connects.
+func streamParticipants(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var subscription *leaderboard.Subscription
+	subscription, err = leaderboardBroker.Subscribe(campaignName)
+	if err != nil {
+		logger.Info("refused leaderboard stream", zap.String("campaignName", campaignName), zap.Error(err))
+		return c.String(http.StatusServiceUnavailable, err.Error())
+	}
+	defer leaderboardBroker.Unsubscribe(subscription)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	return leaderboard.Serve(c.Request().Context(), subscription, res, res.Flush, streamHeartbeatInterval)
+}
+
+const qpLimit = "limit"
+const qpOffset = "offset"
+const qpTeam = "team"
+const qpScp = "scp"
+const defaultParticipantListLimit = 100
+const maxParticipantListLimit = 1000
+
+// headerTotalCount is the number of items on all pages of a paged list
+const headerTotalCount = "X-Total-Count"
+
+// parseParticipantFilter reads the page (limit and offset) and filters (team and scp) of a participant list request.
+func parseParticipantFilter(c echo.Context) (filter *types.ParticipantFilter, err error) {
+	filter = &types.ParticipantFilter{
+		TeamName: c.QueryParam(qpTeam),
+		ScpName:  c.QueryParam(qpScp),
+		Limit:    defaultParticipantListLimit,
+	}
+	if limit := c.QueryParam(qpLimit); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 || filter.Limit > maxParticipantListLimit {
+			return nil, fmt.Errorf("%s must be from 1 to %d: %s", qpLimit, maxParticipantListLimit, limit)
+		}
+	}
+	if offset := c.QueryParam(qpOffset); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil || filter.Offset < 0 {
+			return nil, fmt.Errorf("%s must be 0 or more: %s", qpOffset, offset)
+		}
+	}
+	return
+}
+
+// getParticipantsList returns a page of the participants of the campaign, ranked by score, with the number of
+// participants on all pages in the X-Total-Count header. Only the public view of each participant is returned.
 func getParticipantsList(c echo.Context) (err error) {
 	logTelemetry(c)
 
 	campaignName := c.Param(ParamCampaignName)
 	logger.Debug("Getting participant list for campaign", zap.String("campaignName", campaignName))
 
+	var filter *types.ParticipantFilter
+	if filter, err = parseParticipantFilter(c); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
 	var participants []types.ParticipantStruct
-	participants, err = postgresDB.SelectParticipantsInCampaign(campaignName)
+	var total int
+	participants, total, err = postgresDB.SelectParticipantsInCampaign(campaignName, filter)
 	if err != nil {
 		return
 	}
 
-	return c.JSON(http.StatusOK, participants)
+	publicParticipants := make([]types.PublicParticipant, len(participants))
+	for i := range participants {
+		publicParticipants[i] = participants[i].Public()
+	}
+
+	c.Response().Header().Set(headerTotalCount, strconv.Itoa(total))
+	return c.JSON(http.StatusOK, publicParticipants)
 }
 
 func updateParticipant(c echo.Context) (err error) {
@@ -632,6 +1265,9 @@ func updateParticipant(c echo.Context) (err error) {
 	if err != nil {
 		return
 	}
+	if err = scoring.ValidatePoints("score", participant.Score); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 
 	var rowsAffected int64
 	rowsAffected, err = postgresDB.UpdateParticipant(&participant)
@@ -709,6 +1345,9 @@ func addTeam(c echo.Context) (err error) {
 	}
 
 	err = postgresDB.InsertTeam(&team)
+	if db.IsUniqueViolation(err) {
+		return c.String(http.StatusConflict, "Team already exists")
+	}
 	if err != nil {
 		return
 	}
@@ -747,13 +1386,108 @@ func addPersonToTeam(c echo.Context) (err error) {
 	}
 }
 
+// getTeamsList is the team leaderboard of the campaign, with each team scored by the team score rule of the campaign.
+func getTeamsList(c echo.Context) (err error) {
+	logTelemetry(c)
+
+	campaignName := c.Param(ParamCampaignName)
+
+	rules := scoring.DefaultRules()
+	if err = postgresDB.SelectScoringRules(campaignName, &rules); err != nil {
+		return
+	}
+	var teams []types.TeamMemberScores
+	teams, err = postgresDB.SelectTeamMemberScores(campaignName)
+	if err != nil {
+		return
+	}
+
+	ranked := scoring.RankTeams(&rules, teams)
+	publicTeams := make([]types.PublicTeam, len(ranked))
+	for i := range ranked {
+		publicTeams[i] = ranked[i].Public()
+	}
+	return c.JSON(http.StatusOK, publicTeams)
+}
+
+// updateTeam renames the team to the name in the request body, unless another team of the campaign has that name.
+func updateTeam(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	teamName := c.Param(ParamTeamName)
+
+	team := types.TeamStruct{}
+	if err = json.NewDecoder(c.Request().Body).Decode(&team); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if team.Name == "" {
+		return c.String(http.StatusBadRequest, "team name must not be empty")
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateTeamName(campaignName, teamName, team.Name)
+	if db.IsUniqueViolation(err) {
+		return c.String(http.StatusConflict, "Team already exists")
+	}
+	if err != nil {
+		return
+	}
+	if rowsAffected < 1 {
+		return c.String(http.StatusNotFound, "Team not found")
+	}
+
+	logger.Info("team renamed",
+		zap.String("campaignName", campaignName), zap.String("teamName", teamName), zap.String("newTeamName", team.Name))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// deleteTeam deletes the team, leaving its members without a team.
+func deleteTeam(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	teamName := c.Param(ParamTeamName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteTeam(campaignName, teamName)
+	if err != nil {
+		return
+	}
+	if rowsAffected < 1 {
+		return c.String(http.StatusNotFound, "Team not found")
+	}
+
+	logger.Info("team deleted", zap.String("campaignName", campaignName), zap.String("teamName", teamName))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func removePersonFromTeam(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteParticipantTeam(campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	if rowsAffected < 1 {
+		return c.String(http.StatusNotFound, "Participant not found in a team")
+	}
+
+	logger.Info("participant removed from team",
+		zap.String("campaignName", campaignName), zap.String("scpName", scpName), zap.String("loginName", loginName))
+	return c.NoContent(http.StatusNoContent)
+}
+
 func validateBug(bugToValidate *types.BugStruct) (err error) {
 	if len(bugToValidate.Campaign) == 0 {
 		err = fmt.Errorf("bug is not valid, empty campaign: bug: %+v", bugToValidate)
 	} else if len(bugToValidate.Category) == 0 {
 		err = fmt.Errorf("bug is not valid, empty category: bug: %+v", bugToValidate)
+	} else if !scoring.ValidCategoryPath(bugToValidate.Category) {
+		err = fmt.Errorf("bug is not valid, empty key in category path: bug: %+v", bugToValidate)
 	} else if bugToValidate.PointValue < 0 {
 		err = fmt.Errorf("bug is not valid, negative PointValue: bug: %+v", bugToValidate)
+	} else if pointsErr := scoring.ValidatePoints("PointValue", bugToValidate.PointValue); pointsErr != nil {
+		err = fmt.Errorf("bug is not valid, %v: bug: %+v", pointsErr, bugToValidate)
 	}
 	if err != nil {
 		logger.Error("validateBug error", zap.Error(err))
@@ -789,7 +1523,7 @@ func addBug(c echo.Context) (err error) {
 func updateBug(c echo.Context) (err error) {
 	campaign := c.Param(ParamCampaignName)
 	category := c.Param(ParamBugCategory)
-	pointValue, err := strconv.Atoi(c.Param(ParamPointValue))
+	pointValue, err := strconv.ParseFloat(c.Param(ParamPointValue), 64)
 	if err != nil {
 		return
 	}
@@ -941,3 +1675,186 @@ func updateCampaign(c echo.Context) (err error) {
 
 	return c.String(http.StatusOK, guid)
 }
+
+// recomputeCampaignScores rebuilds participant scores from stored scoring events, e.g. after a bug point value changes.
+// Use query parameter reprice=true to also re-price the scoring events against the current bug point values and
+// scoring rules.
+func recomputeCampaignScores(c echo.Context) (err error) {
+	campaignName := strings.TrimSpace(c.Param(ParamCampaignName))
+	if len(campaignName) == 0 {
+		err = fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, campaignName)
+		logger.Error("recomputeCampaignScores", zap.Error(err))
+
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	var reprice bool
+	if c.QueryParam(qpReprice) != "" {
+		reprice, err = strconv.ParseBool(c.QueryParam(qpReprice))
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	var report *types.RecomputeReport
+	if reprice {
+		report, err = postgresDB.RecomputeCampaignScores(campaignName, repriceScoredEvent)
+	} else {
+		report, err = postgresDB.RecomputeCampaignScores(campaignName, nil)
+	}
+	if err != nil {
+		return
+	}
+
+	for _, change := range report.Changes {
+		leaderboardBroker.Publish(newLeaderboardDelta(campaignName, change.ScpName, change.LoginName, change.Private,
+			change.OldScore, change.NewScore))
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// getCampaignStats sums up what was scored in the campaign: pull requests, bugs fixed, points by bug category, the top
+// repositories and organizations, how many participants scored, and the points scored each day.
+func getCampaignStats(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	var campaign *types.CampaignStruct
+	campaign, err = postgresDB.GetCampaign(campaignName)
+	if err != nil {
+		return
+	}
+	if campaign.ID == "" {
+		return c.String(http.StatusNotFound, "Campaign not found")
+	}
+
+	var pullRequests []types.ScoredPullRequest
+	if pullRequests, err = postgresDB.SelectCampaignScoredPullRequests(campaignName); err != nil {
+		return
+	}
+	var days []types.DayScore
+	if days, err = postgresDB.SelectCampaignDailyScores(campaignName); err != nil {
+		return
+	}
+	var pointValues map[string]float64
+	if pointValues, err = postgresDB.SelectPointValues(campaignName); err != nil {
+		return
+	}
+	rules := scoring.DefaultRules()
+	if err = postgresDB.SelectScoringRules(campaignName, &rules); err != nil {
+		return
+	}
+
+	stats := scoring.CampaignStats(campaignName, &rules, pullRequests, days, func(category string) (value float64, found bool) {
+		value, found = pointValues[category]
+		return
+	})
+	if stats.RegisteredParticipants, stats.ActiveParticipants, err = postgresDB.CountParticipants(campaignName); err != nil {
+		return
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
+// getScoringRules returns the scoring rules of the campaign, which are the default rules until they are changed.
+func getScoringRules(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	var campaign *types.CampaignStruct
+	campaign, err = postgresDB.GetCampaign(campaignName)
+	if err != nil {
+		return
+	}
+	if campaign.ID == "" {
+		return c.String(http.StatusNotFound, "Campaign not found")
+	}
+
+	rules := scoring.DefaultRules()
+	if err = postgresDB.SelectScoringRules(campaignName, &rules); err != nil {
+		return
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// putScoringRules replaces the scoring rules of the campaign. Rules missing from the body get their default value.
+// The new rules only apply to pull requests scored from now on, unless the campaign is recomputed with reprice=true.
+func putScoringRules(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	rules := scoring.DefaultRules()
+	if err = json.NewDecoder(c.Request().Body).Decode(&rules); err != nil {
+		logger.Error("error decoding scoring rules body", zap.Error(err))
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err = scoring.Validate(&rules); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpsertScoringRules(campaignName, &rules)
+	if err != nil {
+		return
+	}
+	if rowsAffected < 1 {
+		return c.String(http.StatusNotFound, "Campaign not found")
+	}
+
+	logger.Info("scoring rules updated", zap.String("campaignName", campaignName), zap.Any("rules", rules))
+	return c.JSON(http.StatusOK, rules)
+}
+
+func getDeadLetters(c echo.Context) (err error) {
+	var deadLetters []types.DeadLetter
+	deadLetters, err = postgresDB.SelectDeadLetters()
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, deadLetters)
+}
+
+// retryDeadLetter scores the dead letter again, and removes it if that works. Otherwise, the failed attempt is counted.
+func retryDeadLetter(c echo.Context) (err error) {
+	id := c.Param(ParamDeadLetterId)
+
+	var deadLetter *types.DeadLetter
+	deadLetter, err = postgresDB.SelectDeadLetter(id)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, fmt.Sprintf("no dead letter: %s", id))
+	}
+	if err != nil {
+		return
+	}
+
+	event, retryErr := poll.ParseImportRecord([]byte(deadLetter.Payload))
+	if retryErr == nil {
+		msg := event.Message()
+		_, retryErr = scoreMessage(scoreDB, event.ScoreTime(time.Time{}), &msg)
+	}
+	if retryErr != nil {
+		logger.Info("dead letter retry failed", zap.String("deadLetterId", id), zap.Error(retryErr))
+		if _, err = postgresDB.UpdateDeadLetterAttempt(id, retryErr.Error()); err != nil {
+			return
+		}
+		deadLetter.Attempts++
+		deadLetter.Error = retryErr.Error()
+		return c.JSON(http.StatusUnprocessableEntity, deadLetter)
+	}
+
+	if _, err = postgresDB.DeleteDeadLetter(id); err != nil {
+		return
+	}
+	logger.Info("dead letter retried", zap.String("deadLetterId", id), zap.String("sourceId", deadLetter.SourceId))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// deleteDeadLetter discards the dead letter, without scoring it.
+func deleteDeadLetter(c echo.Context) (err error) {
+	id := c.Param(ParamDeadLetterId)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteDeadLetter(id)
+	if err != nil {
+		return
+	}
+	logger.Info("delete dead letter", zap.String("deadLetterId", id), zap.Int64("rowsAffected", rowsAffected))
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return c.JSON(http.StatusNotFound, fmt.Sprintf("no dead letter: %s", id))
+}
@@ -17,24 +17,34 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/leaderboard"
+	"github.com/sonatype-nexus-community/bbash/internal/poll"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/sonatype-nexus-community/bbash/internal/webhook"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
 	"go.uber.org/zap/zaptest/observer"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	url2 "net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -65,6 +75,11 @@ func resetEnvVarPGHost(t *testing.T, origEnvPGHost string) {
 var insertBugGuidCount int
 var priorScoreCallCount float64
 var updateScoreLastDelta float64
+var insertLedgerEntries []types.LedgerEntry
+var scoreTxCommitCount int
+var scoreTxRollbackCount int
+var insertProcessedEventIds []string
+var deleteProcessedEventsBefore []time.Time
 
 type MockBBashDB struct {
 	t                *testing.T
@@ -120,10 +135,9 @@ type MockBBashDB struct {
 	partiesToScoreResult  []types.ParticipantStruct
 	partiesToScoreErr     error
 
-	selectPointValueMsg      *types.ScoringMessage
-	selectPointValueCampaign string
-	selectPointValueBugType  string
-	selectPointValueResult   float64
+	selectPointValuesCampaign string
+	selectPointValuesResult   map[string]float64
+	selectPointValuesErr      error
 
 	updateScoreParticipant *types.ParticipantStruct
 	updateScoreDelta       int
@@ -132,12 +146,71 @@ type MockBBashDB struct {
 	priorScoreParticipant *types.ParticipantStruct
 	priorScoreMsg         *types.ScoringMessage
 	priorScoreResult      float64
+	priorScoreErr         error
+
+	beginScoreTxErr error
+	commitScoreErr  error
+
+	insertProcessedEventDuplicate bool
+	insertProcessedEventErr       error
+
+	deleteProcessedEventsRowsAffected int64
+	deleteProcessedEventsErr          error
+
+	insertDeadLetterErr error
+
+	selectDeadLettersResult []types.DeadLetter
+	selectDeadLettersErr    error
+
+	selectDeadLetterId     string
+	selectDeadLetterResult *types.DeadLetter
+	selectDeadLetterErr    error
+
+	updateDeadLetterId           string
+	updateDeadLetterAttemptErr   string
+	updateDeadLetterRowsAffected int64
+	updateDeadLetterErr          error
+
+	deleteDeadLetterId           string
+	deleteDeadLetterRowsAffected int64
+	deleteDeadLetterErr          error
 
 	insertScoreEvtPartier   *types.ParticipantStruct
 	insertScoreEvtMsg       *types.ScoringMessage
 	insertScoreEvtNewPoints int
 	insertScoreEvtErr       error
 
+	insertLedgerErr error
+
+	recomputeCampaignName string
+	recomputeReprice      bool
+	recomputeResult       *types.RecomputeReport
+	recomputeErr          error
+
+	selectLedgerCampaignName string
+	selectLedgerSCPName      string
+	selectLedgerLoginName    string
+	selectLedgerResult       []types.LedgerEntry
+	selectLedgerErr          error
+
+	selectScoredPRsCampaignName string
+	selectScoredPRsSCPName      string
+	selectScoredPRsLoginName    string
+	selectScoredPRsResult       []types.ScoredPullRequest
+	selectScoredPRsErr          error
+
+	selectCampaignScoredPRsCampaignName string
+	selectCampaignScoredPRsResult       []types.ScoredPullRequest
+	selectCampaignScoredPRsErr          error
+	selectCampaignDaysCampaignName      string
+	selectCampaignDaysResult            []types.DayScore
+	selectCampaignDaysErr               error
+
+	countParticipantsCampaignName string
+	countParticipantsRegistered   int
+	countParticipantsActive       int
+	countParticipantsErr          error
+
 	insertParticipantPartier  *types.ParticipantStruct
 	insertParticipantGuid     string
 	insertParticipantJoinedAt time.Time
@@ -154,7 +227,9 @@ type MockBBashDB struct {
 	selectPartDetailErr       error
 
 	selectPartInCampCamp   string
+	selectPartInCampFilter *types.ParticipantFilter
 	selectPartInCampResult []types.ParticipantStruct
+	selectPartInCampTotal  int
 	selectPartInCampErr    error
 
 	deletePartCampaign  string
@@ -174,6 +249,27 @@ type MockBBashDB struct {
 	updatePartTeamRowsAffected int64
 	updatePartTeamErr          error
 
+	selectTeamsCampaign string
+	selectTeamsResult   []types.TeamMemberScores
+	selectTeamsErr      error
+
+	updateTeamNameCampaign     string
+	updateTeamNameTeam         string
+	updateTeamNameNewTeam      string
+	updateTeamNameRowsAffected int64
+	updateTeamNameErr          error
+
+	deleteTeamCampaign     string
+	deleteTeamTeam         string
+	deleteTeamRowsAffected int64
+	deleteTeamErr          error
+
+	deletePartTeamCampaignName string
+	deletePartTeamSCPName      string
+	deletePartTeamLoginName    string
+	deletePartTeamRowsAffected int64
+	deletePartTeamErr          error
+
 	insertBugBug  *types.BugStruct
 	insertBugGuid string
 	insertBugErr  error
@@ -185,6 +281,15 @@ type MockBBashDB struct {
 	selectBugsResult []types.BugStruct
 	selectBugsErr    error
 
+	selectRulesResult       *types.ScoringRules
+	selectRulesErr          error
+	upsertRulesCampaign     string
+	upsertRules             *types.ScoringRules
+	upsertRulesRowsAffected int64
+	upsertRulesErr          error
+	selectHistoryResult     *types.ScoringHistory
+	selectHistoryErr        error
+
 	selectPoll    types.Poll
 	selectPollErr error
 	updatePoll    types.Poll
@@ -277,51 +382,172 @@ func (m MockBBashDB) SelectParticipantsToScore(msg *types.ScoringMessage, now ti
 	return m.partiesToScoreResult, m.partiesToScoreErr
 }
 
-func (m MockBBashDB) SelectPointValue(msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
+func (m MockBBashDB) SelectPointValues(campaignName string) (pointValues map[string]float64, err error) {
 	if m.assertParameters {
-		assert.Equal(m.t, m.selectPointValueMsg, msg)
-		assert.Equal(m.t, m.selectPointValueCampaign, campaignName)
-		assert.Equal(m.t, m.selectPointValueBugType, bugType)
+		assert.Equal(m.t, m.selectPointValuesCampaign, campaignName)
 	}
-	return m.selectPointValueResult
+	return m.selectPointValuesResult, m.selectPointValuesErr
 }
 
-func (m MockBBashDB) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
+func (m MockBBashDB) SelectParticipantLedger(campaignName, scpName, loginName string) (entries []types.LedgerEntry, err error) {
 	if m.assertParameters {
-		// multiple mock kludge
-		if priorScoreCallCount == 0 {
-			assert.Equal(m.t, m.updateScoreParticipant, participant)
-			assert.Equal(m.t, m.updateScoreDelta, delta)
-		}
+		assert.Equal(m.t, m.selectLedgerCampaignName, campaignName)
+		assert.Equal(m.t, m.selectLedgerSCPName, scpName)
+		assert.Equal(m.t, m.selectLedgerLoginName, loginName)
 	}
-	updateScoreLastDelta = delta
-	return m.updateScoreErr
+	return m.selectLedgerResult, m.selectLedgerErr
+}
+
+func (m MockBBashDB) SelectScoredPullRequests(campaignName, scpName, loginName string) (pullRequests []types.ScoredPullRequest, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectScoredPRsCampaignName, campaignName)
+		assert.Equal(m.t, m.selectScoredPRsSCPName, scpName)
+		assert.Equal(m.t, m.selectScoredPRsLoginName, loginName)
+	}
+	return m.selectScoredPRsResult, m.selectScoredPRsErr
+}
+
+func (m MockBBashDB) SelectCampaignScoredPullRequests(campaignName string) (pullRequests []types.ScoredPullRequest, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectCampaignScoredPRsCampaignName, campaignName)
+	}
+	return m.selectCampaignScoredPRsResult, m.selectCampaignScoredPRsErr
+}
+
+func (m MockBBashDB) SelectCampaignDailyScores(campaignName string) (days []types.DayScore, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectCampaignDaysCampaignName, campaignName)
+	}
+	return m.selectCampaignDaysResult, m.selectCampaignDaysErr
 }
 
-func (m MockBBashDB) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+func (m MockBBashDB) CountParticipants(campaignName string) (registered, active int, err error) {
 	if m.assertParameters {
+		assert.Equal(m.t, m.countParticipantsCampaignName, campaignName)
+	}
+	return m.countParticipantsRegistered, m.countParticipantsActive, m.countParticipantsErr
+}
+
+// mockScoreTx runs scoring against the MockBBashDB that began it, and counts commits and rollbacks
+type mockScoreTx struct {
+	m MockBBashDB
+}
+
+var _ db.IScoreTx = (*mockScoreTx)(nil)
+
+func (m MockBBashDB) BeginScoreTx() (scoreTx db.IScoreTx, err error) {
+	if m.beginScoreTxErr != nil {
+		return nil, m.beginScoreTxErr
+	}
+	return &mockScoreTx{m: m}, nil
+}
+
+func (tx *mockScoreTx) InsertProcessedEvent(sourceId string, participant *types.ParticipantStruct) (firstTime bool, err error) {
+	insertProcessedEventIds = append(insertProcessedEventIds, sourceId)
+	return !tx.m.insertProcessedEventDuplicate, tx.m.insertProcessedEventErr
+}
+
+func (tx *mockScoreTx) SelectScoringHistory(participant *types.ParticipantStruct, msg *types.ScoringMessage, asOf time.Time) (history *types.ScoringHistory, err error) {
+	return tx.m.SelectScoringHistory(participant, msg, asOf)
+}
+
+func (tx *mockScoreTx) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64, err error) {
+	if tx.m.assertParameters {
 		// multiple mock kludge
 		if priorScoreCallCount == 0 {
-			assert.Equal(m.t, m.priorScoreParticipant, participantToScore)
-			assert.Equal(m.t, m.priorScoreMsg, msg)
+			assert.Equal(tx.m.t, tx.m.priorScoreParticipant, participantToScore)
+			assert.Equal(tx.m.t, tx.m.priorScoreMsg, msg)
 		}
 	}
 	// kludge to support multiple calls to mock. maybe
-	scoreToReturn := m.priorScoreResult + priorScoreCallCount
+	scoreToReturn := tx.m.priorScoreResult + priorScoreCallCount
 	priorScoreCallCount++
-	return scoreToReturn
+	return scoreToReturn, tx.m.priorScoreErr
 }
 
-func (m MockBBashDB) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
-	if m.assertParameters {
+func (tx *mockScoreTx) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, scoredOn time.Time) (err error) {
+	if tx.m.assertParameters {
+		// multiple mock kludge
+		if priorScoreCallCount == 0 {
+			assert.Equal(tx.m.t, tx.m.insertScoreEvtPartier, participantToScore)
+			assert.Equal(tx.m.t, tx.m.insertScoreEvtMsg, msg)
+			assert.Equal(tx.m.t, tx.m.insertScoreEvtNewPoints, newPoints)
+		}
+	}
+	return tx.m.insertScoreEvtErr
+}
+
+func (tx *mockScoreTx) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
+	if tx.m.assertParameters {
 		// multiple mock kludge
 		if priorScoreCallCount == 0 {
-			assert.Equal(m.t, m.insertScoreEvtPartier, participantToScore)
-			assert.Equal(m.t, m.insertScoreEvtMsg, msg)
-			assert.Equal(m.t, m.insertScoreEvtNewPoints, newPoints)
+			assert.Equal(tx.m.t, tx.m.updateScoreParticipant, participant)
+			assert.Equal(tx.m.t, tx.m.updateScoreDelta, delta)
 		}
 	}
-	return m.insertScoreEvtErr
+	updateScoreLastDelta = delta
+	if tx.m.updateScoreErr == nil {
+		participant.Score += delta
+	}
+	return tx.m.updateScoreErr
+}
+
+func (tx *mockScoreTx) InsertLedgerEntry(entry *types.LedgerEntry) (err error) {
+	insertLedgerEntries = append(insertLedgerEntries, *entry)
+	return tx.m.insertLedgerErr
+}
+
+func (tx *mockScoreTx) Commit() (err error) {
+	scoreTxCommitCount++
+	return tx.m.commitScoreErr
+}
+
+func (tx *mockScoreTx) Rollback() (err error) {
+	scoreTxRollbackCount++
+	return
+}
+
+func (m MockBBashDB) DeleteProcessedEvents(processedBefore time.Time) (rowsAffected int64, err error) {
+	deleteProcessedEventsBefore = append(deleteProcessedEventsBefore, processedBefore)
+	return m.deleteProcessedEventsRowsAffected, m.deleteProcessedEventsErr
+}
+
+func (m MockBBashDB) InsertDeadLetter(deadLetter *types.DeadLetter) (err error) {
+	return m.insertDeadLetterErr
+}
+
+func (m MockBBashDB) SelectDeadLetters() (deadLetters []types.DeadLetter, err error) {
+	return m.selectDeadLettersResult, m.selectDeadLettersErr
+}
+
+func (m MockBBashDB) SelectDeadLetter(id string) (deadLetter *types.DeadLetter, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectDeadLetterId, id)
+	}
+	return m.selectDeadLetterResult, m.selectDeadLetterErr
+}
+
+func (m MockBBashDB) UpdateDeadLetterAttempt(id, attemptErr string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updateDeadLetterId, id)
+		assert.Equal(m.t, m.updateDeadLetterAttemptErr, attemptErr)
+	}
+	return m.updateDeadLetterRowsAffected, m.updateDeadLetterErr
+}
+
+func (m MockBBashDB) DeleteDeadLetter(id string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteDeadLetterId, id)
+	}
+	return m.deleteDeadLetterRowsAffected, m.deleteDeadLetterErr
+}
+
+func (m MockBBashDB) RecomputeCampaignScores(campaignName string, reprice func(entry *types.LedgerEntry, scoredOn time.Time, history *types.ScoringHistory) (points float64, err error)) (report *types.RecomputeReport, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.recomputeCampaignName, campaignName)
+		assert.Equal(m.t, m.recomputeReprice, reprice != nil)
+	}
+	return m.recomputeResult, m.recomputeErr
 }
 
 func (m MockBBashDB) InsertParticipant(participant *types.ParticipantStruct) (err error) {
@@ -353,11 +579,12 @@ func (m MockBBashDB) DeleteParticipant(campaign, scpName, loginName string) (par
 	return m.deletePartGuid, m.deletePartErr
 }
 
-func (m MockBBashDB) SelectParticipantsInCampaign(campaignName string) (participants []types.ParticipantStruct, err error) {
+func (m MockBBashDB) SelectParticipantsInCampaign(campaignName string, filter *types.ParticipantFilter) (participants []types.ParticipantStruct, total int, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.selectPartInCampCamp, campaignName)
+		assert.Equal(m.t, m.selectPartInCampFilter, filter)
 	}
-	return m.selectPartInCampResult, m.selectPartInCampErr
+	return m.selectPartInCampResult, m.selectPartInCampTotal, m.selectPartInCampErr
 }
 
 func (m MockBBashDB) InsertTeam(team *types.TeamStruct) (err error) {
@@ -369,6 +596,39 @@ func (m MockBBashDB) InsertTeam(team *types.TeamStruct) (err error) {
 	return m.insertTeamErr
 }
 
+func (m MockBBashDB) SelectTeamMemberScores(campaignName string) (teams []types.TeamMemberScores, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectTeamsCampaign, campaignName)
+	}
+	return m.selectTeamsResult, m.selectTeamsErr
+}
+
+func (m MockBBashDB) UpdateTeamName(campaignName, teamName, newTeamName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updateTeamNameCampaign, campaignName)
+		assert.Equal(m.t, m.updateTeamNameTeam, teamName)
+		assert.Equal(m.t, m.updateTeamNameNewTeam, newTeamName)
+	}
+	return m.updateTeamNameRowsAffected, m.updateTeamNameErr
+}
+
+func (m MockBBashDB) DeleteTeam(campaignName, teamName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteTeamCampaign, campaignName)
+		assert.Equal(m.t, m.deleteTeamTeam, teamName)
+	}
+	return m.deleteTeamRowsAffected, m.deleteTeamErr
+}
+
+func (m MockBBashDB) DeleteParticipantTeam(campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deletePartTeamCampaignName, campaignName)
+		assert.Equal(m.t, m.deletePartTeamSCPName, scpName)
+		assert.Equal(m.t, m.deletePartTeamLoginName, loginName)
+	}
+	return m.deletePartTeamRowsAffected, m.deletePartTeamErr
+}
+
 func (m MockBBashDB) UpdateParticipant(participant *types.ParticipantStruct) (rowsAffected int64, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.updateParticipantPartier, participant)
@@ -414,6 +674,29 @@ func (m MockBBashDB) SelectBugs() (bugs []types.BugStruct, err error) {
 	return m.selectBugsResult, m.selectBugsErr
 }
 
+func (m MockBBashDB) SelectScoringRules(campaignName string, rules *types.ScoringRules) (err error) {
+	if m.selectRulesResult != nil {
+		*rules = *m.selectRulesResult
+	}
+	return m.selectRulesErr
+}
+
+func (m MockBBashDB) UpsertScoringRules(campaignName string, rules *types.ScoringRules) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertRulesCampaign, campaignName)
+		assert.Equal(m.t, m.upsertRules, rules)
+	}
+	return m.upsertRulesRowsAffected, m.upsertRulesErr
+}
+
+func (m MockBBashDB) SelectScoringHistory(participant *types.ParticipantStruct, msg *types.ScoringMessage, asOf time.Time) (history *types.ScoringHistory, err error) {
+	history = m.selectHistoryResult
+	if history == nil {
+		history = &types.ScoringHistory{}
+	}
+	return history, m.selectHistoryErr
+}
+
 func (m MockBBashDB) NewPoll() types.Poll {
 	return db.NewPoll()
 }
@@ -443,6 +726,12 @@ func newMockDb(t *testing.T) (mockDbIF *MockBBashDB) {
 	insertBugGuidCount = 0
 	priorScoreCallCount = 0
 	updateScoreLastDelta = 0
+	insertLedgerEntries = nil
+	scoreTxCommitCount = 0
+	scoreTxRollbackCount = 0
+	insertProcessedEventIds = nil
+	deleteProcessedEventsBefore = nil
+	leaderboardBroker = leaderboard.NewBroker(defaultStreamMaxConnections)
 
 	logger = zaptest.NewLogger(t)
 
@@ -635,9 +924,9 @@ func TestSetupRoutes(t *testing.T) {
 	//assert.Equal(t, 22, len(routes))
 	// Out main() method will only print "custom" routes, ignoring defaults added by echo. such defaults are still
 	// included in the "total" route count below
-	assert.Equal(t, 200, len(routes))
+	assert.Equal(t, 261, len(routes))
 
-	assert.Equal(t, 23, customRouteCount)
+	assert.Equal(t, 40, customRouteCount)
 }
 
 const timeLayout = "2006-01-02T15:04:05.000Z"
@@ -935,6 +1224,16 @@ const participantID = "participantUUId"
 const loginName = "loginName"
 const teamName = "myTeamName"
 
+func TestUpdateParticipantScorePrecision(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"guid": "%s","campaignName": "%s", "scpName": "%s", "loginName": "%s", "score": 1.005}`, participantID, campaign, scpName, loginName)
+	c, rec := setupMockContextUpdateParticipant(participantJson)
+	newMockDb(t)
+
+	assert.NoError(t, updateParticipant(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "score must not have more than 2 decimal places: 1.005", rec.Body.String())
+}
+
 func TestUpdateParticipantMissingParticipantID(t *testing.T) {
 	participantJson := fmt.Sprintf(`{"loginName": "%s","campaignName": "%s", "scpName": "%s"}`, loginName, campaign, scpName)
 	c, rec := setupMockContextUpdateParticipant(participantJson)
@@ -1049,6 +1348,21 @@ func TestAddTeamInsertError(t *testing.T) {
 	assert.Equal(t, "", rec.Body.String())
 }
 
+func TestAddTeamNameTaken(t *testing.T) {
+	c, rec := setupMockContextTeam(`{"campaignName": "` + campaign + `","name":"` + teamName + `"}`)
+
+	mock := newMockDb(t)
+	mock.insertTeamTm = &types.TeamStruct{
+		Name:         teamName,
+		CampaignName: campaign,
+	}
+	mock.insertTeamErr = &pq.Error{Code: "23505"}
+
+	assert.NoError(t, addTeam(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "Team already exists", rec.Body.String())
+}
+
 func TestAddTeam(t *testing.T) {
 	teamJson := `{"campaignName": "` + campaign + `","name":"` + teamName + `"}`
 	c, rec := setupMockContextTeam(teamJson)
@@ -1131,6 +1445,332 @@ func TestAddPersonToTeamSomeRowsAffected(t *testing.T) {
 	assert.Equal(t, "", rec.Body.String())
 }
 
+func setupMockContextTeamName(campaignName, teamName, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamTeamName)
+	c.SetParamValues(campaignName, teamName)
+	return
+}
+
+func TestGetTeamsListRulesError(t *testing.T) {
+	c, _ := setupMockContextParticipantList(campaign, "")
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced rules error")
+	mock.selectRulesErr = forcedError
+
+	assert.EqualError(t, getTeamsList(c), forcedError.Error())
+}
+
+func TestGetTeamsListError(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "")
+	mock := newMockDb(t)
+	mock.selectTeamsCampaign = campaign
+	forcedError := fmt.Errorf("forced select teams error")
+	mock.selectTeamsErr = forcedError
+
+	assert.EqualError(t, getTeamsList(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetTeamsListNoTeams(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "")
+	mock := newMockDb(t)
+	mock.selectTeamsCampaign = campaign
+
+	assert.NoError(t, getTeamsList(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[]\n", rec.Body.String())
+}
+
+func TestGetTeamsList(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "")
+	mock := newMockDb(t)
+	mock.selectTeamsCampaign = campaign
+	mock.selectRulesResult = &types.ScoringRules{TeamScore: types.TeamScoreTop, TeamTopMembers: 1}
+	mock.selectTeamsResult = []types.TeamMemberScores{
+		{Team: types.TeamStruct{Id: "teamId1", CampaignName: campaign, Name: "alpha"}, MemberScores: []float64{2, 3.5}},
+		{Team: types.TeamStruct{Id: "teamId2", CampaignName: campaign, Name: "bravo"}, MemberScores: []float64{9}},
+	}
+
+	assert.NoError(t, getTeamsList(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `[{"campaignName":"`+campaign+`","name":"bravo","memberCount":1,"score":9,"rank":1},`+
+		`{"campaignName":"`+campaign+`","name":"alpha","memberCount":2,"score":3.5,"rank":2}]`+"\n", rec.Body.String())
+}
+
+func TestUpdateTeamInvalidBody(t *testing.T) {
+	c, rec := setupMockContextTeamName(campaign, teamName, "{bogus")
+	newMockDb(t)
+
+	assert.NoError(t, updateTeam(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUpdateTeamEmptyName(t *testing.T) {
+	c, rec := setupMockContextTeamName(campaign, teamName, `{"name": ""}`)
+	newMockDb(t)
+
+	assert.NoError(t, updateTeam(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "team name must not be empty", rec.Body.String())
+}
+
+func setupMockUpdateTeamName(t *testing.T) (mock *MockBBashDB) {
+	mock = newMockDb(t)
+	mock.updateTeamNameCampaign = campaign
+	mock.updateTeamNameTeam = teamName
+	mock.updateTeamNameNewTeam = "newTeamName"
+	return
+}
+
+func TestUpdateTeamError(t *testing.T) {
+	c, _ := setupMockContextTeamName(campaign, teamName, `{"name": "newTeamName"}`)
+	mock := setupMockUpdateTeamName(t)
+	forcedError := fmt.Errorf("forced update team error")
+	mock.updateTeamNameErr = forcedError
+
+	assert.EqualError(t, updateTeam(c), forcedError.Error())
+}
+
+func TestUpdateTeamNameTaken(t *testing.T) {
+	c, rec := setupMockContextTeamName(campaign, teamName, `{"name": "newTeamName"}`)
+	mock := setupMockUpdateTeamName(t)
+	mock.updateTeamNameErr = &pq.Error{Code: "23505"}
+
+	assert.NoError(t, updateTeam(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "Team already exists", rec.Body.String())
+}
+
+func TestUpdateTeamNotFound(t *testing.T) {
+	c, rec := setupMockContextTeamName(campaign, teamName, `{"name": "newTeamName"}`)
+	setupMockUpdateTeamName(t)
+
+	assert.NoError(t, updateTeam(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Team not found", rec.Body.String())
+}
+
+func TestUpdateTeam(t *testing.T) {
+	c, rec := setupMockContextTeamName(campaign, teamName, `{"name": "newTeamName"}`)
+	mock := setupMockUpdateTeamName(t)
+	mock.updateTeamNameRowsAffected = 1
+
+	assert.NoError(t, updateTeam(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestDeleteTeamError(t *testing.T) {
+	c, _ := setupMockContextTeamName(campaign, teamName, "")
+	mock := newMockDb(t)
+	mock.deleteTeamCampaign = campaign
+	mock.deleteTeamTeam = teamName
+	forcedError := fmt.Errorf("forced delete team error")
+	mock.deleteTeamErr = forcedError
+
+	assert.EqualError(t, deleteTeam(c), forcedError.Error())
+}
+
+func TestDeleteTeamNotFound(t *testing.T) {
+	c, rec := setupMockContextTeamName(campaign, teamName, "")
+	mock := newMockDb(t)
+	mock.deleteTeamCampaign = campaign
+	mock.deleteTeamTeam = teamName
+
+	assert.NoError(t, deleteTeam(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Team not found", rec.Body.String())
+}
+
+func TestDeleteTeam(t *testing.T) {
+	c, rec := setupMockContextTeamName(campaign, teamName, "")
+	mock := newMockDb(t)
+	mock.deleteTeamCampaign = campaign
+	mock.deleteTeamTeam = teamName
+	mock.deleteTeamRowsAffected = 1
+
+	assert.NoError(t, deleteTeam(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func setupMockDeletePartTeam(t *testing.T) (mock *MockBBashDB) {
+	mock = newMockDb(t)
+	mock.deletePartTeamCampaignName = campaign
+	mock.deletePartTeamSCPName = scpName
+	mock.deletePartTeamLoginName = loginName
+	return
+}
+
+func TestRemovePersonFromTeamError(t *testing.T) {
+	c, _ := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	mock := setupMockDeletePartTeam(t)
+	forcedError := fmt.Errorf("forced remove from team error")
+	mock.deletePartTeamErr = forcedError
+
+	assert.EqualError(t, removePersonFromTeam(c), forcedError.Error())
+}
+
+func TestRemovePersonFromTeamNotFound(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	setupMockDeletePartTeam(t)
+
+	assert.NoError(t, removePersonFromTeam(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Participant not found in a team", rec.Body.String())
+}
+
+func TestRemovePersonFromTeam(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	mock := setupMockDeletePartTeam(t)
+	mock.deletePartTeamRowsAffected = 1
+
+	assert.NoError(t, removePersonFromTeam(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestGetParticipantLedgerError(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectLedgerCampaignName = campaign
+	mock.selectLedgerSCPName = scpName
+	mock.selectLedgerLoginName = loginName
+	forcedError := fmt.Errorf("forced ledger select error")
+	mock.selectLedgerErr = forcedError
+
+	assert.EqualError(t, getParticipantLedger(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetParticipantLedger(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectLedgerCampaignName = campaign
+	mock.selectLedgerSCPName = scpName
+	mock.selectLedgerLoginName = loginName
+	mock.selectLedgerResult = []types.LedgerEntry{
+		{Id: "ledgerId", CampaignName: campaign, ScpName: scpName, LoginName: loginName, SourceId: "myLogId", OldPoints: 1, NewPoints: 3},
+	}
+
+	assert.NoError(t, getParticipantLedger(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `[{"guid":"ledgerId","campaignName":"`+campaign+`","scpName":"`+scpName+`","loginName":"`+loginName+`","sourceId":"myLogId"`), rec.Body.String())
+	assert.True(t, strings.Contains(rec.Body.String(), `"oldPoints":1,"newPoints":3`), rec.Body.String())
+}
+
+func setupMockSelectParticipantScores(t *testing.T) (mock *MockBBashDB) {
+	mock = newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName,
+		Email: "secret@example.com", Score: 7.5}
+	mock.selectScoredPRsCampaignName = campaign
+	mock.selectScoredPRsSCPName = scpName
+	mock.selectScoredPRsLoginName = loginName
+	return
+}
+
+func TestGetParticipantScoresNotFound(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	mock := setupMockSelectParticipantScores(t)
+	mock.selectPartDetailErr = sql.ErrNoRows
+
+	assert.NoError(t, getParticipantScores(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Participant not found", rec.Body.String())
+}
+
+func TestGetParticipantScoresPrivate(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	mock := setupMockSelectParticipantScores(t)
+	mock.selectPartDetailResult.Private = true
+
+	assert.NoError(t, getParticipantScores(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Participant not found", rec.Body.String())
+}
+
+func TestGetParticipantScoresDetailError(t *testing.T) {
+	c, _ := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	mock := setupMockSelectParticipantScores(t)
+	forcedError := fmt.Errorf("forced participant detail error")
+	mock.selectPartDetailErr = forcedError
+
+	assert.EqualError(t, getParticipantScores(c), forcedError.Error())
+}
+
+func TestGetParticipantScoresSelectError(t *testing.T) {
+	c, _ := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	mock := setupMockSelectParticipantScores(t)
+	forcedError := fmt.Errorf("forced scored pull requests error")
+	mock.selectScoredPRsErr = forcedError
+
+	assert.EqualError(t, getParticipantScores(c), forcedError.Error())
+}
+
+func TestGetParticipantScores(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+	mock := setupMockSelectParticipantScores(t)
+	mock.selectScoredPRsResult = []types.ScoredPullRequest{
+		{RepoOwner: "myOwner", RepoName: "myRepo", PullRequest: 2, Points: 5,
+			BugCounts: map[string]interface{}{"opt": map[string]interface{}{"semgrep": float64(2)}}},
+		{RepoOwner: "myOwner", RepoName: "myRepo", PullRequest: 1, Points: 2.5,
+			BugCounts: map[string]interface{}{"opt": map[string]interface{}{"semgrep": float64(1)}}},
+	}
+
+	assert.NoError(t, getParticipantScores(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var breakdown types.ScoreBreakdown
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &breakdown))
+	assert.Equal(t, 7.5, breakdown.Score)
+	assert.Equal(t, 2, len(breakdown.PullRequests))
+	assert.Equal(t, []types.RepositoryScore{{RepoOwner: "myOwner", RepoName: "myRepo", PullRequests: 2, Points: 7.5}},
+		breakdown.Repositories)
+	assert.Equal(t, []types.CategoryScore{{Category: "opt.semgrep", Fixed: 3, PullRequests: 2}}, breakdown.Categories)
+	assert.False(t, strings.Contains(rec.Body.String(), "secret@example.com"), rec.Body.String())
+}
+
+func TestStreamParticipantsTooManyConnections(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "")
+	newMockDb(t)
+	leaderboardBroker = leaderboard.NewBroker(1)
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+	defer leaderboardBroker.Unsubscribe(subscription)
+
+	assert.NoError(t, streamParticipants(c))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, leaderboard.ErrTooManySubscribers.Error(), rec.Body.String())
+}
+
+func TestStreamParticipants(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "")
+	newMockDb(t)
+
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- streamParticipants(c)
+	}()
+	assert.Eventually(t, func() bool {
+		return leaderboardBroker.SubscriberCount() == 1
+	}, time.Second, time.Millisecond)
+	leaderboardBroker.Publish(types.LeaderboardDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, OldScore: 1, Score: 3})
+	leaderboardBroker.Close()
+
+	assert.NoError(t, <-streamed)
+	assert.Equal(t, 0, leaderboardBroker.SubscriberCount())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "event: score\n"+
+		`data: {"campaignName":"`+campaign+`","scpName":"`+scpName+`","loginName":"`+loginName+`","oldScore":1,"score":3}`+"\n\n",
+		rec.Body.String())
+}
+
 func setupMockContextParticipantDetail(campaignName, scpName, loginName string) (c echo.Context, rec *httptest.ResponseRecorder) {
 	e := echo.New()
 	req := httptest.NewRequest("", "/", nil)
@@ -1173,9 +1813,9 @@ func TestGetParticipantDetail(t *testing.T) {
 	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","campaignName":"`+campaign+`","scpName":"`+scpName+`","loginName":"`+loginName+`"`), rec.Body.String())
 }
 
-func setupMockContextParticipantList(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+func setupMockContextParticipantList(campaignName, query string) (c echo.Context, rec *httptest.ResponseRecorder) {
 	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
+	req := httptest.NewRequest("", "/?"+query, nil)
 	rec = httptest.NewRecorder()
 	c = e.NewContext(req, rec)
 	c.SetParamNames(ParamCampaignName)
@@ -1185,9 +1825,10 @@ func setupMockContextParticipantList(campaignName string) (c echo.Context, rec *
 
 func TestGetParticipantsListError(t *testing.T) {
 	campaignName := ""
-	c, rec := setupMockContextParticipantList(campaignName)
+	c, rec := setupMockContextParticipantList(campaignName, "")
 
 	mock := newMockDb(t)
+	mock.selectPartInCampFilter = &types.ParticipantFilter{Limit: defaultParticipantListLimit}
 	forcedError := fmt.Errorf("forced Scan error")
 	mock.selectPartInCampErr = forcedError
 
@@ -1197,10 +1838,11 @@ func TestGetParticipantsListError(t *testing.T) {
 }
 
 func TestGetParticipantsList(t *testing.T) {
-	c, rec := setupMockContextParticipantList(campaign)
+	c, rec := setupMockContextParticipantList(campaign, "")
 
 	mock := newMockDb(t)
 	mock.selectPartInCampCamp = campaign
+	mock.selectPartInCampFilter = &types.ParticipantFilter{Limit: defaultParticipantListLimit}
 	mock.selectPartInCampResult = []types.ParticipantStruct{
 		{
 			ID:           participantID,
@@ -1208,20 +1850,102 @@ func TestGetParticipantsList(t *testing.T) {
 			JoinedAt:     now,
 		},
 	}
+	mock.selectPartInCampTotal = 1
 
 	assert.NoError(t, getParticipantsList(c))
 	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.True(t, strings.HasPrefix(rec.Body.String(), `[{"guid":"`+participantID+`","campaignName":"`+campaign+`","scpName":"","loginName":""`), rec.Body.String())
+	assert.Equal(t, "1", rec.Header().Get(headerTotalCount))
+	assert.Equal(t, `[{"campaignName":"`+campaign+`","displayName":"","score":0,"teamName":""}]`+"\n", rec.Body.String())
 }
 
-func TestValidateBug(t *testing.T) {
-	_, _ = setupMockContext()
-	logger = zaptest.NewLogger(t)
-	assert.EqualError(t, validateBug(&types.BugStruct{}), "bug is not valid, empty campaign: bug: &{Id: Campaign: Category: PointValue:0}")
-	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign"}), "bug is not valid, empty category: bug: &{Id: Campaign:myCampaign Category: PointValue:0}")
-	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: ""}), "bug is not valid, empty category: bug: &{Id: Campaign:myCampaign Category: PointValue:0}")
+func TestGetParticipantsListHidesPrivateDetails(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartInCampFilter = &types.ParticipantFilter{Limit: defaultParticipantListLimit}
+	mock.selectPartInCampResult = []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, Email: "secret@example.com",
+			DisplayName: "Public Name", Score: 3, TeamName: "myTeam", JoinedAt: now, Rank: 1},
+		{ID: "privateId", CampaignName: campaign, ScpName: scpName, LoginName: "privateLogin", Email: "private@example.com",
+			DisplayName: "Private Name", Score: 2, JoinedAt: now, Rank: 2, Private: true},
+	}
+	mock.selectPartInCampTotal = 2
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var participants []types.PublicParticipant
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &participants))
+	assert.Equal(t, []types.PublicParticipant{
+		{CampaignName: campaign, ScpName: scpName, LoginName: loginName, DisplayName: "Public Name", Score: 3, TeamName: "myTeam", Rank: 1},
+		{CampaignName: campaign, DisplayName: "Private Name", Score: 2, Rank: 2},
+	}, participants)
+	for _, hidden := range []string{participantID, "privateId", "privateLogin", "@example.com", "joinedAt", "private"} {
+		assert.False(t, strings.Contains(rec.Body.String(), hidden), hidden)
+	}
+}
+
+func TestGetParticipantsListPagedAndFiltered(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "limit=2&offset=4&team=myTeam&scp=github")
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartInCampFilter = &types.ParticipantFilter{TeamName: "myTeam", ScpName: "github", Limit: 2, Offset: 4}
+	mock.selectPartInCampResult = []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, Score: 5, Rank: 3},
+	}
+	mock.selectPartInCampTotal = 7
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "7", rec.Header().Get(headerTotalCount))
+	assert.True(t, strings.Contains(rec.Body.String(), `"rank":3`), rec.Body.String())
+}
+
+func TestGetParticipantsListEmptyPage(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign, "offset=20")
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartInCampFilter = &types.ParticipantFilter{Limit: defaultParticipantListLimit, Offset: 20}
+	mock.selectPartInCampResult = []types.ParticipantStruct{}
+	mock.selectPartInCampTotal = 3
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "3", rec.Header().Get(headerTotalCount))
+	assert.Equal(t, "[]\n", rec.Body.String())
+}
+
+func TestGetParticipantsListInvalidPage(t *testing.T) {
+	for query, message := range map[string]string{
+		"limit=bogus": "limit must be from 1 to 1000: bogus",
+		"limit=0":     "limit must be from 1 to 1000: 0",
+		"limit=1001":  "limit must be from 1 to 1000: 1001",
+		"offset=-1":   "offset must be 0 or more: -1",
+		"offset=x":    "offset must be 0 or more: x",
+	} {
+		c, rec := setupMockContextParticipantList(campaign, query)
+		newMockDb(t)
+
+		assert.NoError(t, getParticipantsList(c))
+		assert.Equal(t, http.StatusBadRequest, c.Response().Status, query)
+		assert.Equal(t, message, rec.Body.String())
+	}
+}
+
+func TestValidateBug(t *testing.T) {
+	_, _ = setupMockContext()
+	logger = zaptest.NewLogger(t)
+	assert.EqualError(t, validateBug(&types.BugStruct{}), "bug is not valid, empty campaign: bug: &{Id: Campaign: Category: PointValue:0}")
+	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign"}), "bug is not valid, empty category: bug: &{Id: Campaign:myCampaign Category: PointValue:0}")
+	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: ""}), "bug is not valid, empty category: bug: &{Id: Campaign:myCampaign Category: PointValue:0}")
 	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "myCategory", PointValue: -1}), "bug is not valid, negative PointValue: bug: &{Id: Campaign:myCampaign Category:myCategory PointValue:-1}")
+	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "ErrorProne..NullAway"}), "bug is not valid, empty key in category path: bug: &{Id: Campaign:myCampaign Category:ErrorProne..NullAway PointValue:0}")
 	assert.NoError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "myCategory", PointValue: 0}))
+	assert.NoError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "ErrorProne.NullAway", PointValue: 0}))
+	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "myCategory", PointValue: 0.125}), "bug is not valid, PointValue must not have more than 2 decimal places: 0.125: bug: &{Id: Campaign:myCampaign Category:myCategory PointValue:0.125}")
+	assert.NoError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "myCategory", PointValue: 0.5}))
 }
 
 func setupMockContextAddBug(bugJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
@@ -1268,8 +1992,8 @@ func TestAddBugInvalidBug(t *testing.T) {
 	assert.Equal(t, "", rec.Body.String())
 }
 func TestAddBug(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextAddBug(`{"campaign": "` + campaign + `", "category":"` + category + `","pointValue":` + strconv.Itoa(pointValue) + `}`)
+	pointValue := 9.5
+	c, rec := setupMockContextAddBug(`{"campaign": "` + campaign + `", "category":"` + category + `","pointValue":` + fmt.Sprint(pointValue) + `}`)
 
 	mock := newMockDb(t)
 	mock.insertBugBug = &types.BugStruct{
@@ -1283,7 +2007,7 @@ func TestAddBug(t *testing.T) {
 	assert.NoError(t, addBug(c))
 	assert.Equal(t, http.StatusCreated, c.Response().Status)
 	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+bugId+`","endpoints":`), rec.Body.String())
-	assert.True(t, strings.HasSuffix(rec.Body.String(), `"object":{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+strconv.Itoa(pointValue)+`}}`+"\n"), rec.Body.String())
+	assert.True(t, strings.HasSuffix(rec.Body.String(), `"object":{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+fmt.Sprint(pointValue)+`}}`+"\n"), rec.Body.String())
 }
 
 func setupMockContextUpdateBug(campaign, bugCategory, pointValue string) (c echo.Context, rec *httptest.ResponseRecorder) {
@@ -1299,14 +2023,14 @@ func setupMockContextUpdateBug(campaign, bugCategory, pointValue string) (c echo
 func TestUpdateBugInvalidPointValue(t *testing.T) {
 	c, rec := setupMockContextUpdateBug("", "", "non-number")
 
-	assert.EqualError(t, updateBug(c), `strconv.Atoi: parsing "non-number": invalid syntax`)
+	assert.EqualError(t, updateBug(c), `strconv.ParseFloat: parsing "non-number": invalid syntax`)
 	assert.Equal(t, 0, c.Response().Status)
 	assert.Equal(t, "", rec.Body.String())
 }
 
 func TestUpdateBugUpdateError(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextUpdateBug(campaign, category, strconv.Itoa(pointValue))
+	pointValue := 9.5
+	c, rec := setupMockContextUpdateBug(campaign, category, fmt.Sprint(pointValue))
 
 	mock := newMockDb(t)
 	mock.updateBugBug = &types.BugStruct{
@@ -1323,8 +2047,8 @@ func TestUpdateBugUpdateError(t *testing.T) {
 }
 
 func TestUpdateBugRowsAffectedZero(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextUpdateBug(campaign, category, strconv.Itoa(pointValue))
+	pointValue := 9.5
+	c, rec := setupMockContextUpdateBug(campaign, category, fmt.Sprint(pointValue))
 
 	mock := newMockDb(t)
 	mock.updateBugBug = &types.BugStruct{
@@ -1350,8 +2074,8 @@ func TestUpdateBugInvalidBug(t *testing.T) {
 }
 
 func TestUpdateBug(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextUpdateBug(campaign, category, strconv.Itoa(pointValue))
+	pointValue := 9.5
+	c, rec := setupMockContextUpdateBug(campaign, category, fmt.Sprint(pointValue))
 
 	mock := newMockDb(t)
 	mock.updateBugBug = &types.BugStruct{
@@ -1392,7 +2116,7 @@ func TestGetBugs(t *testing.T) {
 	mock := newMockDb(t)
 	bugId := "myBugId"
 	category := "myCategory"
-	pointValue := 9
+	pointValue := 9.5
 	mock.selectBugsResult = []types.BugStruct{
 		{
 			Id:         bugId,
@@ -1404,7 +2128,7 @@ func TestGetBugs(t *testing.T) {
 
 	assert.NoError(t, getBugs(c))
 	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.Equal(t, `[{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+strconv.Itoa(pointValue)+`}]`+"\n", rec.Body.String())
+	assert.Equal(t, `[{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+fmt.Sprint(pointValue)+`}]`+"\n", rec.Body.String())
 }
 
 func setupMockContextPutBugs(bugsJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
@@ -1661,128 +2385,39 @@ func setupMockDBOrgValid(mock *MockBBashDB) {
 	mock.validOrgResult = true
 }
 
-func TestTraverseBugCountsEmpty(t *testing.T) {
-	points := float64(1)
-	scored := float64(2)
-	bugCounts := map[string]interface{}{}
-
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(1), points)
-	assert.Equal(t, float64(2), scored)
-}
-
-func TestTraverseBugCountsSimple(t *testing.T) {
-	bugType := "myBugType"
-
-	mock := newMockDb(t)
-	mock.selectPointValueBugType = bugType
-	mock.selectPointValueResult = 2
-
-	points := float64(1)
-	scored := float64(2)
-	bugCounts := map[string]interface{}{
-		bugType: float64(3),
-	}
-
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(7), points)
-	assert.Equal(t, float64(5), scored)
-}
-
-func TestTraverseBugCountsNestedMap(t *testing.T) {
-	bugType := "myBugType"
-	nestedBugType := "myNestedBugType"
-
-	mock := newMockDb(t)
-	mock.selectPointValueBugType = nestedBugType
-	mock.selectPointValueResult = 2
-
-	points := float64(1)
-	scored := float64(2)
-	mapNestedBugType := map[string]interface{}{
-		nestedBugType: float64(3),
-	}
-	bugCounts := map[string]interface{}{
-		bugType: mapNestedBugType,
-	}
-
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(7), points)
-	assert.Equal(t, float64(5), scored)
-}
-
-func TestTraverseBugCountsSimpleAndNestedMap(t *testing.T) {
-	bugType := "myBugType"
-	nestedBugType := "myNestedBugType"
-
-	mock := newMockDb(t)
-	mock.assertParameters = false
-	mock.selectPointValueResult = 2
-
-	points := float64(1)
-	scored := float64(2)
-	mapNestedBugType := map[string]interface{}{
-		nestedBugType: float64(3),
-	}
-	bugCounts := map[string]interface{}{
-		"bugTypeSimpleFirst": float64(2),
-		bugType:              mapNestedBugType,
-		"bugTypeSimpleLast":  float64(4),
-	}
-
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(19), points)
-	assert.Equal(t, float64(11), scored)
-}
-
-func TestTraverseBugCountsSimpleAndNestedMapNonClassified(t *testing.T) {
-	bugType := "myBugType"
-	nestedBugType := "myNestedBugType"
-
-	mock := newMockDb(t)
-	mock.assertParameters = false
-
-	points := float64(1)
-	scored := float64(2)
-	mapNestedBugType := map[string]interface{}{
-		nestedBugType: float64(3),
-	}
-	bugCounts := map[string]interface{}{
-		"bugTypeSimpleFirst": float64(2),
-		bugType:              mapNestedBugType,
-		"bugTypeSimpleLast":  float64(4),
-	}
-
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(1), points)
-	assert.Equal(t, float64(11), scored)
-}
+var scoredParticipant = &types.ParticipantStruct{CampaignName: campaign, ScpName: "GitHub", LoginName: loginName}
 
 func TestScorePointsNothing(t *testing.T) {
+	newMockDb(t)
 	msg := &types.ScoringMessage{}
-	points := scorePoints(msg, campaign)
+	points, _, err := scorePoints(scoredParticipant, msg, &types.ScoringHistory{})
+	assert.NoError(t, err)
 	assert.Equal(t, float64(0), points)
 }
 
 func TestScorePoints(t *testing.T) {
 	mock := newMockDb(t)
 	msg := &types.ScoringMessage{BugCounts: map[string]interface{}{"myBugType": float64(1)}}
-	mock.selectPointValueMsg = msg
-	mock.selectPointValueCampaign = campaign
-	mock.selectPointValueBugType = "myBugType"
-	mock.selectPointValueResult = 1
+	mock.selectPointValuesCampaign = campaign
+	mock.selectPointValuesResult = map[string]float64{"myBugType": 1}
 
 	_, _ = setupMockContext()
 
-	points := scorePoints(msg, campaign)
+	points, _, err := scorePoints(scoredParticipant, msg, &types.ScoringHistory{})
+	assert.NoError(t, err)
 	assert.Equal(t, float64(1), points)
 }
 
+func TestScorePointsPointValuesError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectPointValuesCampaign = campaign
+	forcedError := fmt.Errorf("forced point values error")
+	mock.selectPointValuesErr = forcedError
+
+	_, _, err := scorePoints(scoredParticipant, &types.ScoringMessage{BugCounts: map[string]interface{}{"G104": float64(1)}}, &types.ScoringHistory{})
+	assert.EqualError(t, err, forcedError.Error())
+}
+
 func TestScorePointsWithTraverseError(t *testing.T) {
 	mock := newMockDb(t)
 	msg := &types.ScoringMessage{BugCounts: map[string]interface{}{
@@ -1790,31 +2425,31 @@ func TestScorePointsWithTraverseError(t *testing.T) {
 		"myGoodugType": float64(2),
 	}}
 	mock.assertParameters = false
-	mock.selectPointValueResult = 2
+	mock.selectPointValuesResult = map[string]float64{"myGoodugType": 2}
 
 	_, _ = setupMockContext()
 
-	points := scorePoints(msg, campaign)
+	points, _, err := scorePoints(scoredParticipant, msg, &types.ScoringHistory{})
+	assert.NoError(t, err)
 	assert.Equal(t, float64(4), points)
 }
 
 func TestScorePointsFixedTwoThreePointers(t *testing.T) {
 	mock := newMockDb(t)
-	mock.selectPointValueResult = 3
 	bugType := "threePointBugType"
 	msg := &types.ScoringMessage{BugCounts: map[string]interface{}{bugType: float64(2)}}
-	mock.selectPointValueMsg = msg
-	mock.selectPointValueCampaign = campaign
-	mock.selectPointValueBugType = bugType
+	mock.selectPointValuesCampaign = campaign
+	mock.selectPointValuesResult = map[string]float64{bugType: 3}
 
-	points := scorePoints(msg, campaign)
+	points, _, err := scorePoints(scoredParticipant, msg, &types.ScoringHistory{})
+	assert.NoError(t, err)
 	assert.Equal(t, float64(6), points)
 }
 
 func TestScorePointsWithOptMap(t *testing.T) {
 	mock := newMockDb(t)
 	mock.assertParameters = false
-	mock.selectPointValueResult = 3
+	mock.selectPointValuesResult = map[string]float64{"G104": 3, "ShellCheck": 3, "sprintf-host-port": 3}
 
 	// similar to this:
 	// "fixed-bug-types":{"opt":{"semgrep":{"node_password":1,"node_username":1}}}
@@ -1829,16 +2464,77 @@ func TestScorePointsWithOptMap(t *testing.T) {
 		BugCounts: mapBugTypes,
 	}
 
-	points := scorePoints(&msg, campaign)
+	points, _, err := scorePoints(scoredParticipant, &msg, &types.ScoringHistory{})
+	assert.NoError(t, err)
 	assert.Equal(t, float64(12), points)
 }
 
 func TestScorePointsBonusForNonClassified(t *testing.T) {
+	newMockDb(t)
 	msg := &types.ScoringMessage{TotalFixed: 1}
-	points := scorePoints(msg, campaign)
+	points, _, err := scorePoints(scoredParticipant, msg, &types.ScoringHistory{})
+	assert.NoError(t, err)
 	assert.Equal(t, float64(1), points)
 }
 
+func TestScorePointsCategoryPaths(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.selectPointValuesResult = map[string]float64{"ErrorProne": 2, "NullAway": 5, "semgrep.node_password": 7}
+	msg := &types.ScoringMessage{BugCounts: map[string]interface{}{
+		"ErrorProne": map[string]interface{}{"NullAway": float64(1), "MissingOverride": float64(1)},
+		"semgrep":    map[string]interface{}{"node_password": float64(1), "node_username": float64(1)},
+	}}
+
+	points, resolved, err := scorePoints(scoredParticipant, msg, &types.ScoringHistory{})
+	assert.NoError(t, err)
+	// NullAway by its old flat category, MissingOverride by its parent, node_password by its path, node_username unpriced
+	assert.Equal(t, float64(5+2+7+1), points)
+	assert.Equal(t, map[string]string{
+		"ErrorProne.NullAway":        "NullAway",
+		"ErrorProne.MissingOverride": "ErrorProne",
+		"semgrep.node_password":      "semgrep.node_password",
+		"semgrep.node_username":      "",
+	}, resolved)
+}
+
+func TestScorePointsCampaignRules(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectRulesResult = &types.ScoringRules{UnclassifiedPoints: 2, DailyCap: 10, FirstFixBonus: 5}
+	msg := &types.ScoringMessage{TotalFixed: 3}
+
+	points, _, err := scorePoints(scoredParticipant, msg, &types.ScoringHistory{PointsToday: 3})
+	assert.NoError(t, err)
+	// 3 unclassified bugs at 2 points each, capped by the 7 points left today
+	assert.Equal(t, float64(6), points)
+
+	points, _, err = scorePoints(scoredParticipant, msg, &types.ScoringHistory{PointsToday: 8})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), points)
+}
+
+func TestScorePointsRulesError(t *testing.T) {
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced rules error")
+	mock.selectRulesErr = forcedError
+
+	_, _, err := scorePoints(scoredParticipant, &types.ScoringMessage{TotalFixed: 1}, &types.ScoringHistory{})
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestRepriceScoredEvent(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectRulesResult = &types.ScoringRules{UnclassifiedPoints: 3}
+
+	entry := &types.LedgerEntry{CampaignName: campaign, ScpName: "GitHub", LoginName: loginName,
+		ScoringMessage: types.ScoringMessage{TotalFixed: 3, BugCounts: map[string]interface{}{"G104": float64(1)}}}
+	mock.assertParameters = false
+	points, err := repriceScoredEvent(entry, now, &types.ScoringHistory{})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(9), points)
+	assert.Equal(t, map[string]string{"G104": ""}, entry.ResolvedCategories)
+}
+
 func TestProcessScoringMessageInvalidScore_Error(t *testing.T) {
 	msg := types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
 
@@ -2018,6 +2714,9 @@ func TestProcessScoringMessageParticipantUpdateScoreError(t *testing.T) {
 
 	err := processScoringMessage(mock, now, msg)
 	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, scoreTxCommitCount)
+	assert.Equal(t, 1, scoreTxRollbackCount)
+	assert.Nil(t, insertLedgerEntries)
 }
 
 func TestProcessScoringMessageParticipant(t *testing.T) {
@@ -2043,10 +2742,8 @@ func TestProcessScoringMessageParticipant(t *testing.T) {
 		},
 	}
 
-	mock.selectPointValueMsg = msgLowerCase
-	mock.selectPointValueCampaign = campaign
-	mock.selectPointValueBugType = category
-	mock.selectPointValueResult = 3
+	mock.selectPointValuesCampaign = campaign
+	mock.selectPointValuesResult = map[string]float64{category: 3}
 
 	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
 	mock.priorScoreMsg = msgLowerCase
@@ -2061,6 +2758,262 @@ func TestProcessScoringMessageParticipant(t *testing.T) {
 
 	err := processScoringMessage(mock, now, msg)
 	assert.NoError(t, err)
+	assert.Equal(t, []types.LedgerEntry{
+		{
+			CampaignName:       campaign,
+			ScpName:            "someSCP",
+			LoginName:          "someLoginName",
+			RepoOwner:          db.TestOrgValid,
+			RepoName:           repoName,
+			PullRequest:        prId,
+			OldPoints:          2,
+			NewPoints:          6,
+			BugCounts:          map[string]interface{}{category: float64(2)},
+			ScoringMessage:     *msgLowerCase,
+			ResolvedCategories: map[string]string{category: category},
+			CreatedOn:          now,
+		},
+	}, insertLedgerEntries)
+	assert.Equal(t, 1, scoreTxCommitCount)
+	assert.Equal(t, 0, scoreTxRollbackCount)
+}
+
+func setupMockDBScoreParticipant(t *testing.T) (mock *MockBBashDB, participant *types.ParticipantStruct, msg *types.ScoringMessage) {
+	mock = newMockDb(t)
+	mock.assertParameters = false
+	participant = &types.ParticipantStruct{ID: "someId", CampaignName: campaign, ScpName: "GitHub", LoginName: loginName}
+	msg = &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, RepoName: "myRepoName",
+		TriggerUser: loginName, TotalFixed: 2, PullRequest: 7}
+	return
+}
+
+func TestScoreParticipantBeginError(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	forcedError := fmt.Errorf("forced begin error")
+	mock.beginScoreTxErr = forcedError
+
+	_, err := scoreParticipant(mock, now, participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, scoreTxCommitCount)
+	assert.Equal(t, 0, scoreTxRollbackCount)
+}
+
+func TestScoreParticipantPriorScoreError(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	forcedError := fmt.Errorf("forced prior score error")
+	mock.priorScoreErr = forcedError
+
+	_, err := scoreParticipant(mock, now, participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, scoreTxCommitCount)
+	assert.Equal(t, 1, scoreTxRollbackCount)
+	assert.Equal(t, float64(0), updateScoreLastDelta)
+}
+
+func TestScoreParticipantHistoryError(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	forcedError := fmt.Errorf("forced history error")
+	mock.selectHistoryErr = forcedError
+
+	_, err := scoreParticipant(mock, now, participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, scoreTxCommitCount)
+	assert.Equal(t, 1, scoreTxRollbackCount)
+	assert.Equal(t, float64(0), updateScoreLastDelta)
+}
+
+func TestScoreParticipantCommitError(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	forcedError := fmt.Errorf("forced commit error")
+	mock.commitScoreErr = forcedError
+
+	_, err := scoreParticipant(mock, now, participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 1, scoreTxCommitCount)
+	assert.Equal(t, 0, scoreTxRollbackCount)
+}
+
+func TestScoreParticipantWithoutSourceId(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+
+	scored, err := scoreParticipant(mock, now, participant, msg)
+	assert.NoError(t, err)
+	assert.True(t, scored)
+	assert.Nil(t, insertProcessedEventIds)
+}
+
+func TestScoreParticipantProcessedEventError(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	msg.SourceId = "myLogId"
+	forcedError := fmt.Errorf("forced processed event error")
+	mock.insertProcessedEventErr = forcedError
+
+	scored, err := scoreParticipant(mock, now, participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, scored)
+	assert.Equal(t, 1, scoreTxRollbackCount)
+}
+
+func TestScoreParticipantFirstTime(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	msg.SourceId = "myLogId"
+
+	scored, err := scoreParticipant(mock, now, participant, msg)
+	assert.NoError(t, err)
+	assert.True(t, scored)
+	assert.Equal(t, []string{"myLogId"}, insertProcessedEventIds)
+	assert.Equal(t, float64(2), updateScoreLastDelta)
+	assert.Equal(t, 1, scoreTxCommitCount)
+}
+
+func TestScoreParticipantDuplicate(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	msg.SourceId = "myLogId"
+	mock.insertProcessedEventDuplicate = true
+
+	scored, err := scoreParticipant(mock, now, participant, msg)
+	assert.NoError(t, err)
+	assert.False(t, scored)
+	assert.Equal(t, []string{"myLogId"}, insertProcessedEventIds)
+	assert.Equal(t, float64(0), updateScoreLastDelta)
+	assert.Nil(t, insertLedgerEntries)
+}
+
+func TestScoreParticipantPublishesDelta(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+
+	scored, err := scoreParticipant(mock, now, participant, msg)
+	assert.NoError(t, err)
+	assert.True(t, scored)
+	assert.Equal(t, types.LeaderboardDelta{CampaignName: campaign, ScpName: "GitHub", LoginName: loginName, OldScore: 0, Score: 2},
+		<-subscription.Deltas())
+}
+
+func TestScoreParticipantPublishesPrivateDelta(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	participant.Private = true
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+
+	_, err = scoreParticipant(mock, now, participant, msg)
+	assert.NoError(t, err)
+	delta := <-subscription.Deltas()
+	assert.Equal(t, types.LeaderboardDelta{CampaignName: campaign, OldScore: 0, Score: 2}, delta)
+	body, err := json.Marshal(delta)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"campaignName":"`+campaign+`","oldScore":0,"score":2}`, string(body))
+}
+
+func TestScoreParticipantPublishesNothingOnError(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	mock.commitScoreErr = fmt.Errorf("forced commit error")
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+
+	_, err = scoreParticipant(mock, now, participant, msg)
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(subscription.Deltas()))
+}
+
+func TestScoreParticipantPublishesNothingWhenDuplicate(t *testing.T) {
+	mock, participant, msg := setupMockDBScoreParticipant(t)
+	msg.SourceId = "myLogId"
+	mock.insertProcessedEventDuplicate = true
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+
+	scored, err := scoreParticipant(mock, now, participant, msg)
+	assert.NoError(t, err)
+	assert.False(t, scored)
+	assert.Equal(t, 0, len(subscription.Deltas()))
+}
+
+func TestScoreMessageDuplicateNotCounted(t *testing.T) {
+	mock := setupMockDBImport(t)
+	mock.insertProcessedEventDuplicate = true
+
+	scoredCount, err := scoreMessage(mock, now, &types.ScoringMessage{TriggerUser: "someone", SourceId: "myLogId"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, scoredCount)
+}
+
+func TestProcessedEventRetentionDefault(t *testing.T) {
+	origRetention := os.Getenv(envProcessedEventRetentionDays)
+	defer resetEnvVar(t, envProcessedEventRetentionDays, origRetention)
+	logger = zaptest.NewLogger(t)
+
+	assert.NoError(t, os.Unsetenv(envProcessedEventRetentionDays))
+	assert.Equal(t, 90*24*time.Hour, processedEventRetention())
+
+	assert.NoError(t, os.Setenv(envProcessedEventRetentionDays, "0"))
+	assert.Equal(t, 90*24*time.Hour, processedEventRetention())
+}
+
+func TestProcessedEventRetention(t *testing.T) {
+	origRetention := os.Getenv(envProcessedEventRetentionDays)
+	defer resetEnvVar(t, envProcessedEventRetentionDays, origRetention)
+	logger = zaptest.NewLogger(t)
+
+	assert.NoError(t, os.Setenv(envProcessedEventRetentionDays, "7"))
+	assert.Equal(t, 7*24*time.Hour, processedEventRetention())
+}
+
+func TestDeleteExpiredProcessedEventsError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.deleteProcessedEventsErr = fmt.Errorf("forced delete processed events error")
+
+	deleteExpiredProcessedEvents(now, time.Hour)
+	assert.Equal(t, []time.Time{now.Add(-time.Hour)}, deleteProcessedEventsBefore)
+}
+
+func TestStartProcessedEventCleanup(t *testing.T) {
+	newMockDb(t)
+
+	stop := startProcessedEventCleanup(time.Hour, time.Hour)
+	close(stop)
+	// the first cleanup runs right away
+	assert.Equal(t, 1, len(deleteProcessedEventsBefore))
+}
+
+func TestScoreParticipantFailureLeavesNoPartialScore(t *testing.T) {
+	_, participant, msg := setupMockDBScoreParticipant(t)
+
+	mock, dbFake, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced update score error")
+	// the scoring event is written before the participant update fails, so the whole transaction must be rolled back
+	db.SetupMockScoreTxUpdateScoreForcedError(mock, participant, msg, 1, 2, forcedError)
+
+	_, err := scoreParticipant(dbFake, now, participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessScoringMessageParticipantLedgerError(t *testing.T) {
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: "myRepoName", SourceId: "myLogId"}
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.validOrgResult = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: campaign,
+			ScpName:      "someSCP",
+			LoginName:    "someLoginName",
+		},
+	}
+	forcedError := fmt.Errorf("forced ledger error")
+	mock.insertLedgerErr = forcedError
+
+	scoredCount, err := scoreMessage(mock, now, msg)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, scoredCount)
+	assert.Equal(t, 1, len(insertLedgerEntries))
+	assert.Equal(t, "myLogId", insertLedgerEntries[0].SourceId)
 }
 
 func TestGetSourceControlProvidersQueryError(t *testing.T) {
@@ -2313,70 +3266,564 @@ func TestProcessScoringMessage(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestBeginLogPolling(t *testing.T) {
-	logger = zaptest.NewLogger(t)
-
-	_, sqlDb, closeDbFunc := db.SetupMockDB(t)
-	defer closeDbFunc()
-	// side effect: set up the postgresDB var
-	scoreDB = sqlDb
-
-	quit, errChan, err := beginLogPolling()
-	defer func() {
-		//close(quit)
-		//close(errChan)
-	}()
-	assert.NoError(t, err)
-	assert.NotNil(t, quit)
-	assert.NotNil(t, errChan)
-}
+func setupMockContextGitHubWebhook(t *testing.T, secret, eventType, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	origSecret := os.Getenv(envGitHubWebhookSecret)
+	t.Cleanup(func() {
+		resetEnvVar(t, envGitHubWebhookSecret, origSecret)
+	})
+	resetEnvVar(t, envGitHubWebhookSecret, secret)
 
-func closePollIfSet() {
-	if stopPoll != nil {
-		close(stopPoll)
-	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(webhook.HeaderEvent, eventType)
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(secret, []byte(body)))
+	return setupMockContextWithRequest(req)
 }
-func TestRestartPolling(t *testing.T) {
-	logger = zaptest.NewLogger(t)
 
-	_, sqlDb, closeDbFunc := db.SetupMockDB(t)
-	defer closeDbFunc()
-	// side effect: set up the postgresDB var
-	scoreDB = sqlDb
+const testWebhookSecret = "myWebhookSecret"
 
-	// fake stopPolling chan
-	closePollIfSet()
-	stopPoll = make(chan bool)
+func TestGitHubWebhookMissingSecret(t *testing.T) {
+	c, rec := setupMockContextGitHubWebhook(t, "", webhook.EventPing, "{}")
+	logger = zaptest.NewLogger(t)
 
-	err := restartPolling(nil)
-	assert.NoError(t, err)
-	assert.NotNil(t, stopPoll)
+	assert.NoError(t, githubWebhook(c))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
 }
 
-func TestStopPolling(t *testing.T) {
-	// fake stopPolling chan
-	closePollIfSet()
-	stopPoll = make(chan bool)
+func TestGitHubWebhookInvalidSignature(t *testing.T) {
+	c, rec := setupMockContextGitHubWebhook(t, testWebhookSecret, webhook.EventPing, "{}")
+	c.Request().Header.Set(webhook.HeaderSignature, webhook.Sign("wrongSecret", []byte("{}")))
+	logger = zaptest.NewLogger(t)
 
-	assert.NoError(t, stopPolling(nil))
-	assert.Nil(t, stopPoll)
-	// allow time for poll channel to finish logging during shutdown
-	time.Sleep(1 * time.Second)
+	assert.NoError(t, githubWebhook(c))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 }
 
-func TestSetPollDateEmptyBody(t *testing.T) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
+func TestGitHubWebhookUnsupportedEvent(t *testing.T) {
+	c, rec := setupMockContextGitHubWebhook(t, testWebhookSecret, "issues", "{}")
+	logger = zaptest.NewLogger(t)
 
-	assert.EqualError(t, setPollDate(c), "EOF")
+	assert.NoError(t, githubWebhook(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "unsupported github event type: issues", rec.Body.String())
 }
 
-func setupMockContextPollDate(t *testing.T, poll types.Poll) echo.Context {
+func TestGitHubWebhookPing(t *testing.T) {
+	c, rec := setupMockContextGitHubWebhook(t, testWebhookSecret, webhook.EventPing, `{"zen": "Practicality beats purity."}`)
 	logger = zaptest.NewLogger(t)
 
-	e := echo.New()
+	assert.NoError(t, githubWebhook(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestGitHubWebhookPullRequestScored(t *testing.T) {
+	body, err := os.ReadFile("internal/webhook/testdata/pull_request_closed_merged.json")
+	assert.NoError(t, err)
+	c, rec := setupMockContextGitHubWebhook(t, testWebhookSecret, webhook.EventPullRequest, string(body))
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.validOrgResult = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: campaign,
+			ScpName:      "GitHub",
+			LoginName:    "mygithubid",
+		},
+	}
+	mock.selectPointValuesResult = map[string]float64{"NullAway": 2, "G104": 2}
+	scoreDB = mock
+
+	c.Request().Header.Set(webhook.HeaderDelivery, "myDeliveryId")
+
+	assert.NoError(t, githubWebhook(c))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	// 2 NullAway + 1 G104 at 2 points each, no prior score
+	assert.Equal(t, float64(6), updateScoreLastDelta)
+	assert.Equal(t, 1, len(insertLedgerEntries))
+	assert.Equal(t, "myDeliveryId", insertLedgerEntries[0].SourceId)
+}
+
+func TestGitHubWebhookScoringError(t *testing.T) {
+	body, err := os.ReadFile("internal/webhook/testdata/check_run_completed.json")
+	assert.NoError(t, err)
+	c, _ := setupMockContextGitHubWebhook(t, testWebhookSecret, webhook.EventCheckRun, string(body))
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	forcedError := fmt.Errorf("forced webhook org error")
+	mock.validOrgErr = forcedError
+	scoreDB = mock
+
+	assert.EqualError(t, githubWebhook(c), forcedError.Error())
+}
+
+func setupMockDBImport(t *testing.T) (mock *MockBBashDB) {
+	mock = newMockDb(t)
+	mock.assertParameters = false
+	mock.validOrgResult = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: campaign,
+			ScpName:      "GitHub",
+			LoginName:    "someone",
+		},
+	}
+	scoreDB = mock
+	return
+}
+
+func TestImportScoringEventsInvalidAsOf(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/?asOf=yesterday", strings.NewReader(""))
+	c, rec := setupMockContextWithRequest(req)
+	setupMockDBImport(t)
+
+	assert.NoError(t, importScoringEvents(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": cannot parse "yesterday" as "2006"`, rec.Body.String())
+}
+
+func TestImportScoringEvents(t *testing.T) {
+	body := `{"eventSource": "github", "repositoryOwner": "myOrg", "triggerUser": "someone", "fixed-bugs": 1, "pullRequestId": 7}
+{bogus
+`
+	req := httptest.NewRequest(http.MethodPost, "/?asOf=2022-05-16T12:00:00Z", strings.NewReader(body))
+	c, rec := setupMockContextWithRequest(req)
+	setupMockDBImport(t)
+
+	assert.NoError(t, importScoringEvents(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var report types.ImportReport
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, 0, report.Skipped)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, float64(1), updateScoreLastDelta)
+}
+
+func TestImportScoringEventsSkipped(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"triggerUser": "stranger"}`))
+	c, rec := setupMockContextWithRequest(req)
+	mock := setupMockDBImport(t)
+	mock.partiesToScoreResult = nil
+
+	assert.NoError(t, importScoringEvents(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"accepted":0,"skipped":1,"failed":0,"results":[{"line":1,"status":"skipped"}]}`+"\n", rec.Body.String())
+}
+
+func TestRunImportCommandUsage(t *testing.T) {
+	var out strings.Builder
+	assert.EqualError(t, runImportCommand([]string{}, &out), "usage: bbash import [-as-of <RFC3339 time>] <file.jsonl>")
+}
+
+func TestRunImportCommandBadFlag(t *testing.T) {
+	var out strings.Builder
+	assert.EqualError(t, runImportCommand([]string{"-bogus", "file.jsonl"}, &out), "flag provided but not defined: -bogus")
+}
+
+func TestRunImportCommandBadAsOf(t *testing.T) {
+	var out strings.Builder
+	assert.Error(t, runImportCommand([]string{"-as-of", "yesterday", "file.jsonl"}, &out))
+}
+
+func TestRunImportCommandMissingFile(t *testing.T) {
+	var out strings.Builder
+	assert.EqualError(t, runImportCommand([]string{"no-such-file.jsonl"}, &out), "open no-such-file.jsonl: no such file or directory")
+}
+
+func TestRunImportCommand(t *testing.T) {
+	setupMockDBImport(t)
+
+	importFile := t.TempDir() + "/missed.jsonl"
+	assert.NoError(t, os.WriteFile(importFile, []byte(`{"triggerUser": "someone", "fixed-bugs": 2}`), 0600))
+
+	var out strings.Builder
+	assert.NoError(t, runImportCommand([]string{"-as-of", "2022-05-16T12:00:00Z", importFile}, &out))
+
+	var report types.ImportReport
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, float64(2), updateScoreLastDelta)
+}
+
+func TestBeginLogPolling(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	_, sqlDb, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	// side effect: set up the postgresDB var
+	scoreDB = sqlDb
+	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done, err := beginLogPolling(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, done)
+	cancel()
+	<-done
+}
+
+func TestBeginLogPollingUnknownSource(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	origSource := os.Getenv(envScoreSource)
+	defer resetEnvVar(t, envScoreSource, origSource)
+	assert.NoError(t, os.Setenv(envScoreSource, "carrierPigeon"))
+
+	done, err := beginLogPolling(context.Background())
+	assert.EqualError(t, err, "unknown score source: carrierPigeon")
+	assert.Nil(t, done)
+}
+
+func TestUseDatadogMappingNotSet(t *testing.T) {
+	origMappingFile := os.Getenv(envDDMappingFile)
+	defer resetEnvVar(t, envDDMappingFile, origMappingFile)
+	assert.NoError(t, os.Unsetenv(envDDMappingFile))
+
+	assert.NoError(t, useDatadogMapping())
+}
+
+func TestUseDatadogMappingMissingFile(t *testing.T) {
+	origMappingFile := os.Getenv(envDDMappingFile)
+	defer resetEnvVar(t, envDDMappingFile, origMappingFile)
+	assert.NoError(t, os.Setenv(envDDMappingFile, filepath.Join(t.TempDir(), "missing.yaml")))
+
+	assert.Error(t, useDatadogMapping())
+}
+
+func TestUseDatadogMapping(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	origMappingFile := os.Getenv(envDDMappingFile)
+	defer resetEnvVar(t, envDDMappingFile, origMappingFile)
+	mappingFile := filepath.Join(t.TempDir(), "mapping.yaml")
+	assert.NoError(t, ioutil.WriteFile(mappingFile, []byte(`query: "myQuery"`), 0600))
+	assert.NoError(t, os.Setenv(envDDMappingFile, mappingFile))
+	defer poll.UseMapping(poll.DefaultMapping())
+
+	assert.NoError(t, useDatadogMapping())
+
+	// an import of a raw datadog log export uses the mapping too
+	poll.UseMapping(poll.Mapping{Query: "myQuery", MessagePath: "myMessage"})
+	event, err := poll.ParseImportRecord([]byte(`{"id": "myLogId", "attributes": {"attributes": {"myMessage": {"triggerUser": "someone"}}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "someone", event.ScoringMessage.TriggerUser)
+}
+
+func TestNewScoreSourceDefault(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	origSource := os.Getenv(envScoreSource)
+	defer resetEnvVar(t, envScoreSource, origSource)
+	assert.NoError(t, os.Unsetenv(envScoreSource))
+
+	source, err := newScoreSource()
+	assert.NoError(t, err)
+	assert.IsType(t, &poll.DatadogSource{}, source)
+}
+
+func TestNewScoreSourceJsonlMissingFile(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	origSource := os.Getenv(envScoreSource)
+	defer resetEnvVar(t, envScoreSource, origSource)
+	assert.NoError(t, os.Setenv(envScoreSource, poll.SourceJsonl))
+	origSourceFile := os.Getenv(envScoreSourceFile)
+	defer resetEnvVar(t, envScoreSourceFile, origSourceFile)
+	assert.NoError(t, os.Unsetenv(envScoreSourceFile))
+
+	source, err := newScoreSource()
+	assert.EqualError(t, err, "score source jsonl requires env var SCORE_SOURCE_FILE")
+	assert.Nil(t, source)
+}
+
+func TestNewScoreSourceJsonl(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	origSource := os.Getenv(envScoreSource)
+	defer resetEnvVar(t, envScoreSource, origSource)
+	assert.NoError(t, os.Setenv(envScoreSource, poll.SourceJsonl))
+	origSourceFile := os.Getenv(envScoreSourceFile)
+	defer resetEnvVar(t, envScoreSourceFile, origSourceFile)
+	assert.NoError(t, os.Setenv(envScoreSourceFile, "scores.jsonl"))
+
+	source, err := newScoreSource()
+	assert.NoError(t, err)
+	assert.IsType(t, &poll.FileSource{}, source)
+}
+
+type mockPollManager struct {
+	restartErr   error
+	stopErr      error
+	stopDelay    time.Duration
+	startCount   int
+	stopCount    int
+	restartCount int
+	status       types.PollStatus
+}
+
+var _ poll.IManager = (*mockPollManager)(nil)
+
+func (m *mockPollManager) Start() (err error) {
+	m.startCount++
+	return
+}
+
+func (m *mockPollManager) Stop() (lastPollErr error) {
+	time.Sleep(m.stopDelay)
+	m.stopCount++
+	return m.stopErr
+}
+
+func (m *mockPollManager) Restart() (err error) {
+	m.restartCount++
+	return m.restartErr
+}
+
+func (m *mockPollManager) Status() types.PollStatus {
+	return m.status
+}
+
+// useMockPollManager should always be followed by a deferred call to the restore func
+func useMockPollManager(mock *mockPollManager) (restore func()) {
+	origPollManager := pollManager
+	pollManager = mock
+	return func() {
+		pollManager = origPollManager
+	}
+}
+
+func TestRestartPollingError(t *testing.T) {
+	forcedError := fmt.Errorf("forced restart error")
+	mock := &mockPollManager{restartErr: forcedError}
+	defer useMockPollManager(mock)()
+
+	assert.EqualError(t, restartPolling(nil), forcedError.Error())
+	assert.Equal(t, 1, mock.restartCount)
+}
+
+func TestRestartPolling(t *testing.T) {
+	mock := &mockPollManager{}
+	defer useMockPollManager(mock)()
+
+	assert.NoError(t, restartPolling(nil))
+	assert.Equal(t, 1, mock.restartCount)
+}
+
+func TestStopPolling(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := &mockPollManager{stopErr: fmt.Errorf("forced last poll error")}
+	defer useMockPollManager(mock)()
+
+	assert.NoError(t, stopPolling(nil))
+	assert.NoError(t, stopPolling(nil))
+	assert.Equal(t, 2, mock.stopCount)
+}
+
+func TestPollManagerRestartAndStop(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	_, sqlDb, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	// side effect: set up the postgresDB var
+	scoreDB = sqlDb
+	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+
+	defer useMockPollManager(nil)()
+	pollManager = poll.NewManager(beginLogPolling)
+
+	assert.NoError(t, restartPolling(nil))
+	assert.True(t, pollManager.Status().Running)
+	assert.NoError(t, restartPolling(nil))
+	assert.True(t, pollManager.Status().Running)
+	assert.NoError(t, stopPolling(nil))
+	assert.False(t, pollManager.Status().Running)
+	// stopping again is harmless
+	assert.NoError(t, stopPolling(nil))
+}
+
+func TestShutdownTimeoutDefault(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	origTimeout := os.Getenv(envShutdownTimeoutSeconds)
+	defer resetEnvVar(t, envShutdownTimeoutSeconds, origTimeout)
+	assert.NoError(t, os.Setenv(envShutdownTimeoutSeconds, "bogus"))
+
+	assert.Equal(t, defaultShutdownTimeoutSeconds*time.Second, shutdownTimeout())
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	origTimeout := os.Getenv(envShutdownTimeoutSeconds)
+	defer resetEnvVar(t, envShutdownTimeoutSeconds, origTimeout)
+	assert.NoError(t, os.Setenv(envShutdownTimeoutSeconds, "5"))
+
+	assert.Equal(t, 5*time.Second, shutdownTimeout())
+}
+
+func TestShutdownStopsPolling(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := &mockPollManager{stopErr: fmt.Errorf("forced last poll error")}
+	defer useMockPollManager(mock)()
+
+	assert.NoError(t, shutdown(echo.New(), time.Second))
+	assert.Equal(t, 1, mock.stopCount)
+}
+
+func TestShutdownEndsLeaderboardStreams(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer useMockPollManager(&mockPollManager{})()
+	leaderboardBroker = leaderboard.NewBroker(1)
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+
+	assert.NoError(t, shutdown(echo.New(), time.Second))
+	_, open := <-subscription.Deltas()
+	assert.False(t, open)
+}
+
+func TestStreamMaxConnectionsDefault(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	origMax := os.Getenv(envStreamMaxConnections)
+	defer resetEnvVar(t, envStreamMaxConnections, origMax)
+	assert.NoError(t, os.Setenv(envStreamMaxConnections, "0"))
+
+	assert.Equal(t, defaultStreamMaxConnections, streamMaxConnections())
+}
+
+func TestStreamMaxConnections(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	origMax := os.Getenv(envStreamMaxConnections)
+	defer resetEnvVar(t, envStreamMaxConnections, origMax)
+	assert.NoError(t, os.Setenv(envStreamMaxConnections, "20"))
+
+	assert.Equal(t, 20, streamMaxConnections())
+}
+
+func TestShutdownPollStopTimeout(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer useMockPollManager(&mockPollManager{stopDelay: time.Second})()
+
+	assert.EqualError(t, shutdown(echo.New(), 10*time.Millisecond),
+		"timed out waiting for polling to stop: context deadline exceeded")
+}
+
+func TestServeStartError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := &mockPollManager{}
+	defer useMockPollManager(mock)()
+
+	e := echo.New()
+	e.HideBanner = true
+	err := serve(context.Background(), e, "bogus:-1", time.Second)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "listen tcp"), err.Error())
+	assert.Equal(t, 1, mock.stopCount)
+}
+
+// startServing runs serve in the background, and waits for the server to listen
+func startServing(t *testing.T, ctx context.Context, e *echo.Echo) (url string, served chan error) {
+	e.HideBanner = true
+	e.HidePort = true
+	served = make(chan error, 1)
+	go func() {
+		served <- serve(ctx, e, "127.0.0.1:0", 5*time.Second)
+	}()
+	for i := 0; i < 100 && e.ListenerAddr() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !assert.NotNil(t, e.ListenerAddr()) {
+		t.FailNow()
+	}
+	url = "http://" + e.ListenerAddr().String()
+	return
+}
+
+func TestServeShutdownDrainsInFlightRequest(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := &mockPollManager{}
+	defer useMockPollManager(mock)()
+
+	e := echo.New()
+	requestStarted := make(chan bool)
+	e.GET("/slow", func(c echo.Context) error {
+		close(requestStarted)
+		time.Sleep(200 * time.Millisecond)
+		return c.String(http.StatusOK, "finished")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, served := startServing(t, ctx, e)
+
+	type response struct {
+		code int
+		body string
+		err  error
+	}
+	responseChan := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			responseChan <- response{err: err}
+			return
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		body, err := ioutil.ReadAll(resp.Body)
+		responseChan <- response{code: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-requestStarted
+	cancel()
+
+	assert.NoError(t, <-served)
+	resp := <-responseChan
+	assert.NoError(t, resp.err)
+	assert.Equal(t, http.StatusOK, resp.code)
+	assert.Equal(t, "finished", resp.body)
+	assert.Equal(t, 1, mock.stopCount)
+}
+
+func TestServeShutdownOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupt signal can not be sent on windows")
+	}
+	logger = zaptest.NewLogger(t)
+	mock := &mockPollManager{}
+	defer useMockPollManager(mock)()
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	_, served := startServing(t, ctx, echo.New())
+
+	process, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, process.Signal(syscall.SIGTERM))
+
+	select {
+	case err = <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "server did not shut down on signal")
+	}
+	assert.Equal(t, 1, mock.stopCount)
+}
+
+func TestSetPollDateEmptyBody(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	assert.EqualError(t, setPollDate(c), "EOF")
+}
+
+func setupMockContextPollDate(t *testing.T, poll types.Poll) echo.Context {
+	logger = zaptest.NewLogger(t)
+
+	e := echo.New()
 
 	bodyBytes, err := json.Marshal(poll)
 	assert.NoError(t, err)
@@ -2430,3 +3877,515 @@ func TestSetPollDate(t *testing.T) {
 	err := setPollDate(c)
 	assert.NoError(t, err)
 }
+
+func setupMockPollDB(t *testing.T) (mock sqlmock.Sqlmock, closeDbFunc func()) {
+	logger = zaptest.NewLogger(t)
+	var dbFake *db.BBashDB
+	mock, dbFake, closeDbFunc = db.SetupMockDB(t)
+	scoreDB = dbFake
+	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+	return
+}
+
+func TestGetPollStatusSelectError(t *testing.T) {
+	c, _ := setupMockContext()
+	mock, closeDbFunc := setupMockPollDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select poll error")
+	db.SetupMockPollSelectForcedError(mock, forcedError, "1")
+
+	assert.EqualError(t, getPollStatus(c), forcedError.Error())
+}
+
+func TestGetPollStatus(t *testing.T) {
+	c, rec := setupMockContext()
+	mock, closeDbFunc := setupMockPollDB(t)
+	defer closeDbFunc()
+	defer useMockPollManager(&mockPollManager{status: types.PollStatus{Running: true, IntervalSeconds: 60}})()
+
+	lastPolled := time.Now().Add(-time.Minute).Truncate(time.Second)
+	db.SetupMockPollSelect(mock, "1", lastPolled)
+
+	assert.NoError(t, getPollStatus(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var pollStatus types.PollStatus
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pollStatus))
+	assert.True(t, lastPolled.Equal(pollStatus.LastPolled))
+	assert.True(t, lastPolled.Add(time.Second*2).Equal(pollStatus.LastPollCompleted))
+	assert.True(t, pollStatus.LagSeconds >= 60, pollStatus.LagSeconds)
+	assert.False(t, pollStatus.Stale)
+}
+
+func TestGetHealthNotPolling(t *testing.T) {
+	c, rec := setupMockContext()
+
+	assert.NoError(t, getHealth("myBuildInfo")(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "I am ALIVE. myBuildInfo", rec.Body.String())
+}
+
+func TestGetHealthPollError(t *testing.T) {
+	c, rec := setupMockContext()
+	mock, closeDbFunc := setupMockPollDB(t)
+	defer closeDbFunc()
+	defer useMockPollManager(&mockPollManager{status: types.PollStatus{Running: true, IntervalSeconds: 60}})()
+
+	db.SetupMockPollSelectForcedError(mock, fmt.Errorf("forced select poll error"), "1")
+
+	assert.NoError(t, getHealth("myBuildInfo")(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "I am DEGRADED, poll status error: forced select poll error. myBuildInfo", rec.Body.String())
+}
+
+func TestGetHealthPollStale(t *testing.T) {
+	c, rec := setupMockContext()
+	mock, closeDbFunc := setupMockPollDB(t)
+	defer closeDbFunc()
+	defer useMockPollManager(&mockPollManager{status: types.PollStatus{Running: true, IntervalSeconds: 60}})()
+
+	lastPolled := time.Now().Add(-time.Hour)
+	db.SetupMockPollSelect(mock, "1", lastPolled)
+
+	assert.NoError(t, getHealth("myBuildInfo")(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, fmt.Sprintf("I am DEGRADED, polling is stale, last poll completed: %s. myBuildInfo",
+		lastPolled.Add(time.Second*2).Format(time.RFC3339)), rec.Body.String())
+}
+
+func TestGetHealthPollCurrent(t *testing.T) {
+	c, rec := setupMockContext()
+	mock, closeDbFunc := setupMockPollDB(t)
+	defer closeDbFunc()
+	defer useMockPollManager(&mockPollManager{status: types.PollStatus{Running: true, IntervalSeconds: 60}})()
+
+	db.SetupMockPollSelect(mock, "1", time.Now())
+
+	assert.NoError(t, getHealth("myBuildInfo")(c))
+	assert.Equal(t, "I am ALIVE. myBuildInfo", rec.Body.String())
+}
+
+func setupMockContextRecompute(campaignName, reprice string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/?"+qpReprice+"="+reprice, nil)
+	c, rec = setupMockContextWithRequest(req)
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaignName)
+	return
+}
+
+func TestRecomputeCampaignScoresMissingCampaign(t *testing.T) {
+	c, rec := setupMockContextRecompute(" ", "")
+	newMockDb(t)
+
+	assert.NoError(t, recomputeCampaignScores(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid parameter campaignName: ", rec.Body.String())
+}
+
+func TestRecomputeCampaignScoresInvalidReprice(t *testing.T) {
+	c, rec := setupMockContextRecompute(campaign, "bogus")
+	newMockDb(t)
+
+	assert.NoError(t, recomputeCampaignScores(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `strconv.ParseBool: parsing "bogus": invalid syntax`, rec.Body.String())
+}
+
+func TestRecomputeCampaignScoresError(t *testing.T) {
+	c, _ := setupMockContextRecompute(campaign, "")
+	mock := newMockDb(t)
+	mock.recomputeCampaignName = campaign
+	forcedError := fmt.Errorf("forced recompute error")
+	mock.recomputeErr = forcedError
+
+	assert.EqualError(t, recomputeCampaignScores(c), forcedError.Error())
+}
+
+func TestRecomputeCampaignScores(t *testing.T) {
+	c, rec := setupMockContextRecompute(campaign, "false")
+	mock := newMockDb(t)
+	mock.recomputeCampaignName = campaign
+	mock.recomputeResult = &types.RecomputeReport{
+		CampaignName: campaign,
+		Changes:      []types.ScoreChange{{ScpName: scpName, LoginName: loginName, OldScore: 3, NewScore: 5}},
+	}
+
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+
+	assert.NoError(t, recomputeCampaignScores(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"campaignName":"`+campaign+`","repricedEvents":0,"changes":[{"scpName":"`+scpName+`","loginName":"`+loginName+`","private":false,"oldScore":3,"newScore":5}]}`+"\n", rec.Body.String())
+	assert.Equal(t, types.LeaderboardDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, OldScore: 3, Score: 5},
+		<-subscription.Deltas())
+}
+
+func TestRecomputeCampaignScoresPrivateParticipant(t *testing.T) {
+	c, _ := setupMockContextRecompute(campaign, "false")
+	mock := newMockDb(t)
+	mock.recomputeCampaignName = campaign
+	mock.recomputeResult = &types.RecomputeReport{
+		CampaignName: campaign,
+		Changes:      []types.ScoreChange{{ScpName: scpName, LoginName: loginName, Private: true, OldScore: 3, NewScore: 5}},
+	}
+
+	subscription, err := leaderboardBroker.Subscribe(campaign)
+	assert.NoError(t, err)
+
+	assert.NoError(t, recomputeCampaignScores(c))
+	assert.Equal(t, types.LeaderboardDelta{CampaignName: campaign, OldScore: 3, Score: 5}, <-subscription.Deltas())
+}
+
+func TestRecomputeCampaignScoresReprice(t *testing.T) {
+	c, rec := setupMockContextRecompute(campaign, "true")
+	mock := newMockDb(t)
+	mock.recomputeCampaignName = campaign
+	mock.recomputeReprice = true
+	mock.recomputeResult = &types.RecomputeReport{CampaignName: campaign, RepricedEvents: 2}
+
+	assert.NoError(t, recomputeCampaignScores(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"campaignName":"`+campaign+`","repricedEvents":2,"changes":null}`+"\n", rec.Body.String())
+}
+
+func setupMockContextScoringRules(campaignName, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	c, rec = setupMockContextWithRequest(req)
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaignName)
+	return
+}
+
+func TestGetScoringRulesCampaignNotFound(t *testing.T) {
+	c, rec := setupMockContextScoringRules(campaign, "")
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{}
+
+	assert.NoError(t, getScoringRules(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetScoringRulesError(t *testing.T) {
+	c, _ := setupMockContextScoringRules(campaign, "")
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: "campaignId"}
+	forcedError := fmt.Errorf("forced select rules error")
+	mock.selectRulesErr = forcedError
+
+	assert.EqualError(t, getScoringRules(c), forcedError.Error())
+}
+
+func TestGetScoringRulesDefault(t *testing.T) {
+	c, rec := setupMockContextScoringRules(campaign, "")
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: "campaignId"}
+
+	assert.NoError(t, getScoringRules(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"unclassifiedPoints":1,"multipliers":null,"pullRequestCap":0,"dailyCap":0,"firstFixBonus":0,"zeroedCategories":null}`+"\n", rec.Body.String())
+}
+
+func TestPutScoringRulesInvalidBody(t *testing.T) {
+	c, rec := setupMockContextScoringRules(campaign, "{bogus")
+	newMockDb(t)
+
+	assert.NoError(t, putScoringRules(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestPutScoringRulesInvalidRules(t *testing.T) {
+	c, rec := setupMockContextScoringRules(campaign, `{"dailyCap": -1}`)
+	newMockDb(t)
+
+	assert.NoError(t, putScoringRules(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "dailyCap must not be negative: -1", rec.Body.String())
+}
+
+func TestPutScoringRulesInvalidPrecision(t *testing.T) {
+	c, rec := setupMockContextScoringRules(campaign, `{"unclassifiedPoints": 0.125}`)
+	newMockDb(t)
+
+	assert.NoError(t, putScoringRules(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "unclassifiedPoints must not have more than 2 decimal places: 0.125", rec.Body.String())
+}
+
+func TestPutScoringRulesError(t *testing.T) {
+	c, _ := setupMockContextScoringRules(campaign, `{}`)
+	mock := newMockDb(t)
+	mock.upsertRulesCampaign = campaign
+	mock.upsertRules = &types.ScoringRules{UnclassifiedPoints: 1}
+	forcedError := fmt.Errorf("forced upsert rules error")
+	mock.upsertRulesErr = forcedError
+
+	assert.EqualError(t, putScoringRules(c), forcedError.Error())
+}
+
+func TestPutScoringRulesCampaignNotFound(t *testing.T) {
+	c, rec := setupMockContextScoringRules(campaign, `{}`)
+	mock := newMockDb(t)
+	mock.upsertRulesCampaign = campaign
+	mock.upsertRules = &types.ScoringRules{UnclassifiedPoints: 1}
+
+	assert.NoError(t, putScoringRules(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPutScoringRules(t *testing.T) {
+	c, rec := setupMockContextScoringRules(campaign, `{"dailyCap": 50, "multipliers": [{"repositoryOwner": "myOrg", "factor": 2}],
+		"zeroedCategories": ["ShellCheck"]}`)
+	mock := newMockDb(t)
+	mock.upsertRulesCampaign = campaign
+	mock.upsertRules = &types.ScoringRules{
+		UnclassifiedPoints: 1,
+		DailyCap:           50,
+		Multipliers:        []types.ScoringMultiplier{{RepoOwner: "myOrg", Factor: 2}},
+		ZeroedCategories:   []string{"ShellCheck"},
+	}
+	mock.upsertRulesRowsAffected = 1
+
+	assert.NoError(t, putScoringRules(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"unclassifiedPoints":1,"multipliers":[{"repositoryOwner":"myOrg","factor":2}],"pullRequestCap":0,"dailyCap":50,"firstFixBonus":0,"zeroedCategories":["ShellCheck"]}`+"\n", rec.Body.String())
+}
+
+func setupMockContextCampaignStats(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	c, rec = setupMockContext()
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaignName)
+	return
+}
+
+func setupMockCampaignStats(t *testing.T) (mock *MockBBashDB) {
+	mock = newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: "campaignId"}
+	mock.selectCampaignScoredPRsCampaignName = campaign
+	mock.selectCampaignDaysCampaignName = campaign
+	mock.selectPointValuesCampaign = campaign
+	mock.countParticipantsCampaignName = campaign
+	return
+}
+
+func TestGetCampaignStatsCampaignError(t *testing.T) {
+	c, _ := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	forcedError := fmt.Errorf("forced get campaign error")
+	mock.getCampaignErr = forcedError
+
+	assert.EqualError(t, getCampaignStats(c), forcedError.Error())
+}
+
+func TestGetCampaignStatsCampaignNotFound(t *testing.T) {
+	c, rec := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	mock.getCampaignResult = &types.CampaignStruct{}
+
+	assert.NoError(t, getCampaignStats(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Campaign not found", rec.Body.String())
+}
+
+func TestGetCampaignStatsSelectError(t *testing.T) {
+	c, _ := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	forcedError := fmt.Errorf("forced select campaign scored pull requests error")
+	mock.selectCampaignScoredPRsErr = forcedError
+
+	assert.EqualError(t, getCampaignStats(c), forcedError.Error())
+}
+
+func TestGetCampaignStatsDaysError(t *testing.T) {
+	c, _ := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	forcedError := fmt.Errorf("forced select campaign daily scores error")
+	mock.selectCampaignDaysErr = forcedError
+
+	assert.EqualError(t, getCampaignStats(c), forcedError.Error())
+}
+
+func TestGetCampaignStatsPointValuesError(t *testing.T) {
+	c, _ := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	forcedError := fmt.Errorf("forced select point values error")
+	mock.selectPointValuesErr = forcedError
+
+	assert.EqualError(t, getCampaignStats(c), forcedError.Error())
+}
+
+func TestGetCampaignStatsRulesError(t *testing.T) {
+	c, _ := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	forcedError := fmt.Errorf("forced select scoring rules error")
+	mock.selectRulesErr = forcedError
+
+	assert.EqualError(t, getCampaignStats(c), forcedError.Error())
+}
+
+func TestGetCampaignStatsCountError(t *testing.T) {
+	c, _ := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	forcedError := fmt.Errorf("forced count participants error")
+	mock.countParticipantsErr = forcedError
+
+	assert.EqualError(t, getCampaignStats(c), forcedError.Error())
+}
+
+func TestGetCampaignStats(t *testing.T) {
+	c, rec := setupMockContextCampaignStats(campaign)
+	mock := setupMockCampaignStats(t)
+	scoredOn := time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC)
+	mock.selectCampaignScoredPRsResult = []types.ScoredPullRequest{
+		{RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, Points: 4,
+			BugCounts: map[string]interface{}{"ShellCheck": float64(2), "unpriced": float64(1)}, ScoredOn: &scoredOn},
+	}
+	mock.selectCampaignDaysResult = []types.DayScore{
+		{Day: "2022-05-16", PullRequests: 1, Points: 5},
+		{Day: "2022-05-18", PullRequests: 1, Points: -1},
+	}
+	mock.selectPointValuesResult = map[string]float64{"ShellCheck": 1.5}
+	mock.selectRulesResult = &types.ScoringRules{UnclassifiedPoints: 0.5}
+	mock.countParticipantsRegistered = 3
+	mock.countParticipantsActive = 1
+
+	assert.NoError(t, getCampaignStats(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"campaignName":"`+campaign+`","pullRequests":1,"bugsFixed":3,"points":4,"registeredParticipants":3,"activeParticipants":1,`+
+		`"categories":[{"category":"ShellCheck","fixed":2,"nominalPoints":3},{"category":"unpriced","fixed":1,"nominalPoints":0.5}],`+
+		`"topRepositories":[{"repositoryOwner":"myOrg","repositoryName":"myRepo","pullRequests":1,"points":4}],`+
+		`"topOrganizations":[{"organization":"myOrg","pullRequests":1,"points":4}],`+
+		`"days":[{"day":"2022-05-16","pullRequests":1,"points":5},{"day":"2022-05-17","pullRequests":0,"points":0},`+
+		`{"day":"2022-05-18","pullRequests":1,"points":-1}]}`+"\n", rec.Body.String())
+}
+
+func setupMockContextDeadLetter(id string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	c, rec = setupMockContext()
+	c.SetParamNames(ParamDeadLetterId)
+	c.SetParamValues(id)
+	return
+}
+
+const testDeadLetterId = "myDeadLetterId"
+
+func TestGetDeadLettersError(t *testing.T) {
+	c, _ := setupMockContext()
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced select dead letters error")
+	mock.selectDeadLettersErr = forcedError
+
+	assert.EqualError(t, getDeadLetters(c), forcedError.Error())
+}
+
+func TestGetDeadLetters(t *testing.T) {
+	c, rec := setupMockContext()
+	mock := newMockDb(t)
+	mock.selectDeadLettersResult = []types.DeadLetter{{Id: testDeadLetterId, SourceId: "myLogId", Payload: "{bogus", Error: "bad", Attempts: 2}}
+
+	assert.NoError(t, getDeadLetters(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `[{"guid":"myDeadLetterId","sourceId":"myLogId","payload":"{bogus","error":"bad","attempts":2,`), rec.Body.String())
+}
+
+func TestRetryDeadLetterNotFound(t *testing.T) {
+	c, rec := setupMockContextDeadLetter(testDeadLetterId)
+	mock := newMockDb(t)
+	mock.selectDeadLetterId = testDeadLetterId
+	mock.selectDeadLetterErr = sql.ErrNoRows
+
+	assert.NoError(t, retryDeadLetter(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `"no dead letter: myDeadLetterId"`+"\n", rec.Body.String())
+}
+
+func TestRetryDeadLetterSelectError(t *testing.T) {
+	c, _ := setupMockContextDeadLetter(testDeadLetterId)
+	mock := newMockDb(t)
+	mock.selectDeadLetterId = testDeadLetterId
+	forcedError := fmt.Errorf("forced select dead letter error")
+	mock.selectDeadLetterErr = forcedError
+
+	assert.EqualError(t, retryDeadLetter(c), forcedError.Error())
+}
+
+func TestRetryDeadLetterFailsAgain(t *testing.T) {
+	c, rec := setupMockContextDeadLetter(testDeadLetterId)
+	mock := newMockDb(t)
+	mock.selectDeadLetterId = testDeadLetterId
+	mock.selectDeadLetterResult = &types.DeadLetter{Id: testDeadLetterId, Payload: "{bogus", Error: "bad", Attempts: 1}
+	mock.updateDeadLetterId = testDeadLetterId
+	mock.updateDeadLetterAttemptErr = "invalid character 'b' looking for beginning of object key string"
+
+	assert.NoError(t, retryDeadLetter(c))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), `"error":"invalid character 'b' looking for beginning of object key string","attempts":2`), rec.Body.String())
+}
+
+func TestRetryDeadLetterUpdateAttemptError(t *testing.T) {
+	c, _ := setupMockContextDeadLetter(testDeadLetterId)
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.selectDeadLetterResult = &types.DeadLetter{Id: testDeadLetterId, Payload: "{bogus"}
+	forcedError := fmt.Errorf("forced update dead letter error")
+	mock.updateDeadLetterErr = forcedError
+
+	assert.EqualError(t, retryDeadLetter(c), forcedError.Error())
+}
+
+func TestRetryDeadLetter(t *testing.T) {
+	c, rec := setupMockContextDeadLetter(testDeadLetterId)
+	mock := setupMockDBImport(t)
+	mock.selectDeadLetterResult = &types.DeadLetter{
+		Id:       testDeadLetterId,
+		SourceId: "myLogId",
+		Payload:  `{"id": "myLogId", "baseTime": "2022-05-16T10:00:00Z", "scoringMessage": {"triggerUser": "someone", "fixed-bugs": 3}}`,
+	}
+	mock.deleteDeadLetterRowsAffected = 1
+
+	assert.NoError(t, retryDeadLetter(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, float64(3), updateScoreLastDelta)
+	assert.Equal(t, []string{"myLogId"}, insertProcessedEventIds)
+}
+
+func TestRetryDeadLetterDeleteError(t *testing.T) {
+	c, _ := setupMockContextDeadLetter(testDeadLetterId)
+	mock := setupMockDBImport(t)
+	mock.selectDeadLetterResult = &types.DeadLetter{Id: testDeadLetterId, Payload: `{"triggerUser": "someone"}`}
+	forcedError := fmt.Errorf("forced delete dead letter error")
+	mock.deleteDeadLetterErr = forcedError
+
+	assert.EqualError(t, retryDeadLetter(c), forcedError.Error())
+}
+
+func TestDeleteDeadLetterError(t *testing.T) {
+	c, _ := setupMockContextDeadLetter(testDeadLetterId)
+	mock := newMockDb(t)
+	mock.deleteDeadLetterId = testDeadLetterId
+	forcedError := fmt.Errorf("forced delete dead letter error")
+	mock.deleteDeadLetterErr = forcedError
+
+	assert.EqualError(t, deleteDeadLetter(c), forcedError.Error())
+}
+
+func TestDeleteDeadLetterNotFound(t *testing.T) {
+	c, rec := setupMockContextDeadLetter(testDeadLetterId)
+	mock := newMockDb(t)
+	mock.deleteDeadLetterId = testDeadLetterId
+
+	assert.NoError(t, deleteDeadLetter(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDeleteDeadLetter(t *testing.T) {
+	c, rec := setupMockContextDeadLetter(testDeadLetterId)
+	mock := newMockDb(t)
+	mock.deleteDeadLetterId = testDeadLetterId
+	mock.deleteDeadLetterRowsAffected = 1
+
+	assert.NoError(t, deleteDeadLetter(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
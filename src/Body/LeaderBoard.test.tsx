@@ -13,12 +13,12 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-import {render} from '@testing-library/react';
+import {fireEvent, render} from '@testing-library/react';
 import React from 'react';
 import {ClientContextProvider, createClient} from 'react-fetching-library';
 import fetchMock from "fetch-mock-jest";
 
-import LeaderBoard from './LeaderBoard';
+import LeaderBoard, {applyScoreDelta, formatScores, leaderboardPageSize, Participant, ScoreDelta} from './LeaderBoard';
 import {Campaign, qp} from "./CampaignSelect";
 import {MockResponseObject} from "fetch-mock";
 
@@ -26,6 +26,9 @@ beforeEach(() => {
     fetchMock.reset()
 })
 
+const leadersUrl = (offset: number, caller: string) =>
+    `/participant/list/${selectedCampaign.name}?limit=${leaderboardPageSize}&offset=${offset}&${qp.feature}=getLeaders&${qp.call}=${caller}`;
+
 const selectedCampaign: Campaign = {
     guid: "myCampaignGuid",
     name: "myCampaignName",
@@ -55,12 +58,93 @@ describe("<LeaderBoard></LeaderBoard>", () => {
         expect(await findByText("Refresh Scores")).toBeTruthy()
     });
 
+    test("Should show fractional scores with two decimal places", async () => {
+        fetchMock.get(leadersUrl(0, "useEffect"),
+            [
+                {loginName: "fractionalUser", score: 12.5},
+                {loginName: "wholeUser", score: 3},
+            ]
+        );
+
+        const client = createClient({});
+        const {findByText} = render(
+            <ClientContextProvider client={client}>
+                <LeaderBoard selectedCampaign={selectedCampaign}/>
+            </ClientContextProvider>
+        );
+
+        expect(await findByText("12.50")).toBeTruthy()
+        expect(await findByText("3.00")).toBeTruthy()
+    });
+
+    test("Should show the display name of a private participant", async () => {
+        fetchMock.get(leadersUrl(0, "useEffect"),
+            [
+                {loginName: "publicUser", displayName: "Public User", score: 5},
+                {displayName: "Private User", score: 3},
+            ]
+        );
+
+        const client = createClient({});
+        const {findByText} = render(
+            <ClientContextProvider client={client}>
+                <LeaderBoard selectedCampaign={selectedCampaign}/>
+            </ClientContextProvider>
+        );
+
+        expect(await findByText("publicUser")).toBeTruthy()
+        expect(await findByText("Private User")).toBeTruthy()
+    });
+
+    test("Should page through the participants", async () => {
+        fetchMock.get(leadersUrl(0, "useEffect"), {
+            body: [{loginName: "firstPageUser", score: 5, rank: 1}],
+            headers: {"X-Total-Count": String(leaderboardPageSize + 1)},
+        });
+        fetchMock.get(leadersUrl(leaderboardPageSize, "useEffect"), {
+            body: [{loginName: "secondPageUser", score: 3, rank: 2}],
+            headers: {"X-Total-Count": String(leaderboardPageSize + 1)},
+        });
+
+        const client = createClient({});
+        const {findByText} = render(
+            <ClientContextProvider client={client}>
+                <LeaderBoard selectedCampaign={selectedCampaign}/>
+            </ClientContextProvider>
+        );
+
+        expect(await findByText("firstPageUser")).toBeTruthy()
+        expect(await findByText(`Participants 1 - ${leaderboardPageSize} of ${leaderboardPageSize + 1}`)).toBeTruthy()
+
+        fireEvent.click(await findByText("Next"))
+
+        expect(await findByText("secondPageUser")).toBeTruthy()
+        expect(await findByText(`Participants ${leaderboardPageSize + 1} - ${leaderboardPageSize + 1} of ${leaderboardPageSize + 1}`)).toBeTruthy()
+    });
+
+    test("Should not page a single page of participants", async () => {
+        fetchMock.get(leadersUrl(0, "useEffect"), {
+            body: [{loginName: "onlyUser", score: 5, rank: 1}],
+            headers: {"X-Total-Count": "1"},
+        });
+
+        const client = createClient({});
+        const {findByText, queryByText} = render(
+            <ClientContextProvider client={client}>
+                <LeaderBoard selectedCampaign={selectedCampaign}/>
+            </ClientContextProvider>
+        );
+
+        expect(await findByText("onlyUser")).toBeTruthy()
+        expect(queryByText("Next")).toBeNull()
+    });
+
     test("Should show error if failure reading participant list", async () => {
         let myError = new Error("forced fetch error");
         let mockResponse: MockResponseObject = {
             throws: myError,
         }
-        fetchMock.get(`/participant/list/${selectedCampaign.name}?${qp.feature}=getLeaders&${qp.call}=useEffect`,
+        fetchMock.get(leadersUrl(0, "useEffect"),
             mockResponse
         );
 
@@ -76,3 +160,76 @@ describe("<LeaderBoard></LeaderBoard>", () => {
         })).toBeTruthy()
     });
 });
+
+describe("formatScores", () => {
+    test("Should show whole scores without decimals", () => {
+        expect(formatScores([12, 3, 0])).toEqual(["12", "3", "0"])
+    });
+
+    test("Should show all scores with two decimals when any score is fractional", () => {
+        expect(formatScores([12.5, 3, 0.25])).toEqual(["12.50", "3.00", "0.25"])
+    });
+
+    test("Should show no scores", () => {
+        expect(formatScores([])).toEqual([])
+    });
+});
+
+describe("applyScoreDelta", () => {
+    const participant = (loginName: string, score: number, rank?: number): Participant => ({
+        campaignName: selectedCampaign.name,
+        scpName: "GitHub",
+        loginName: loginName,
+        displayName: loginName,
+        score: score,
+        teamName: "",
+        rank: rank,
+    });
+
+    const delta = (loginName: string, score: number): ScoreDelta => ({
+        campaignName: selectedCampaign.name,
+        scpName: "GitHub",
+        loginName: loginName,
+        oldScore: 0,
+        score: score,
+    });
+
+    test("Should update the score and reorder the participants", () => {
+        const participants = [participant("first", 5, 1), participant("second", 3, 2)];
+        expect(applyScoreDelta(participants, delta("second", 7)))
+            .toEqual([participant("second", 7, 1), participant("first", 5, 2)])
+    });
+
+    test("Should list the participant after those who reached the same score first", () => {
+        const participants = [participant("first", 5, 1), participant("second", 5, 1), participant("third", 3, 2)];
+        expect(applyScoreDelta(participants, delta("third", 5)))
+            .toEqual([participant("first", 5, 1), participant("second", 5, 1), participant("third", 5, 1)])
+        expect(applyScoreDelta(participants, delta("first", 3)))
+            .toEqual([participant("second", 5, 1), participant("third", 3, 2), participant("first", 3, 2)])
+    });
+
+    test("Should rank densely from the first rank of the page", () => {
+        const participants = [participant("first", 8, 4), participant("second", 6, 5), participant("third", 4, 6)];
+        expect(applyScoreDelta(participants, delta("third", 6)))
+            .toEqual([participant("first", 8, 4), participant("second", 6, 5), participant("third", 6, 5)])
+    });
+
+    test("Should ignore an unchanged score", () => {
+        const participants = [participant("first", 5, 1), participant("second", 5, 1)];
+        expect(applyScoreDelta(participants, delta("first", 5))).toBe(participants)
+    });
+
+    test("Should ignore a participant not in the list", () => {
+        const participants = [participant("first", 5)];
+        expect(applyScoreDelta(participants, delta("unknown", 7))).toBe(participants)
+    });
+
+    test("Should ignore a delta of a private participant", () => {
+        const participants = [participant("first", 5), {campaignName: selectedCampaign.name, displayName: "private", score: 3, teamName: ""}];
+        expect(applyScoreDelta(participants, {campaignName: selectedCampaign.name, oldScore: 3, score: 7})).toBe(participants)
+    });
+
+    test("Should ignore a delta before the list is loaded", () => {
+        expect(applyScoreDelta(undefined, delta("first", 7))).toBeUndefined()
+    });
+});
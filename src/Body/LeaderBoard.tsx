@@ -22,31 +22,102 @@ type CampaignSelectProps = {
     selectedCampaign?: Campaign;
 }
 
-interface Participant {
-    guid: string
+// Participant is the public view of a participant. Private participants have no scpName or loginName.
+export interface Participant {
     campaignName: string
-    scpName: string
-    loginName: string
+    scpName?: string
+    loginName?: string
     displayName: string
-    email: string
     score: number
-    team: string
-    joinedAt: string
+    teamName: string
+    rank?: number
 }
 
+// ScoreDelta is a change to a participant score, streamed from /participant/stream/:campaignName. The delta of a
+// private participant has no scpName or loginName.
+export interface ScoreDelta {
+    campaignName: string
+    scpName?: string
+    loginName?: string
+    oldScore: number
+    score: number
+}
+
+// applyScoreDelta updates the score of the participant, keeping the list in the order of the participant list: from
+// the highest score down, and participants with equal scores in the order they reached that score, so the participant
+// whose score just changed follows the others with its new score. A delta for a participant not in the list is
+// ignored, as the list is reloaded whenever the stream reconnects. A delta for a private participant is ignored too,
+// as it can not be matched to a participant: reload the list instead.
+export const applyScoreDelta = (participants: Participant[] | undefined, delta: ScoreDelta): Participant[] | undefined => {
+    const index = participants && delta.loginName
+        ? participants.findIndex((participant) =>
+            participant.scpName === delta.scpName && participant.loginName === delta.loginName)
+        : -1;
+    if (!participants || index < 0 || participants[index].score === delta.score) {
+        return participants;
+    }
+    const others = participants.filter((_, i) => i !== index);
+    const below = others.findIndex((participant) => participant.score < delta.score);
+    const position = below < 0 ? others.length : below;
+    return rankParticipants([
+        ...others.slice(0, position),
+        {...participants[index], score: delta.score},
+        ...others.slice(position),
+    ], participants[0].rank ?? 1);
+}
+
+// rankParticipants ranks the ordered participants as the participant list does: participants with equal scores share
+// a rank, and the next score down takes the next rank, counting on from firstRank, the rank the page started at.
+const rankParticipants = (participants: Participant[], firstRank: number): Participant[] => {
+    let rank = firstRank;
+    return participants.map((participant, i) => {
+        if (i > 0 && participant.score !== participants[i - 1].score) {
+            rank++;
+        }
+        return {...participant, rank: rank};
+    });
+}
+
+// scores are stored with two decimal places
+const scoreDecimals = 2;
+
+// formatScores renders every score of the leaderboard with the same number of decimal places, so scores line up:
+// whole numbers when all scores are whole, and two decimal places when any score is fractional.
+export const formatScores = (scores: number[]): string[] => {
+    const decimals = scores.some((score) => !Number.isInteger(score)) ? scoreDecimals : 0;
+    return scores.map((score) => score.toFixed(decimals));
+}
+
+// leaderboardPageSize is the number of participants on a page of the leaderboard
+export const leaderboardPageSize = 100;
+
+// totalCountHeader holds the number of participants on all pages of the participant list
+const totalCountHeader = "X-Total-Count";
+
 type queryError = {
     error: boolean
     errorMessage: string
 }
 
+// leaderboardPage is the page of the campaign shown, by the offset of its first participant
+type leaderboardPage = {
+    campaignName?: string
+    offset: number
+}
+
 const LeaderBoard = (props: CampaignSelectProps) => {
 
     const [queryError, setQueryError] = useState<queryError>({error: false, errorMessage: ""}),
-        [participantList, setParticipantList] = useState<Participant[]>();
+        [participantList, setParticipantList] = useState<Participant[]>(),
+        [totalCount, setTotalCount] = useState<number>(0),
+        [page, setPage] = useState<leaderboardPage>({offset: 0});
 
     const clientContext = useContext(ClientContext);
 
-    const getLeaders = useCallback(async (campaign: Campaign | undefined, caller) => {
+    // a newly selected campaign starts on its first page
+    const offset = page.campaignName === props.selectedCampaign?.name ? page.offset : 0;
+
+    const getLeaders = useCallback(async (campaign: Campaign | undefined, pageOffset: number, caller) => {
         if (!campaign) {
             console.debug("no selectedCampaign, skipping getLeaders")
             return
@@ -54,12 +125,15 @@ const LeaderBoard = (props: CampaignSelectProps) => {
 
         const getLeadersAction: Action = {
             method: 'GET',
-            endpoint: `/participant/list/${campaign.name}?${qp.feature}=getLeaders&${qp.call}=${caller}`
+            endpoint: `/participant/list/${campaign.name}?limit=${leaderboardPageSize}&offset=${pageOffset}&${qp.feature}=getLeaders&${qp.call}=${caller}`
         }
         const res = await clientContext.query(getLeadersAction);
 
         if (!res.error) {
-            setParticipantList(res.payload ? res.payload : []);
+            const participants: Participant[] = res.payload ? res.payload : [];
+            const total = Number(res.headers?.get(totalCountHeader));
+            setParticipantList(participants);
+            setTotalCount(Number.isInteger(total) && total > 0 ? total : pageOffset + participants.length);
         } else {
             const errMsg = (res && res.payload) ? res.payload.error : res.errorObject.toString()
             setQueryError({error: true, errorMessage: errMsg});
@@ -68,13 +142,41 @@ const LeaderBoard = (props: CampaignSelectProps) => {
 
     useEffect(() => {
         // noinspection JSIgnoredPromiseFromCall
-        getLeaders(props.selectedCampaign, "useEffect");
-    }, [clientContext, props.selectedCampaign, getLeaders]) // rebuilds the list only when selectedCampaign changes
+        getLeaders(props.selectedCampaign, offset, "useEffect");
+    }, [clientContext, props.selectedCampaign, offset, getLeaders]) // rebuilds the list only when selectedCampaign or the page changes
+
+    useEffect(() => {
+        const campaign = props.selectedCampaign;
+        if (!campaign || typeof EventSource === "undefined") {
+            return
+        }
+
+        const scoreStream = new EventSource(`/participant/stream/${campaign.name}`);
+        // reload on every (re)connect, to catch up on any scores changed while disconnected
+        scoreStream.addEventListener("open", () => {
+            // noinspection JSIgnoredPromiseFromCall
+            getLeaders(campaign, offset, "streamOpen");
+        });
+        scoreStream.addEventListener("score", (event) => {
+            const delta: ScoreDelta = JSON.parse((event as MessageEvent).data);
+            if (!delta.loginName) {
+                // noinspection JSIgnoredPromiseFromCall
+                getLeaders(campaign, offset, "privateScore");
+                return
+            }
+            setParticipantList((participants) => applyScoreDelta(participants, delta));
+        });
+        return () => scoreStream.close();
+    }, [props.selectedCampaign, offset, getLeaders])
+
+    const showPage = (pageOffset: number) => {
+        setPage({campaignName: props.selectedCampaign?.name, offset: pageOffset});
+    }
 
     // noinspection JSUnusedLocalSymbols
     const onClick = (evt: MouseEvent<HTMLButtonElement>) => {
         // noinspection JSIgnoredPromiseFromCall
-        getLeaders(props.selectedCampaign, "refreshScores");
+        getLeaders(props.selectedCampaign, offset, "refreshScores");
     }
 
     const doRender = () => {
@@ -82,6 +184,8 @@ const LeaderBoard = (props: CampaignSelectProps) => {
             return <NxLoadError error={queryError.errorMessage}/>;
         }
 
+        const scores = formatScores(participantList ? participantList.map((participant) => participant.score) : []);
+
         return (
             <>
                 <NxButton variant="primary" onClick={onClick}>Refresh Scores</NxButton>
@@ -89,23 +193,35 @@ const LeaderBoard = (props: CampaignSelectProps) => {
                 <NxTable>
                     <NxTable.Head>
                         <NxTable.Row>
+                            <NxTable.Cell isNumeric>Rank</NxTable.Cell>
                             <NxTable.Cell>Source Code Repository User Name</NxTable.Cell>
                             <NxTable.Cell isNumeric>Score</NxTable.Cell>
                         </NxTable.Row>
                     </NxTable.Head>
                     <NxTable.Body>
-                        {participantList?.length ? participantList.map((participant) =>
+                        {participantList?.length ? participantList.map((participant, index) =>
                                 <NxTable.Row>
-                                    <NxTable.Cell>{participant.loginName}</NxTable.Cell>
-                                    <NxTable.Cell isNumeric>{participant.score}</NxTable.Cell>
+                                    <NxTable.Cell isNumeric>{participant.rank}</NxTable.Cell>
+                                    <NxTable.Cell>{participant.loginName ?? participant.displayName}</NxTable.Cell>
+                                    <NxTable.Cell isNumeric>{scores[index]}</NxTable.Cell>
                                 </NxTable.Row>
                             )
                             : <NxTable.Row>
+                                <NxTable.Cell isNumeric> </NxTable.Cell>
                                 <NxTable.Cell>No Participants</NxTable.Cell>
                                 <NxTable.Cell isNumeric> </NxTable.Cell>
                             </NxTable.Row>}
                     </NxTable.Body>
                 </NxTable>
+
+                {totalCount > leaderboardPageSize &&
+                    <div>
+                        <NxButton variant="tertiary" disabled={offset === 0}
+                                  onClick={() => showPage(Math.max(offset - leaderboardPageSize, 0))}>Previous</NxButton>
+                        <span>{`Participants ${offset + 1} - ${Math.min(offset + leaderboardPageSize, totalCount)} of ${totalCount}`}</span>
+                        <NxButton variant="tertiary" disabled={offset + leaderboardPageSize >= totalCount}
+                                  onClick={() => showPage(offset + leaderboardPageSize)}>Next</NxButton>
+                    </div>}
             </>
         )
     }